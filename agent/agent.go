@@ -12,6 +12,7 @@ import (
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/config"
 	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/internal/series"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -240,6 +241,17 @@ func (a *Agent) flush() {
 	wg.Wait()
 }
 
+// addToOutputs adds a metric to all configured outputs
+func (a *Agent) addToOutputs(m telegraf.Metric) {
+	for i, o := range a.Config.Outputs {
+		if i == len(a.Config.Outputs)-1 {
+			o.AddMetric(m)
+		} else {
+			o.AddMetric(m.Copy())
+		}
+	}
+}
+
 // flusher monitors the metrics input channel and flushes on the minimum interval
 func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) error {
 	// Inelegant, but this sleep is to allow the Gather threads to run, so that
@@ -249,6 +261,19 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) er
 	// create an output metric channel and a gorouting that continously passes
 	// each metric onto the output plugins & aggregators.
 	outMetricC := make(chan telegraf.Metric, 100)
+
+	// if series expiration is enabled, track the series sent to the outputs
+	// and periodically check for series that have not been seen recently.
+	var tracker *series.Tracker
+	var expireC <-chan time.Time
+	if a.Config.Agent.SeriesExpiration.Duration > 0 {
+		tracker = series.NewTracker(a.Config.Agent.SeriesExpiration.Duration,
+			a.Config.Agent.SeriesCacheLimit)
+		expireTicker := time.NewTicker(a.Config.Agent.Interval.Duration)
+		defer expireTicker.Stop()
+		expireC = expireTicker.C
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -261,6 +286,14 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) er
 					continue
 				}
 				return
+			case <-expireC:
+				markers := tracker.Expire(time.Now())
+				if !a.Config.Agent.StaleMarkers {
+					continue
+				}
+				for _, m := range markers {
+					a.addToOutputs(m)
+				}
 			case m := <-outMetricC:
 				// if dropOriginal is set to true, then we will only send this
 				// metric to the aggregators, not the outputs.
@@ -273,13 +306,10 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) er
 					}
 				}
 				if !dropOriginal {
-					for i, o := range a.Config.Outputs {
-						if i == len(a.Config.Outputs)-1 {
-							o.AddMetric(m)
-						} else {
-							o.AddMetric(m.Copy())
-						}
+					if tracker != nil {
+						tracker.Add(time.Now(), m)
 					}
+					a.addToOutputs(m)
 				}
 			}
 		}
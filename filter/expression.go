@@ -0,0 +1,603 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expression is a compiled boolean expression over the name, tags, fields and
+// time of a metric, ie:
+//
+//   e, _ := CompileExpression(`fields.status >= 500 || tags.env != "prod"`)
+//   e.Eval("http", map[string]string{"env": "prod"},
+//       map[string]interface{}{"status": int64(503)}, time.Now()) // true
+//
+// The following operands are available:
+//
+//   name                  the measurement name
+//   time                  the metric timestamp, compared against an RFC3339
+//                         string or a number of seconds since the epoch
+//   tags.key, tags["key"]        the value of a tag
+//   fields.key, fields["key"]    the value of a field
+//   "string", 'string', 1, 2.5, true, false
+//
+// Operands are compared with ==, !=, <, <=, >, >=, =~ and !~ (regular
+// expression match against a string literal), and comparisons are combined
+// with && (and), || (or), ! (not) and parentheses. Numbers are compared
+// regardless of their type. A missing tag or field is not equal to anything.
+type Expression struct {
+	expr string
+	root node
+}
+
+// CompileExpression parses the given expression.
+func CompileExpression(expr string) (*Expression, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	return &Expression{expr: expr, root: root}, nil
+}
+
+// Eval returns true if the expression holds for the given metric.
+func (e *Expression) Eval(
+	name string,
+	tags map[string]string,
+	fields map[string]interface{},
+	t time.Time,
+) bool {
+	m := &evalMetric{name: name, tags: tags, fields: fields, t: t}
+	b, ok := e.root.eval(m).(bool)
+	return ok && b
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.expr
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokDot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators, longest first so that "<=" is not lexed as "<".
+var operators = []string{
+	"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!",
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case c == '.' && !(i+1 < len(s) && isDigit(rune(s[i+1]))):
+			tokens = append(tokens, token{tokDot, ".", i})
+			i++
+		case c == '"' || c == '\'':
+			str, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%s at position %d", err, i)
+			}
+			tokens = append(tokens, token{tokString, str, i})
+			i += n
+		case isDigit(c) || c == '.' || (c == '-' && i+1 < len(s) && isDigit(rune(s[i+1]))):
+			j := i + 1
+			for j < len(s) && (isDigit(rune(s[j])) || strings.ContainsRune(".eE", rune(s[j])) ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, s[i:j], i})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(s) && isIdentPart(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			var op string
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(s)}), nil
+}
+
+// lexString reads a quoted string at the start of s, and returns its unquoted
+// value and the number of bytes read. Only quotes and backslashes can be
+// escaped, other backslashes are kept so that regular expressions such as
+// "\d+" need no double escaping.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var buf []byte
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case quote:
+			return string(buf), i + 1, nil
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == quote || s[i+1] == '\\') {
+				i++
+			}
+			buf = append(buf, s[i])
+		default:
+			buf = append(buf, s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || unicode.IsDigit(c)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %s, got %s at position %d",
+			what, tok, tok.pos)
+	}
+	return tok, nil
+}
+
+// isOp returns true if the next token is one of the given operators or
+// keywords.
+func (p *parser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokIdent {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&", "and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!", "not") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "=~", "!~") {
+		return left, nil
+	}
+	op := p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "=~", "!~":
+		var pattern string
+		if lit, ok := right.(*literalNode); ok {
+			pattern, _ = lit.v.(string)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("%s must be followed by a string at position %d",
+				op.text, op.pos)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &matchNode{left: left, re: re, negate: op.text == "!~"}, nil
+	}
+
+	// compare time against a timestamp string
+	left, err = convertTime(left, right)
+	if err != nil {
+		return nil, err
+	}
+	right, err = convertTime(right, left)
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op.text, left: left, right: right}, nil
+}
+
+// convertTime parses n as an RFC3339 timestamp if it is a string literal that
+// is compared against the metric time.
+func convertTime(n, other node) (node, error) {
+	if _, ok := other.(*timeNode); !ok {
+		return n, nil
+	}
+	lit, ok := n.(*literalNode)
+	if !ok {
+		return n, nil
+	}
+	s, ok := lit.v.(string)
+	if !ok {
+		return n, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return &literalNode{t}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokString:
+		return &literalNode{tok.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", tok, tok.pos)
+		}
+		return &literalNode{f}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "name":
+			return &nameNode{}, nil
+		case "time":
+			return &timeNode{}, nil
+		case "tags", "fields":
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if tok.text == "tags" {
+				return &tagNode{key}, nil
+			}
+			return &fieldNode{key}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+// parseKey parses the .key or ["key"] following tags or fields.
+func (p *parser) parseKey() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokDot:
+		key, err := p.expect(tokIdent, "key")
+		return key.text, err
+	case tokLBracket:
+		key, err := p.expect(tokString, "string")
+		if err != nil {
+			return "", err
+		}
+		_, err = p.expect(tokRBracket, `"]"`)
+		return key.text, err
+	}
+	return "", fmt.Errorf(`expected "." or "[", got %s at position %d`,
+		tok, tok.pos)
+}
+
+type evalMetric struct {
+	name   string
+	tags   map[string]string
+	fields map[string]interface{}
+	t      time.Time
+}
+
+// node is a node of the expression tree. eval returns nil for missing
+// values, and otherwise one of bool, float64, string or time.Time.
+type node interface {
+	eval(m *evalMetric) interface{}
+}
+
+type literalNode struct {
+	v interface{}
+}
+
+func (n *literalNode) eval(m *evalMetric) interface{} {
+	return n.v
+}
+
+type nameNode struct{}
+
+func (n *nameNode) eval(m *evalMetric) interface{} {
+	return m.name
+}
+
+type timeNode struct{}
+
+func (n *timeNode) eval(m *evalMetric) interface{} {
+	return m.t
+}
+
+type tagNode struct {
+	key string
+}
+
+func (n *tagNode) eval(m *evalMetric) interface{} {
+	if v, ok := m.tags[n.key]; ok {
+		return v
+	}
+	return nil
+}
+
+type fieldNode struct {
+	key string
+}
+
+func (n *fieldNode) eval(m *evalMetric) interface{} {
+	v, ok := m.fields[n.key]
+	if !ok {
+		return nil
+	}
+	switch v := v.(type) {
+	case float64, string, bool:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	}
+	return nil
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n *andNode) eval(m *evalMetric) interface{} {
+	return truth(n.left.eval(m)) && truth(n.right.eval(m))
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n *orNode) eval(m *evalMetric) interface{} {
+	return truth(n.left.eval(m)) || truth(n.right.eval(m))
+}
+
+type notNode struct {
+	n node
+}
+
+func (n *notNode) eval(m *evalMetric) interface{} {
+	return !truth(n.n.eval(m))
+}
+
+type matchNode struct {
+	left   node
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n *matchNode) eval(m *evalMetric) interface{} {
+	s, ok := n.left.eval(m).(string)
+	return (ok && n.re.MatchString(s)) != n.negate
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(m *evalMetric) interface{} {
+	left, right := n.left.eval(m), n.right.eval(m)
+	cmp, ok := compare(left, right)
+	if _, isBool := left.(bool); isBool && n.op != "==" && n.op != "!=" {
+		return false
+	}
+	switch n.op {
+	case "==":
+		return ok && cmp == 0
+	case "!=":
+		return !ok || cmp != 0
+	case "<":
+		return ok && cmp < 0
+	case "<=":
+		return ok && cmp <= 0
+	case ">":
+		return ok && cmp > 0
+	case ">=":
+		return ok && cmp >= 0
+	}
+	return false
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater than b,
+// and false if the values are not comparable, or if one of them is NaN.
+// Booleans are not ordered, so only whether they are equal is meaningful.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		switch b := b.(type) {
+		case float64:
+			return compareFloat(a, b)
+		case time.Time:
+			return compareFloat(a, float64(b.UnixNano())/1e9)
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		switch b := b.(type) {
+		case time.Time:
+			return compareFloat(float64(a.UnixNano()), float64(b.UnixNano()))
+		case float64:
+			return compareFloat(float64(a.UnixNano())/1e9, b)
+		}
+	}
+	return 0, false
+}
+
+// compareFloat compares two floats, NaN being unordered: it is neither
+// equal to, less than nor greater than any value.
+func compareFloat(a, b float64) (int, bool) {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return 0, false
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	}
+	return 0, true
+}
+
+func truth(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
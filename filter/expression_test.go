@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionEval(t *testing.T) {
+	now := time.Date(2017, time.February, 14, 10, 0, 0, 0, time.UTC)
+	tags := map[string]string{"env": "prod", "host": "web01", "dotted.key": "x"}
+	fields := map[string]interface{}{
+		"status":  int64(503),
+		"latency": 0.25,
+		"message": "timeout",
+		"ok":      false,
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`name == "http"`, true},
+		{`name != "http"`, false},
+		{`name =~ "^ht"`, true},
+		{`name !~ "^ht"`, false},
+		{`fields.status >= 500`, true},
+		{`fields.status >= 500 && fields.status < 600`, true},
+		{`fields.status == 503.0`, true},
+		{`fields.latency > .5`, false},
+		{`fields.latency > -1`, true},
+		{`fields.status >= 500 || tags.env != "prod"`, true},
+		{`fields.status < 500 || tags.env != "prod"`, false},
+		{`fields.status < 500 or not (tags.env == "prod")`, false},
+		{`!(fields.status < 500) and tags.host =~ 'web\d+'`, true},
+		{`tags["dotted.key"] == "x"`, true},
+		{`fields["message"] == "timeout"`, true},
+		{`fields.ok`, false},
+		{`!fields.ok`, true},
+		{`fields.ok == false`, true},
+		{`fields.ok < true`, false},
+		{`fields.message > 5`, false},
+		{`fields.message != 5`, true},
+		{`tags.missing == ""`, false},
+		{`tags.missing != "prod"`, true},
+		{`fields.missing > 0`, false},
+		{`tags.missing !~ "prod"`, true},
+		{`time > "2017-02-14T09:00:00Z"`, true},
+		{`time >= "2017-02-14T10:00:00Z" && time < "2017-02-14T11:00:00Z"`, true},
+		{`time < 1487066400`, false},
+		{`time == 1487066400`, true},
+		{`fields.status`, false},
+	}
+
+	for _, tt := range tests {
+		e, err := CompileExpression(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.expected, e.Eval("http", tags, fields, now), tt.expr)
+	}
+}
+
+func TestExpressionEvalNaN(t *testing.T) {
+	fields := map[string]interface{}{"x": math.NaN()}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`fields.x == 5`, false},
+		{`fields.x != 5`, true},
+		{`fields.x < 5`, false},
+		{`fields.x >= 5`, false},
+		{`fields.x == fields.x`, false},
+		{`fields.x != fields.x`, true},
+	}
+
+	for _, tt := range tests {
+		e, err := CompileExpression(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.expected, e.Eval("m", nil, fields, time.Now()), tt.expr)
+	}
+}
+
+func TestExpressionCompileErrors(t *testing.T) {
+	exprs := []string{
+		``,
+		`name ==`,
+		`name == "http`,
+		`(name == "http"`,
+		`name == "http")`,
+		`name =~ 5`,
+		`name =~ "("`,
+		`tags.`,
+		`tags[env]`,
+		`fields`,
+		`time > "yesterday"`,
+		`name # "http"`,
+		`host == "web01"`,
+	}
+
+	for _, expr := range exprs {
+		_, err := CompileExpression(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -124,6 +124,19 @@ type AgentConfig struct {
 	// does _not_ deactivate FlushInterval.
 	FlushBufferWhenFull bool
 
+	// SeriesExpiration is the amount of time after which a series that has
+	// not received any new points is considered ended. Series are only
+	// tracked when this is set.
+	SeriesExpiration internal.Duration
+
+	// SeriesCacheLimit is the maximum number of series that are tracked for
+	// expiration. When full, the least recently seen series is forgotten.
+	SeriesCacheLimit int
+
+	// StaleMarkers tells Telegraf to send a staleness marker metric to the
+	// outputs when a series expires.
+	StaleMarkers bool
+
 	// TODO(cam): Remove UTC and parameter, they are no longer
 	// valid for the agent config. Leaving them here for now for backwards-
 	// compatability
@@ -230,6 +243,15 @@ var header = `# Telegraf Configuration
   ## ie, a jitter of 5s and interval 10s means flushes will happen every 10-15s
   flush_jitter = "0s"
 
+  ## Series which have not received any points for series_expiration are
+  ## considered ended. Telegraf tracks at most series_cache_limit series.
+  ## Series expiration is disabled when series_expiration is "0s".
+  # series_expiration = "0s"
+  # series_cache_limit = 100000
+  ## Send a metric with a "_stale=true" field to the outputs when a series
+  ## expires, so that they can mark the end of the series.
+  # stale_markers = false
+
   ## By default, precision will be set to the same timestamp order as the
   ## collection interval, with the maximum being 1s.
   ## Precision will NOT be used for service inputs, such as logparser and statsd.
@@ -935,7 +957,8 @@ func buildAggregator(name string, tbl *ast.Table) (*models.AggregatorConfig, err
 // models.ProcessorConfig to be inserted into models.RunningProcessor
 func buildProcessor(name string, tbl *ast.Table) (*models.ProcessorConfig, error) {
 	conf := &models.ProcessorConfig{Name: name}
-	unsupportedFields := []string{"tagexclude", "taginclude", "fielddrop", "fieldpass"}
+	unsupportedFields := []string{"tagexclude", "taginclude", "fielddrop", "fieldpass",
+		"fieldvalue"}
 	for _, field := range unsupportedFields {
 		if _, ok := tbl.Fields[field]; ok {
 			return nil, fmt.Errorf("%s is not supported for processor plugins (%s).",
@@ -965,7 +988,7 @@ func buildProcessor(name string, tbl *ast.Table) (*models.ProcessorConfig, error
 }
 
 // buildFilter builds a Filter
-// (tagpass/tagdrop/namepass/namedrop/fieldpass/fielddrop) to
+// (tagpass/tagdrop/namepass/namedrop/fieldpass/fielddrop/metricpass/fieldvalue) to
 // be inserted into the models.OutputConfig/models.InputConfig
 // to be used for glob filtering on tags and measurements
 func buildFilter(tbl *ast.Table) (models.Filter, error) {
@@ -1084,6 +1107,31 @@ func buildFilter(tbl *ast.Table) (models.Filter, error) {
 			}
 		}
 	}
+	if node, ok := tbl.Fields["metricpass"]; ok {
+		kv, ok := node.(*ast.KeyValue)
+		if !ok {
+			return f, fmt.Errorf("Error parsing 'metricpass', must be a string")
+		}
+		str, ok := kv.Value.(*ast.String)
+		if !ok {
+			return f, fmt.Errorf("Error parsing 'metricpass', must be a string")
+		}
+		f.MetricPass = str.Value
+	}
+	if node, ok := tbl.Fields["fieldvalue"]; ok {
+		subtbls, ok := node.([]*ast.Table)
+		if !ok {
+			return f, fmt.Errorf("Error parsing 'fieldvalue', must be an array " +
+				"of tables, ie [[inputs.name.fieldvalue]]")
+		}
+		for _, subtbl := range subtbls {
+			fv, err := buildFieldValueFilter(subtbl)
+			if err != nil {
+				return f, err
+			}
+			f.FieldValue = append(f.FieldValue, fv)
+		}
+	}
 	if err := f.Compile(); err != nil {
 		return f, err
 	}
@@ -1098,9 +1146,102 @@ func buildFilter(tbl *ast.Table) (models.Filter, error) {
 	delete(tbl.Fields, "tagpass")
 	delete(tbl.Fields, "tagexclude")
 	delete(tbl.Fields, "taginclude")
+	delete(tbl.Fields, "metricpass")
+	delete(tbl.Fields, "fieldvalue")
 	return f, nil
 }
 
+// buildFieldValueFilter builds a models.FieldValueFilter from a
+// [[*.fieldvalue]] table
+func buildFieldValueFilter(tbl *ast.Table) (models.FieldValueFilter, error) {
+	fv := models.FieldValueFilter{}
+
+	if node, ok := tbl.Fields["fields"]; ok {
+		var ary *ast.Array
+		if kv, ok := node.(*ast.KeyValue); ok {
+			ary, _ = kv.Value.(*ast.Array)
+		}
+		if ary == nil {
+			return fv, fmt.Errorf("Error parsing 'fieldvalue' fields, must be an array of strings")
+		}
+		for _, elem := range ary.Value {
+			str, ok := elem.(*ast.String)
+			if !ok {
+				return fv, fmt.Errorf("Error parsing 'fieldvalue' fields, must be an array of strings")
+			}
+			fv.Fields = append(fv.Fields, str.Value)
+		}
+	}
+
+	for _, field := range []string{"min", "max"} {
+		node, ok := tbl.Fields[field]
+		if !ok {
+			continue
+		}
+		kv, ok := node.(*ast.KeyValue)
+		if !ok {
+			continue
+		}
+
+		var bound float64
+		var err error
+		switch v := kv.Value.(type) {
+		case *ast.Integer:
+			bound, err = strconv.ParseFloat(v.Value, 64)
+		case *ast.Float:
+			bound, err = strconv.ParseFloat(v.Value, 64)
+		default:
+			err = fmt.Errorf("must be a number")
+		}
+		if err != nil {
+			return fv, fmt.Errorf("Error parsing 'fieldvalue' %s, %s", field, err)
+		}
+
+		if field == "min" {
+			fv.Min = &bound
+		} else {
+			fv.Max = &bound
+		}
+	}
+
+	if node, ok := tbl.Fields["drop_nan"]; ok {
+		var b *ast.Boolean
+		if kv, ok := node.(*ast.KeyValue); ok {
+			b, _ = kv.Value.(*ast.Boolean)
+		}
+		if b == nil {
+			return fv, fmt.Errorf("Error parsing 'fieldvalue' drop_nan, must be a boolean")
+		}
+		var err error
+		fv.DropNaN, err = strconv.ParseBool(b.Value)
+		if err != nil {
+			return fv, fmt.Errorf("Error parsing 'fieldvalue' drop_nan, %s", err)
+		}
+	}
+
+	for _, field := range []string{"pass_pattern", "drop_pattern"} {
+		node, ok := tbl.Fields[field]
+		if !ok {
+			continue
+		}
+		var str *ast.String
+		if kv, ok := node.(*ast.KeyValue); ok {
+			str, _ = kv.Value.(*ast.String)
+		}
+		if str == nil {
+			return fv, fmt.Errorf("Error parsing 'fieldvalue' %s, must be a string", field)
+		}
+
+		if field == "pass_pattern" {
+			fv.PassPattern = str.Value
+		} else {
+			fv.DropPattern = str.Value
+		}
+	}
+
+	return fv, nil
+}
+
 // buildInput parses input specific items from the ast.Table,
 // builds the filter and returns a
 // models.InputConfig to be inserted into models.RunningInput
@@ -1218,6 +1359,50 @@ func buildParser(name string, tbl *ast.Table) (parsers.Parser, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["json_string_fields"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.JSONStringFields = append(c.JSONStringFields, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_query"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONQuery = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_name_key"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONNameKey = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_time_key"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONTimeKey = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_time_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONTimeFormat = str.Value
+			}
+		}
+	}
+
 	if node, ok := tbl.Fields["data_type"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
 			if str, ok := kv.Value.(*ast.String); ok {
@@ -1226,13 +1411,216 @@ func buildParser(name string, tbl *ast.Table) (parsers.Parser, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["csv_header_row_count"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := strconv.Atoi(integer.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing csv_header_row_count, %s", err)
+				}
+				c.CSVHeaderRowCount = v
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_skip_rows"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := strconv.Atoi(integer.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing csv_skip_rows, %s", err)
+				}
+				c.CSVSkipRows = v
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_delimiter"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVDelimiter = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_comment"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVComment = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_trim_space"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.CSVTrimSpace, err = strconv.ParseBool(b.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing csv_trim_space, %s", err)
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_column_names"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CSVColumnNames = append(c.CSVColumnNames, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_column_types"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CSVColumnTypes = append(c.CSVColumnTypes, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_tag_columns"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CSVTagColumns = append(c.CSVTagColumns, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_measurement_column"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVMeasurementColumn = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_timestamp_column"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVTimestampColumn = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVTimestampFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["grok_patterns"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.GrokPatterns = append(c.GrokPatterns, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["grok_custom_patterns"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.GrokCustomPatterns = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["grok_custom_pattern_files"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.GrokCustomPatternFiles = append(c.GrokCustomPatternFiles, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_auth_file"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CollectdAuthFile = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_security_level"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CollectdSecurityLevel = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_typesdb"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CollectdTypesDB = append(c.CollectdTypesDB, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_parse_multivalue"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CollectdParseMultiValue = str.Value
+			}
+		}
+	}
+
 	c.MetricName = name
 
 	delete(tbl.Fields, "data_format")
 	delete(tbl.Fields, "separator")
 	delete(tbl.Fields, "templates")
 	delete(tbl.Fields, "tag_keys")
+	delete(tbl.Fields, "json_string_fields")
+	delete(tbl.Fields, "json_query")
+	delete(tbl.Fields, "json_name_key")
+	delete(tbl.Fields, "json_time_key")
+	delete(tbl.Fields, "json_time_format")
 	delete(tbl.Fields, "data_type")
+	delete(tbl.Fields, "csv_header_row_count")
+	delete(tbl.Fields, "csv_skip_rows")
+	delete(tbl.Fields, "csv_delimiter")
+	delete(tbl.Fields, "csv_comment")
+	delete(tbl.Fields, "csv_trim_space")
+	delete(tbl.Fields, "csv_column_names")
+	delete(tbl.Fields, "csv_column_types")
+	delete(tbl.Fields, "csv_tag_columns")
+	delete(tbl.Fields, "csv_measurement_column")
+	delete(tbl.Fields, "csv_timestamp_column")
+	delete(tbl.Fields, "csv_timestamp_format")
+	delete(tbl.Fields, "grok_patterns")
+	delete(tbl.Fields, "grok_custom_patterns")
+	delete(tbl.Fields, "grok_custom_pattern_files")
+	delete(tbl.Fields, "collectd_auth_file")
+	delete(tbl.Fields, "collectd_security_level")
+	delete(tbl.Fields, "collectd_typesdb")
+	delete(tbl.Fields, "collectd_parse_multivalue")
 
 	return parsers.NewParser(c)
 }
@@ -1271,9 +1659,66 @@ func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error
 		}
 	}
 
+	if node, ok := tbl.Fields["csv_header"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.CSVHeader, err = strconv.ParseBool(b.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing csv_header, %s", err)
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVTimestampFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_delimiter"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CSVDelimiter = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["csv_column_prefix"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.CSVColumnPrefix, err = strconv.ParseBool(b.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing csv_column_prefix, %s", err)
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["graphite_tag_support"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.GraphiteTagSupport, err = strconv.ParseBool(b.Value)
+				if err != nil {
+					return nil, fmt.Errorf("Error parsing graphite_tag_support, %s", err)
+				}
+			}
+		}
+	}
+
 	delete(tbl.Fields, "data_format")
 	delete(tbl.Fields, "prefix")
 	delete(tbl.Fields, "template")
+	delete(tbl.Fields, "graphite_tag_support")
+	delete(tbl.Fields, "csv_header")
+	delete(tbl.Fields, "csv_timestamp_format")
+	delete(tbl.Fields, "csv_delimiter")
+	delete(tbl.Fields, "csv_column_prefix")
 	return serializers.NewSerializer(c)
 }
 
@@ -12,7 +12,9 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs/procstat"
 	"github.com/influxdata/telegraf/plugins/parsers"
 
+	"github.com/influxdata/toml"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfig_LoadSingleInputWithEnvVars(t *testing.T) {
@@ -174,3 +176,41 @@ func TestConfig_LoadDirectory(t *testing.T) {
 	assert.Equal(t, pConfig, c.Inputs[3].Config,
 		"Merged Testdata did not produce correct procstat metadata.")
 }
+
+func TestConfig_BuildFilterErrors(t *testing.T) {
+	for _, conf := range []string{
+		`metricpass = 5`,
+		`metricpass = ["fields.x > 1"]`,
+		"[fieldvalue]\nmin = 0",
+		"[[fieldvalue]]\ndrop_nan = \"true\"",
+		"[[fieldvalue]]\nfields = \"usage\"",
+		"[[fieldvalue]]\nfields = [\"usage\", 1]",
+		"[[fieldvalue]]\npass_pattern = [\"usage\"]",
+		"[[fieldvalue]]\ndrop_pattern = 1",
+	} {
+		tbl, err := toml.Parse([]byte(conf))
+		require.NoError(t, err)
+		_, err = buildFilter(tbl)
+		assert.Error(t, err, conf)
+	}
+}
+
+func TestConfig_BuildFieldValueFilter(t *testing.T) {
+	tbl, err := toml.Parse([]byte(`
+[[fieldvalue]]
+fields = ["usage_*"]
+max = 100
+pass_pattern = "^ok"
+drop_pattern = "^err"
+`))
+	require.NoError(t, err)
+	f, err := buildFilter(tbl)
+	require.NoError(t, err)
+	require.Len(t, f.FieldValue, 1)
+	fv := f.FieldValue[0]
+	assert.Equal(t, []string{"usage_*"}, fv.Fields)
+	require.NotNil(t, fv.Max)
+	assert.Equal(t, float64(100), *fv.Max)
+	assert.Equal(t, "^ok", fv.PassPattern)
+	assert.Equal(t, "^err", fv.DropPattern)
+}
@@ -67,6 +67,52 @@ func (d *Duration) UnmarshalTOML(b []byte) error {
 	return nil
 }
 
+// Size is a size in bytes, which can be given with a unit in the TOML config
+// file, ie: "10MB".
+type Size struct {
+	Size int64
+}
+
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	// longest suffixes first, as "B" is a suffix of all of them.
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// UnmarshalTOML parses the size from the TOML config file, as an integer
+// number of bytes or a string with a unit.
+func (s *Size) UnmarshalTOML(b []byte) error {
+	str := string(bytes.Trim(b, `'`))
+	if uq, err := strconv.Unquote(str); err == nil {
+		str = uq
+	}
+	str = strings.TrimSpace(str)
+
+	factor := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(str, unit.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %s", b)
+	}
+	s.Size = n * factor
+	return nil
+}
+
 // ReadLines reads contents from a file and splits them by new lines.
 // A convenience wrapper to ReadLinesOffsetN(filename, 0, -1).
 func ReadLines(filename string) ([]string, error) {
@@ -155,6 +201,47 @@ func GetTLSConfig(
 	return t, nil
 }
 
+// GetServerTLSConfig gets a tls.Config object for a server from the given
+// certificate and key files. If allowed CAs are given, clients must present a
+// certificate signed by one of them.
+func GetServerTLSConfig(
+	SSLCert, SSLKey string,
+	SSLAllowedClientCA []string,
+) (*tls.Config, error) {
+	if SSLCert == "" && SSLKey == "" && len(SSLAllowedClientCA) == 0 {
+		return nil, nil
+	}
+
+	t := &tls.Config{}
+
+	if len(SSLAllowedClientCA) != 0 {
+		caCertPool := x509.NewCertPool()
+		for _, cert := range SSLAllowedClientCA {
+			caCert, err := ioutil.ReadFile(cert)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Could not load TLS CA: %s",
+					err))
+			}
+			caCertPool.AppendCertsFromPEM(caCert)
+		}
+		t.ClientCAs = caCertPool
+		t.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if SSLCert == "" || SSLKey == "" {
+		return nil, errors.New("TLS server key and certificate are required")
+	}
+	cert, err := tls.LoadX509KeyPair(SSLCert, SSLKey)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf(
+			"Could not load TLS server key/certificate from %s:%s: %s",
+			SSLKey, SSLCert, err))
+	}
+	t.Certificates = []tls.Certificate{cert}
+
+	return t, nil
+}
+
 // SnakeCase converts the given string to snake case following the Golang format:
 // acronyms are converted to lower-case and preceded by an underscore.
 func SnakeCase(in string) string {
@@ -154,3 +154,29 @@ func TestDuration(t *testing.T) {
 	d.UnmarshalTOML([]byte(`1.5`))
 	assert.Equal(t, time.Second, d.Duration)
 }
+
+func TestSize(t *testing.T) {
+	var s Size
+
+	assert.NoError(t, s.UnmarshalTOML([]byte(`1024`)))
+	assert.Equal(t, int64(1024), s.Size)
+
+	s = Size{}
+	assert.NoError(t, s.UnmarshalTOML([]byte(`"10MB"`)))
+	assert.Equal(t, int64(10000000), s.Size)
+
+	s = Size{}
+	assert.NoError(t, s.UnmarshalTOML([]byte(`'2 KiB'`)))
+	assert.Equal(t, int64(2048), s.Size)
+
+	s = Size{}
+	assert.NoError(t, s.UnmarshalTOML([]byte(`"1GiB"`)))
+	assert.Equal(t, int64(1<<30), s.Size)
+
+	s = Size{}
+	assert.NoError(t, s.UnmarshalTOML([]byte(`"512B"`)))
+	assert.Equal(t, int64(512), s.Size)
+
+	s = Size{}
+	assert.Error(t, s.UnmarshalTOML([]byte(`"10XB"`)))
+}
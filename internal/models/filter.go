@@ -2,8 +2,12 @@ package models
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"time"
 
 	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/metric"
 )
 
 // TagFilter is the name of a tag, and the values on which to filter
@@ -13,6 +17,114 @@ type TagFilter struct {
 	filter filter.Filter
 }
 
+// FieldValueFilter drops the fields matching Fields whose value is outside of
+// the Min/Max bounds, is NaN, or does not pass the patterns.
+type FieldValueFilter struct {
+	// Fields is a list of globs of the field keys to check, all fields are
+	// checked if empty.
+	Fields []string
+	fields filter.Filter
+
+	// Min and Max are the inclusive bounds of numeric values, if set.
+	Min *float64
+	Max *float64
+	// DropNaN drops numeric fields with a NaN value.
+	DropNaN bool
+
+	// PassPattern and DropPattern are regular expressions that string values
+	// must match, or must not match.
+	PassPattern string
+	passPattern *regexp.Regexp
+	DropPattern string
+	dropPattern *regexp.Regexp
+}
+
+// compile compiles the field globs and the patterns.
+func (fv *FieldValueFilter) compile() error {
+	var err error
+	fv.fields, err = filter.Compile(fv.Fields)
+	if err != nil {
+		return err
+	}
+	if fv.PassPattern != "" {
+		fv.passPattern, err = regexp.Compile(fv.PassPattern)
+		if err != nil {
+			return err
+		}
+	}
+	if fv.DropPattern != "" {
+		fv.dropPattern, err = regexp.Compile(fv.DropPattern)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shouldPass returns true if the field should pass, false if should drop
+// based on its value.
+func (fv *FieldValueFilter) shouldPass(key string, value interface{}) bool {
+	if fv.fields != nil && !fv.fields.Match(key) {
+		return true
+	}
+
+	if s, ok := value.(string); ok {
+		if fv.passPattern != nil && !fv.passPattern.MatchString(s) {
+			return false
+		}
+		if fv.dropPattern != nil && fv.dropPattern.MatchString(s) {
+			return false
+		}
+		return true
+	}
+
+	v, ok := toFloat(value)
+	if !ok {
+		return true
+	}
+	if math.IsNaN(v) {
+		// NaN is never within bounds, infinities are compared as is.
+		return !fv.DropNaN && fv.Min == nil && fv.Max == nil
+	}
+	if fv.Min != nil && v < *fv.Min {
+		return false
+	}
+	if fv.Max != nil && v > *fv.Max {
+		return false
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	}
+	return 0, false
+}
+
 // Filter containing drop/pass and tagdrop/tagpass rules
 type Filter struct {
 	NameDrop []string
@@ -33,6 +145,11 @@ type Filter struct {
 	TagInclude []string
 	tagInclude filter.Filter
 
+	MetricPass string
+	metricPass *filter.Expression
+
+	FieldValue []FieldValueFilter
+
 	isActive bool
 }
 
@@ -45,7 +162,9 @@ func (f *Filter) Compile() error {
 		len(f.TagInclude) == 0 &&
 		len(f.TagExclude) == 0 &&
 		len(f.TagPass) == 0 &&
-		len(f.TagDrop) == 0 {
+		len(f.TagDrop) == 0 &&
+		len(f.MetricPass) == 0 &&
+		len(f.FieldValue) == 0 {
 		return nil
 	}
 
@@ -90,6 +209,19 @@ func (f *Filter) Compile() error {
 			return fmt.Errorf("Error compiling 'tagpass', %s", err)
 		}
 	}
+
+	for i := range f.FieldValue {
+		if err := f.FieldValue[i].compile(); err != nil {
+			return fmt.Errorf("Error compiling 'fieldvalue', %s", err)
+		}
+	}
+
+	if f.MetricPass != "" {
+		f.metricPass, err = filter.CompileExpression(f.MetricPass)
+		if err != nil {
+			return fmt.Errorf("Error compiling 'metricpass', %s", err)
+		}
+	}
 	return nil
 }
 
@@ -116,14 +248,17 @@ func (f *Filter) Apply(
 		return false
 	}
 
-	// filter fields
-	for fieldkey, _ := range fields {
-		if !f.shouldFieldPass(fieldkey) {
-			delete(fields, fieldkey)
+	// filter fields, staleness markers are kept so that the outputs receiving
+	// a series also receive its end.
+	if !isStaleMarker(fields) {
+		for fieldkey, value := range fields {
+			if !f.shouldFieldPass(fieldkey) || !f.shouldFieldValuePass(fieldkey, value) {
+				delete(fields, fieldkey)
+			}
+		}
+		if len(fields) == 0 {
+			return false
 		}
-	}
-	if len(fields) == 0 {
-		return false
 	}
 
 	// filter tags
@@ -132,6 +267,28 @@ func (f *Filter) Apply(
 	return true
 }
 
+// ShouldMetricPass returns true if the metric should pass, false if should
+// drop based on the metricpass expression. It must be called before Apply, as
+// the expression is evaluated against all of the fields of the metric.
+func (f *Filter) ShouldMetricPass(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t time.Time,
+) bool {
+	if f.metricPass == nil || isStaleMarker(fields) {
+		return true
+	}
+	return f.metricPass.Eval(measurement, tags, fields, t)
+}
+
+// isStaleMarker returns true if the fields are those of a staleness marker,
+// which carries no values to filter on.
+func isStaleMarker(fields map[string]interface{}) bool {
+	stale, ok := fields[metric.StaleField].(bool)
+	return ok && stale
+}
+
 func (f *Filter) IsActive() bool {
 	return f.isActive
 }
@@ -172,6 +329,17 @@ func (f *Filter) shouldFieldPass(key string) bool {
 	return true
 }
 
+// shouldFieldValuePass returns true if the field should pass, false if should
+// drop based on the fieldvalue filters
+func (f *Filter) shouldFieldValuePass(key string, value interface{}) bool {
+	for i := range f.FieldValue {
+		if !f.FieldValue[i].shouldPass(key, value) {
+			return false
+		}
+	}
+	return true
+}
+
 // shouldTagsPass returns true if the metric should pass, false if should drop
 // based on the tagdrop/tagpass filter parameters
 func (f *Filter) shouldTagsPass(tags map[string]string) bool {
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -59,6 +61,150 @@ func TestFilter_ApplyDeleteAllFields(t *testing.T) {
 	assert.False(t, f.Apply("m", fields, nil))
 }
 
+func TestFilter_MetricPass(t *testing.T) {
+	f := Filter{
+		MetricPass: `fields.status >= 500 || tags.env != "prod"`,
+	}
+	require.NoError(t, f.Compile())
+	assert.True(t, f.IsActive())
+
+	now := time.Now()
+	assert.True(t, f.ShouldMetricPass("http",
+		map[string]interface{}{"status": int64(503)},
+		map[string]string{"env": "prod"}, now))
+	assert.True(t, f.ShouldMetricPass("http",
+		map[string]interface{}{"status": int64(200)},
+		map[string]string{"env": "dev"}, now))
+	assert.False(t, f.ShouldMetricPass("http",
+		map[string]interface{}{"status": int64(200)},
+		map[string]string{"env": "prod"}, now))
+}
+
+func TestFilter_StaleMarker(t *testing.T) {
+	f := Filter{
+		NamePass:   []string{"cpu"},
+		FieldPass:  []string{"usage_*"},
+		MetricPass: `fields.usage_idle < 10`,
+	}
+	require.NoError(t, f.Compile())
+
+	// the markers of the series passing the name and tag filters pass.
+	fields := map[string]interface{}{"_stale": true}
+	assert.True(t, f.ShouldMetricPass("cpu", fields, nil, time.Now()))
+	assert.True(t, f.Apply("cpu", fields, map[string]string{}))
+	assert.Equal(t, map[string]interface{}{"_stale": true}, fields)
+
+	assert.False(t, f.Apply("mem", fields, map[string]string{}))
+}
+
+func TestFilter_MetricPassInvalid(t *testing.T) {
+	f := Filter{
+		MetricPass: `fields.status >=`,
+	}
+	require.Error(t, f.Compile())
+}
+
+func TestFilter_FieldValueRange(t *testing.T) {
+	min, max := -40.0, 150.0
+	f := Filter{
+		FieldValue: []FieldValueFilter{
+			{
+				Fields:  []string{"temp_*"},
+				Min:     &min,
+				Max:     &max,
+				DropNaN: true,
+			},
+		},
+	}
+	require.NoError(t, f.Compile())
+	assert.True(t, f.IsActive())
+
+	fields := map[string]interface{}{
+		"temp_input": float64(42.5),
+		"temp_crit":  float64(-273),
+		"temp_max":   int64(255),
+		"temp_min":   math.NaN(),
+		"temp_alarm": uint64(0),
+		"fan_input":  float64(-1),
+	}
+	assert.True(t, f.Apply("sensors", fields, nil))
+	assert.Equal(t, map[string]interface{}{
+		"temp_input": float64(42.5),
+		"temp_alarm": uint64(0),
+		"fan_input":  float64(-1),
+	}, fields)
+}
+
+func TestFilter_FieldValueNaN(t *testing.T) {
+	f := Filter{
+		FieldValue: []FieldValueFilter{
+			{DropNaN: true},
+		},
+	}
+	require.NoError(t, f.Compile())
+
+	fields := map[string]interface{}{
+		"nan":   math.NaN(),
+		"inf":   math.Inf(-1),
+		"value": float32(1),
+		"str":   "NaN",
+	}
+	assert.True(t, f.Apply("m", fields, nil))
+	assert.Equal(t, map[string]interface{}{
+		"inf":   math.Inf(-1),
+		"value": float32(1),
+		"str":   "NaN",
+	}, fields)
+}
+
+func TestFilter_FieldValueInf(t *testing.T) {
+	min := 0.0
+	f := Filter{
+		FieldValue: []FieldValueFilter{
+			{Min: &min},
+		},
+	}
+	require.NoError(t, f.Compile())
+
+	fields := map[string]interface{}{
+		"pos": math.Inf(1),
+		"neg": math.Inf(-1),
+	}
+	assert.True(t, f.Apply("m", fields, nil))
+	assert.Equal(t, map[string]interface{}{"pos": math.Inf(1)}, fields)
+}
+
+func TestFilter_FieldValuePattern(t *testing.T) {
+	f := Filter{
+		FieldValue: []FieldValueFilter{
+			{
+				Fields:      []string{"state"},
+				PassPattern: "^(ok|warn)$",
+			},
+			{
+				DropPattern: "^ERR",
+			},
+		},
+	}
+	require.NoError(t, f.Compile())
+
+	fields := map[string]interface{}{"state": "ok", "model": "ERR"}
+	assert.True(t, f.Apply("hddtemp", fields, nil))
+	assert.Equal(t, map[string]interface{}{"state": "ok"}, fields)
+
+	fields = map[string]interface{}{"state": "SLP"}
+	assert.False(t, f.Apply("hddtemp", fields, nil))
+}
+
+func TestFilter_FieldValueInvalid(t *testing.T) {
+	f := Filter{
+		FieldValue: []FieldValueFilter{
+			{DropPattern: "("},
+		},
+	}
+	require.Error(t, f.Compile())
+}
+
 func TestFilter_Empty(t *testing.T) {
 	f := Filter{}
 
@@ -72,6 +72,9 @@ func makemetric(
 	// instead, the filter is applied to metric incoming into the plugin.
 	//   ie, it gets applied in the RunningAggregator.Apply function.
 	if applyFilter {
+		if ok := filter.ShouldMetricPass(measurement, fields, tags, t); !ok {
+			return nil
+		}
 		if ok := filter.Apply(measurement, fields, tags); !ok {
 			return nil
 		}
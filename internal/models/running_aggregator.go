@@ -87,6 +87,10 @@ func (r *RunningAggregator) Add(in telegraf.Metric) bool {
 		fields := in.Fields()
 		tags := in.Tags()
 		t := in.Time()
+		if ok := r.Config.Filter.ShouldMetricPass(name, fields, tags, t); !ok {
+			// aggregator should not apply this metric
+			return false
+		}
 		if ok := r.Config.Filter.Apply(name, fields, tags); !ok {
 			// aggregator should not apply this metric
 			return false
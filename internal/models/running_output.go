@@ -98,6 +98,10 @@ func (ro *RunningOutput) AddMetric(m telegraf.Metric) {
 		tags := m.Tags()
 		fields := m.Fields()
 		t := m.Time()
+		if ok := ro.Config.Filter.ShouldMetricPass(name, fields, tags, t); !ok {
+			ro.MetricsFiltered.Incr(1)
+			return
+		}
 		if ok := ro.Config.Filter.Apply(name, fields, tags); !ok {
 			ro.MetricsFiltered.Incr(1)
 			return
@@ -125,6 +125,31 @@ func TestRunningOutput_PassFilter(t *testing.T) {
 	assert.Len(t, m.Metrics(), 10)
 }
 
+// Test that metrics not matching the MetricPass expression are dropped.
+func TestRunningOutput_MetricPassFilter(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{
+			MetricPass: `name =~ "^metric[0-9]$" && fields.value >= 100`,
+		},
+	}
+	assert.NoError(t, conf.Filter.Compile())
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	for _, metric := range next5 {
+		ro.AddMetric(metric)
+	}
+	ro.AddMetric(testutil.TestMetric(99, "metric1"))
+
+	err := ro.Write()
+	assert.NoError(t, err)
+	assert.Len(t, m.Metrics(), 9)
+}
+
 // Test that tags are properly included
 func TestRunningOutput_TagIncludeNoMatch(t *testing.T) {
 	conf := &OutputConfig{
@@ -29,7 +29,13 @@ func (rp *RunningProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
 	for _, metric := range in {
 		if rp.Config.Filter.IsActive() {
 			// check if the filter should be applied to this metric
-			if ok := rp.Config.Filter.Apply(metric.Name(), metric.Fields(), metric.Tags()); !ok {
+			name, fields, tags := metric.Name(), metric.Fields(), metric.Tags()
+			if ok := rp.Config.Filter.ShouldMetricPass(name, fields, tags, metric.Time()); !ok {
+				// this means filter should not be applied
+				ret = append(ret, metric)
+				continue
+			}
+			if ok := rp.Config.Filter.Apply(name, fields, tags); !ok {
 				// this means filter should not be applied
 				ret = append(ret, metric)
 				continue
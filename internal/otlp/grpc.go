@@ -0,0 +1,201 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Paths and content types of the OTLP transports.
+const (
+	// GRPCExportPath is the path of the Export method of the metrics service.
+	GRPCExportPath  = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	GRPCContentType = "application/grpc"
+
+	// HTTPMetricsPath is the default path of the metrics endpoint of
+	// OTLP/HTTP.
+	HTTPMetricsPath     = "/v1/metrics"
+	ProtobufContentType = "application/x-protobuf"
+)
+
+// Code is a gRPC status code.
+type Code uint32
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+// Status is a gRPC status, it is also the body of the OTLP/HTTP errors as a
+// google.rpc.Status message.
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", s.Code, s.Message)
+}
+
+// Marshal returns the protobuf encoding of the status.
+func (s *Status) Marshal() ([]byte, error) {
+	var e encoder
+	e.varintField(1, uint64(s.Code))
+	e.stringField(2, s.Message)
+	return e.buf, nil
+}
+
+// Unmarshal decodes the protobuf encoding of a status, the details are
+// ignored.
+func (s *Status) Unmarshal(b []byte) error {
+	*s = Status{}
+	d := decoder{buf: b}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			var v uint64
+			v, err = d.varintValue(field, wireType)
+			s.Code = Code(v)
+		case 2:
+			s.Message, err = d.stringValue(field, wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFrame writes a gRPC length-prefixed message, compressed with gzip if
+// compress is true.
+func WriteFrame(w io.Writer, msg []byte, compress bool) error {
+	var flag byte
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(msg); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		msg = buf.Bytes()
+		flag = 1
+	}
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// ReadFrame reads a gRPC length-prefixed message, and decompresses it with
+// gzip if it is compressed. Messages longer than maxSize are rejected.
+// io.EOF is returned if there is no message.
+func ReadFrame(r io.Reader, maxSize int) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("otlp: truncated grpc message")
+		}
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(header[1:])
+	if int64(n) > int64(maxSize) {
+		return nil, fmt.Errorf("otlp: grpc message of %d bytes exceeds the maximum of %d",
+			n, maxSize)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errors.New("otlp: truncated grpc message")
+	}
+
+	switch header[0] {
+	case 0:
+		return msg, nil
+	case 1:
+		zr, err := gzip.NewReader(bytes.NewReader(msg))
+		if err != nil {
+			return nil, err
+		}
+		// limit the decompressed size as well.
+		msg, err = ioutil.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(msg) > maxSize {
+			return nil, fmt.Errorf("otlp: decompressed grpc message exceeds the maximum of %d",
+				maxSize)
+		}
+		return msg, nil
+	}
+	return nil, fmt.Errorf("otlp: invalid grpc compressed flag %d", header[0])
+}
+
+// EncodeGRPCMessage percent-encodes the message of a status for the
+// grpc-message header.
+func EncodeGRPCMessage(msg string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// DecodeGRPCMessage decodes the grpc-message header, invalid escapes are
+// kept as is.
+func DecodeGRPCMessage(msg string) string {
+	if !strings.Contains(msg, "%") {
+		return msg
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if c, err := strconv.ParseUint(msg[i+1:i+3], 16, 8); err == nil {
+				buf.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		buf.WriteByte(msg[i])
+	}
+	return buf.String()
+}
@@ -0,0 +1,76 @@
+package otlp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrame(t *testing.T) {
+	msg := bytes.Repeat([]byte("telegraf"), 100)
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteFrame(&buf, msg, compress))
+		if compress {
+			assert.Equal(t, byte(1), buf.Bytes()[0])
+			assert.True(t, buf.Len() < len(msg))
+		} else {
+			assert.Equal(t, byte(0), buf.Bytes()[0])
+			assert.Equal(t, 5+len(msg), buf.Len())
+		}
+
+		actual, err := ReadFrame(&buf, len(msg))
+		require.NoError(t, err)
+		assert.Equal(t, msg, actual)
+
+		_, err = ReadFrame(&buf, len(msg))
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	msg := bytes.Repeat([]byte("telegraf"), 100)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, msg, false))
+	_, err := ReadFrame(&buf, len(msg)-1)
+	assert.Error(t, err)
+
+	// the size limit applies to the decompressed message
+	buf.Reset()
+	require.NoError(t, WriteFrame(&buf, msg, true))
+	_, err = ReadFrame(&buf, len(msg)-1)
+	assert.Error(t, err)
+
+	buf.Reset()
+	require.NoError(t, WriteFrame(&buf, msg, false))
+	_, err = ReadFrame(bytes.NewReader(buf.Bytes()[:10]), len(msg))
+	assert.Error(t, err)
+
+	_, err = ReadFrame(bytes.NewReader([]byte{2, 0, 0, 0, 0}), len(msg))
+	assert.Error(t, err)
+}
+
+func TestStatus(t *testing.T) {
+	s := &Status{Code: InvalidArgument, Message: "invalid metric"}
+	b, err := s.Marshal()
+	require.NoError(t, err)
+
+	var decoded Status
+	require.NoError(t, decoded.Unmarshal(b))
+	assert.Equal(t, s, &decoded)
+	assert.Equal(t, "rpc error: code = 3 desc = invalid metric", decoded.Error())
+}
+
+func TestGRPCMessage(t *testing.T) {
+	msg := "invalid 100% \"métric\"\n"
+	encoded := EncodeGRPCMessage(msg)
+	assert.Equal(t, "invalid 100%25 \"m%C3%A9tric\"%0A", encoded)
+	assert.Equal(t, msg, DecodeGRPCMessage(encoded))
+
+	assert.Equal(t, "100%", DecodeGRPCMessage("100%"))
+	assert.Equal(t, "%zz", DecodeGRPCMessage("%zz"))
+}
@@ -0,0 +1,991 @@
+// Package otlp contains the metrics messages of the OpenTelemetry protocol
+// (OTLP), as defined in opentelemetry/proto/metrics/v1/metrics.proto and
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto.
+//
+// The messages are encoded by hand rather than with the struct tags of the
+// protobuf package, since the protocol relies on oneof fields for the values
+// of the data points and of the attributes.
+package otlp
+
+import (
+	"fmt"
+	"math"
+)
+
+// AggregationTemporality defines how the values of a sum or of a histogram
+// are aggregated over time.
+type AggregationTemporality int32
+
+const (
+	AggregationTemporalityUnspecified AggregationTemporality = 0
+	AggregationTemporalityDelta       AggregationTemporality = 1
+	AggregationTemporalityCumulative  AggregationTemporality = 2
+)
+
+// ExportMetricsServiceRequest is the request of the Export method of the
+// metrics service, and the body of an OTLP/HTTP request.
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []*ResourceMetrics
+}
+
+// ExportMetricsServiceResponse is the response of the Export method of the
+// metrics service.
+type ExportMetricsServiceResponse struct {
+	// PartialSuccess is set when the server rejected some of the data points.
+	PartialSuccess *ExportMetricsPartialSuccess
+}
+
+type ExportMetricsPartialSuccess struct {
+	RejectedDataPoints int64
+	ErrorMessage       string
+}
+
+// ResourceMetrics are the metrics of a resource, ie a host or a service.
+type ResourceMetrics struct {
+	Resource     *Resource
+	ScopeMetrics []*ScopeMetrics
+	SchemaURL    string
+}
+
+type Resource struct {
+	Attributes []*KeyValue
+}
+
+// ScopeMetrics are the metrics produced by an instrumentation scope.
+type ScopeMetrics struct {
+	Scope     *InstrumentationScope
+	Metrics   []*Metric
+	SchemaURL string
+}
+
+type InstrumentationScope struct {
+	Name    string
+	Version string
+}
+
+// Metric holds the data points of a metric, exactly one of Gauge, Sum,
+// Histogram or Summary is set.
+type Metric struct {
+	Name        string
+	Description string
+	Unit        string
+
+	Gauge     *Gauge
+	Sum       *Sum
+	Histogram *Histogram
+	Summary   *Summary
+}
+
+type Gauge struct {
+	DataPoints []*NumberDataPoint
+}
+
+type Sum struct {
+	DataPoints             []*NumberDataPoint
+	AggregationTemporality AggregationTemporality
+	IsMonotonic            bool
+}
+
+type Histogram struct {
+	DataPoints             []*HistogramDataPoint
+	AggregationTemporality AggregationTemporality
+}
+
+type Summary struct {
+	DataPoints []*SummaryDataPoint
+}
+
+type NumberDataPoint struct {
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	// Value is either a float64 or an int64.
+	Value interface{}
+}
+
+type HistogramDataPoint struct {
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	// Sum is optional, nil when not set.
+	Sum *float64
+	// BucketCounts has one more element than ExplicitBounds, the last bucket
+	// counting the values above the last bound.
+	BucketCounts   []uint64
+	ExplicitBounds []float64
+}
+
+type SummaryDataPoint struct {
+	Attributes        []*KeyValue
+	StartTimeUnixNano uint64
+	TimeUnixNano      uint64
+	Count             uint64
+	Sum               float64
+	QuantileValues    []*ValueAtQuantile
+}
+
+type ValueAtQuantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// KeyValue is an attribute of a resource, a scope or a data point.
+type KeyValue struct {
+	Key string
+	// Value is one of string, bool, int64, float64, []byte, []interface{} for
+	// an array or []*KeyValue for a key-value list.
+	Value interface{}
+}
+
+// Marshal returns the protobuf encoding of the request.
+func (r *ExportMetricsServiceRequest) Marshal() ([]byte, error) {
+	var e encoder
+	for _, rm := range r.ResourceMetrics {
+		if err := rm.validate(); err != nil {
+			return nil, err
+		}
+		e.messageField(1, rm.marshal)
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the protobuf encoding of a request.
+func (r *ExportMetricsServiceRequest) Unmarshal(b []byte) error {
+	*r = ExportMetricsServiceRequest{}
+	d := decoder{buf: b}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			rm := &ResourceMetrics{}
+			if err := d.message(field, wireType, rm.unmarshal); err != nil {
+				return err
+			}
+			r.ResourceMetrics = append(r.ResourceMetrics, rm)
+		default:
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Marshal returns the protobuf encoding of the response.
+func (r *ExportMetricsServiceResponse) Marshal() ([]byte, error) {
+	var e encoder
+	if p := r.PartialSuccess; p != nil {
+		e.messageField(1, func(e *encoder) {
+			e.varintField(1, uint64(p.RejectedDataPoints))
+			e.stringField(2, p.ErrorMessage)
+		})
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes the protobuf encoding of a response.
+func (r *ExportMetricsServiceResponse) Unmarshal(b []byte) error {
+	*r = ExportMetricsServiceResponse{}
+	d := decoder{buf: b}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			p := &ExportMetricsPartialSuccess{}
+			if err := d.message(field, wireType, p.unmarshal); err != nil {
+				return err
+			}
+			r.PartialSuccess = p
+		default:
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ExportMetricsPartialSuccess) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			v, err := d.varintValue(field, wireType)
+			if err != nil {
+				return err
+			}
+			p.RejectedDataPoints = int64(v)
+		case 2:
+			if p.ErrorMessage, err = d.stringValue(field, wireType); err != nil {
+				return err
+			}
+		default:
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rm *ResourceMetrics) validate() error {
+	if rm.Resource != nil {
+		for _, kv := range rm.Resource.Attributes {
+			if err := validateValue(kv.Value); err != nil {
+				return fmt.Errorf("otlp: resource attribute %q: %s", kv.Key, err)
+			}
+		}
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if err := m.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rm *ResourceMetrics) marshal(e *encoder) {
+	if rm.Resource != nil {
+		e.messageField(1, func(e *encoder) {
+			marshalAttributes(e, 1, rm.Resource.Attributes)
+		})
+	}
+	for _, sm := range rm.ScopeMetrics {
+		e.messageField(2, sm.marshal)
+	}
+	e.stringField(3, rm.SchemaURL)
+}
+
+func (rm *ResourceMetrics) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			r := &Resource{}
+			err = d.message(field, wireType, func(d *decoder) error {
+				return unmarshalAttributes(d, 1, &r.Attributes)
+			})
+			rm.Resource = r
+		case 2:
+			sm := &ScopeMetrics{}
+			err = d.message(field, wireType, sm.unmarshal)
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+		case 3:
+			rm.SchemaURL, err = d.stringValue(field, wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sm *ScopeMetrics) marshal(e *encoder) {
+	if s := sm.Scope; s != nil {
+		e.messageField(1, func(e *encoder) {
+			e.stringField(1, s.Name)
+			e.stringField(2, s.Version)
+		})
+	}
+	for _, m := range sm.Metrics {
+		e.messageField(2, m.marshal)
+	}
+	e.stringField(3, sm.SchemaURL)
+}
+
+func (sm *ScopeMetrics) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			s := &InstrumentationScope{}
+			err = d.message(field, wireType, s.unmarshal)
+			sm.Scope = s
+		case 2:
+			m := &Metric{}
+			err = d.message(field, wireType, m.unmarshal)
+			sm.Metrics = append(sm.Metrics, m)
+		case 3:
+			sm.SchemaURL, err = d.stringValue(field, wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *InstrumentationScope) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			s.Name, err = d.stringValue(field, wireType)
+		case 2:
+			s.Version, err = d.stringValue(field, wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Metric) validate() error {
+	n := 0
+	for _, set := range []bool{m.Gauge != nil, m.Sum != nil, m.Histogram != nil, m.Summary != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("otlp: metric %q must have exactly one data type", m.Name)
+	}
+
+	var points []*NumberDataPoint
+	if m.Gauge != nil {
+		points = m.Gauge.DataPoints
+	} else if m.Sum != nil {
+		points = m.Sum.DataPoints
+	}
+	for _, p := range points {
+		switch p.Value.(type) {
+		case float64, int64:
+		default:
+			return fmt.Errorf("otlp: metric %q has a value of unsupported type %T",
+				m.Name, p.Value)
+		}
+	}
+	for _, kv := range m.attributes() {
+		if err := validateValue(kv.Value); err != nil {
+			return fmt.Errorf("otlp: attribute %q of metric %q: %s", kv.Key, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// attributes returns the attributes of all the data points of the metric.
+func (m *Metric) attributes() []*KeyValue {
+	var attrs []*KeyValue
+	switch {
+	case m.Gauge != nil:
+		for _, p := range m.Gauge.DataPoints {
+			attrs = append(attrs, p.Attributes...)
+		}
+	case m.Sum != nil:
+		for _, p := range m.Sum.DataPoints {
+			attrs = append(attrs, p.Attributes...)
+		}
+	case m.Histogram != nil:
+		for _, p := range m.Histogram.DataPoints {
+			attrs = append(attrs, p.Attributes...)
+		}
+	case m.Summary != nil:
+		for _, p := range m.Summary.DataPoints {
+			attrs = append(attrs, p.Attributes...)
+		}
+	}
+	return attrs
+}
+
+func (m *Metric) marshal(e *encoder) {
+	e.stringField(1, m.Name)
+	e.stringField(2, m.Description)
+	e.stringField(3, m.Unit)
+	switch {
+	case m.Gauge != nil:
+		e.messageField(5, func(e *encoder) {
+			for _, p := range m.Gauge.DataPoints {
+				e.messageField(1, p.marshal)
+			}
+		})
+	case m.Sum != nil:
+		e.messageField(7, func(e *encoder) {
+			for _, p := range m.Sum.DataPoints {
+				e.messageField(1, p.marshal)
+			}
+			e.varintField(2, uint64(m.Sum.AggregationTemporality))
+			e.boolField(3, m.Sum.IsMonotonic)
+		})
+	case m.Histogram != nil:
+		e.messageField(9, func(e *encoder) {
+			for _, p := range m.Histogram.DataPoints {
+				e.messageField(1, p.marshal)
+			}
+			e.varintField(2, uint64(m.Histogram.AggregationTemporality))
+		})
+	case m.Summary != nil:
+		e.messageField(11, func(e *encoder) {
+			for _, p := range m.Summary.DataPoints {
+				e.messageField(1, p.marshal)
+			}
+		})
+	}
+}
+
+func (m *Metric) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			m.Name, err = d.stringValue(field, wireType)
+		case 2:
+			m.Description, err = d.stringValue(field, wireType)
+		case 3:
+			m.Unit, err = d.stringValue(field, wireType)
+		case 5:
+			g := &Gauge{}
+			err = d.message(field, wireType, func(d *decoder) error {
+				return unmarshalNumberDataPoints(d, &g.DataPoints, nil)
+			})
+			m.Gauge = g
+		case 7:
+			s := &Sum{}
+			err = d.message(field, wireType, func(d *decoder) error {
+				return unmarshalNumberDataPoints(d, &s.DataPoints,
+					func(d *decoder, field, wireType int) (bool, error) {
+						switch field {
+						case 2:
+							v, err := d.varintValue(field, wireType)
+							s.AggregationTemporality = AggregationTemporality(v)
+							return true, err
+						case 3:
+							v, err := d.varintValue(field, wireType)
+							s.IsMonotonic = v != 0
+							return true, err
+						}
+						return false, nil
+					})
+			})
+			m.Sum = s
+		case 9:
+			h := &Histogram{}
+			err = d.message(field, wireType, h.unmarshal)
+			m.Histogram = h
+		case 11:
+			s := &Summary{}
+			err = d.message(field, wireType, s.unmarshal)
+			m.Summary = s
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalNumberDataPoints decodes the data points of a gauge or of a sum,
+// the other fields are decoded by the optional other function.
+func unmarshalNumberDataPoints(
+	d *decoder,
+	points *[]*NumberDataPoint,
+	other func(d *decoder, field, wireType int) (bool, error),
+) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		if field == 1 {
+			p := &NumberDataPoint{}
+			if err := d.message(field, wireType, p.unmarshal); err != nil {
+				return err
+			}
+			*points = append(*points, p)
+			continue
+		}
+		if other != nil {
+			ok, err := other(d, field, wireType)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+		}
+		if err := d.skip(wireType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *NumberDataPoint) marshal(e *encoder) {
+	e.fixed64Field(2, p.StartTimeUnixNano)
+	e.fixed64Field(3, p.TimeUnixNano)
+	// the value is a oneof, it is written even when zero.
+	switch v := p.Value.(type) {
+	case float64:
+		e.tag(4, wireFixed64)
+		e.fixed64(math.Float64bits(v))
+	case int64:
+		e.tag(6, wireFixed64)
+		e.fixed64(uint64(v))
+	}
+	marshalAttributes(e, 7, p.Attributes)
+}
+
+func (p *NumberDataPoint) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 2:
+			p.StartTimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 3:
+			p.TimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 4:
+			var v uint64
+			v, err = d.fixed64Value(field, wireType)
+			p.Value = math.Float64frombits(v)
+		case 6:
+			var v uint64
+			v, err = d.fixed64Value(field, wireType)
+			p.Value = int64(v)
+		case 7:
+			err = unmarshalAttribute(d, field, wireType, &p.Attributes)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Histogram) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			p := &HistogramDataPoint{}
+			err = d.message(field, wireType, p.unmarshal)
+			h.DataPoints = append(h.DataPoints, p)
+		case 2:
+			var v uint64
+			v, err = d.varintValue(field, wireType)
+			h.AggregationTemporality = AggregationTemporality(v)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *HistogramDataPoint) marshal(e *encoder) {
+	e.fixed64Field(2, p.StartTimeUnixNano)
+	e.fixed64Field(3, p.TimeUnixNano)
+	e.fixed64Field(4, p.Count)
+	if p.Sum != nil {
+		// optional field, written even when zero.
+		e.tag(5, wireFixed64)
+		e.fixed64(math.Float64bits(*p.Sum))
+	}
+	e.packedFixed64Field(6, p.BucketCounts)
+	e.packedDoubleField(7, p.ExplicitBounds)
+	marshalAttributes(e, 9, p.Attributes)
+}
+
+func (p *HistogramDataPoint) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 2:
+			p.StartTimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 3:
+			p.TimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 4:
+			p.Count, err = d.fixed64Value(field, wireType)
+		case 5:
+			var v uint64
+			v, err = d.fixed64Value(field, wireType)
+			sum := math.Float64frombits(v)
+			p.Sum = &sum
+		case 6:
+			p.BucketCounts, err = d.repeatedFixed64(field, wireType, p.BucketCounts)
+		case 7:
+			var bits []uint64
+			bits, err = d.repeatedFixed64(field, wireType, nil)
+			for _, b := range bits {
+				p.ExplicitBounds = append(p.ExplicitBounds, math.Float64frombits(b))
+			}
+		case 9:
+			err = unmarshalAttribute(d, field, wireType, &p.Attributes)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Summary) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			p := &SummaryDataPoint{}
+			err = d.message(field, wireType, p.unmarshal)
+			s.DataPoints = append(s.DataPoints, p)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *SummaryDataPoint) marshal(e *encoder) {
+	e.fixed64Field(2, p.StartTimeUnixNano)
+	e.fixed64Field(3, p.TimeUnixNano)
+	e.fixed64Field(4, p.Count)
+	e.doubleField(5, p.Sum)
+	for _, q := range p.QuantileValues {
+		e.messageField(6, func(e *encoder) {
+			e.doubleField(1, q.Quantile)
+			e.doubleField(2, q.Value)
+		})
+	}
+	marshalAttributes(e, 7, p.Attributes)
+}
+
+func (p *SummaryDataPoint) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 2:
+			p.StartTimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 3:
+			p.TimeUnixNano, err = d.fixed64Value(field, wireType)
+		case 4:
+			p.Count, err = d.fixed64Value(field, wireType)
+		case 5:
+			var v uint64
+			v, err = d.fixed64Value(field, wireType)
+			p.Sum = math.Float64frombits(v)
+		case 6:
+			q := &ValueAtQuantile{}
+			err = d.message(field, wireType, q.unmarshal)
+			p.QuantileValues = append(p.QuantileValues, q)
+		case 7:
+			err = unmarshalAttribute(d, field, wireType, &p.Attributes)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *ValueAtQuantile) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		var v uint64
+		switch field {
+		case 1:
+			v, err = d.fixed64Value(field, wireType)
+			q.Quantile = math.Float64frombits(v)
+		case 2:
+			v, err = d.fixed64Value(field, wireType)
+			q.Value = math.Float64frombits(v)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(v interface{}) error {
+	switch v := v.(type) {
+	case string, bool, int64, float64, []byte:
+	case []interface{}:
+		for _, item := range v {
+			if err := validateValue(item); err != nil {
+				return err
+			}
+		}
+	case []*KeyValue:
+		for _, kv := range v {
+			if err := validateValue(kv.Value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+func marshalAttributes(e *encoder, field int, attrs []*KeyValue) {
+	for _, kv := range attrs {
+		e.messageField(field, kv.marshal)
+	}
+}
+
+func (kv *KeyValue) marshal(e *encoder) {
+	e.stringField(1, kv.Key)
+	e.messageField(2, func(e *encoder) {
+		marshalAnyValue(e, kv.Value)
+	})
+}
+
+// marshalAnyValue encodes the fields of an AnyValue message, the value is a
+// oneof and it is written even when zero.
+func marshalAnyValue(e *encoder, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		e.tag(1, wireBytes)
+		e.varint(uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	case bool:
+		e.tag(2, wireVarint)
+		if v {
+			e.varint(1)
+		} else {
+			e.varint(0)
+		}
+	case int64:
+		e.tag(3, wireVarint)
+		e.varint(uint64(v))
+	case float64:
+		e.tag(4, wireFixed64)
+		e.fixed64(math.Float64bits(v))
+	case []interface{}:
+		e.messageField(5, func(e *encoder) {
+			for _, item := range v {
+				e.messageField(1, func(e *encoder) {
+					marshalAnyValue(e, item)
+				})
+			}
+		})
+	case []*KeyValue:
+		e.messageField(6, func(e *encoder) {
+			marshalAttributes(e, 1, v)
+		})
+	case []byte:
+		e.tag(7, wireBytes)
+		e.varint(uint64(len(v)))
+		e.buf = append(e.buf, v...)
+	}
+}
+
+func unmarshalAttributes(d *decoder, attrField int, attrs *[]*KeyValue) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		if field == attrField {
+			err = unmarshalAttribute(d, field, wireType, attrs)
+		} else {
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalAttribute(d *decoder, field, wireType int, attrs *[]*KeyValue) error {
+	kv := &KeyValue{}
+	if err := d.message(field, wireType, kv.unmarshal); err != nil {
+		return err
+	}
+	*attrs = append(*attrs, kv)
+	return nil
+}
+
+func (kv *KeyValue) unmarshal(d *decoder) error {
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			kv.Key, err = d.stringValue(field, wireType)
+		case 2:
+			err = d.message(field, wireType, func(d *decoder) error {
+				var err error
+				kv.Value, err = unmarshalAnyValue(d)
+				return err
+			})
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalAnyValue(d *decoder) (interface{}, error) {
+	var value interface{}
+	for !d.done() {
+		field, wireType, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1:
+			value, err = d.stringValue(field, wireType)
+		case 2:
+			var v uint64
+			v, err = d.varintValue(field, wireType)
+			value = v != 0
+		case 3:
+			var v uint64
+			v, err = d.varintValue(field, wireType)
+			value = int64(v)
+		case 4:
+			var v uint64
+			v, err = d.fixed64Value(field, wireType)
+			value = math.Float64frombits(v)
+		case 5:
+			items := []interface{}{}
+			err = d.message(field, wireType, func(d *decoder) error {
+				for !d.done() {
+					field, wireType, err := d.next()
+					if err != nil {
+						return err
+					}
+					if field != 1 {
+						if err := d.skip(wireType); err != nil {
+							return err
+						}
+						continue
+					}
+					var item interface{}
+					err = d.message(field, wireType, func(d *decoder) error {
+						var err error
+						item, err = unmarshalAnyValue(d)
+						return err
+					})
+					if err != nil {
+						return err
+					}
+					items = append(items, item)
+				}
+				return nil
+			})
+			value = items
+		case 6:
+			kvs := []*KeyValue{}
+			err = d.message(field, wireType, func(d *decoder) error {
+				return unmarshalAttributes(d, 1, &kvs)
+			})
+			value = kvs
+		case 7:
+			var b []byte
+			b, err = d.bytesValue(field, wireType)
+			value = append([]byte{}, b...)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// message decodes a nested message with the unmarshal function.
+func (d *decoder) message(field, wireType int, unmarshal func(*decoder) error) error {
+	b, err := d.bytesValue(field, wireType)
+	if err != nil {
+		return err
+	}
+	return unmarshal(&decoder{buf: b})
+}
+
+func (d *decoder) bytesValue(field, wireType int) ([]byte, error) {
+	if err := check(field, wireType, wireBytes); err != nil {
+		return nil, err
+	}
+	return d.bytes()
+}
+
+func (d *decoder) stringValue(field, wireType int) (string, error) {
+	if err := check(field, wireType, wireBytes); err != nil {
+		return "", err
+	}
+	return d.string()
+}
+
+func (d *decoder) varintValue(field, wireType int) (uint64, error) {
+	if err := check(field, wireType, wireVarint); err != nil {
+		return 0, err
+	}
+	return d.varint()
+}
+
+func (d *decoder) fixed64Value(field, wireType int) (uint64, error) {
+	if err := check(field, wireType, wireFixed64); err != nil {
+		return 0, err
+	}
+	return d.fixed64()
+}
@@ -0,0 +1,183 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumberDataPointWireFormat(t *testing.T) {
+	var e encoder
+	p := &NumberDataPoint{TimeUnixNano: 2, Value: 0.0}
+	p.marshal(&e)
+	assert.Equal(t, []byte{
+		0x19, 2, 0, 0, 0, 0, 0, 0, 0, // time_unix_nano, fixed64 field 3
+		0x21, 0, 0, 0, 0, 0, 0, 0, 0, // as_double, fixed64 field 4, written when zero
+	}, e.buf)
+
+	e = encoder{}
+	p = &NumberDataPoint{Value: int64(-1)}
+	p.marshal(&e)
+	assert.Equal(t, []byte{
+		0x31, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // as_int, sfixed64 field 6
+	}, e.buf)
+}
+
+func TestRequestMarshalUnmarshal(t *testing.T) {
+	sum := 12.5
+	req := &ExportMetricsServiceRequest{
+		ResourceMetrics: []*ResourceMetrics{
+			{
+				Resource: &Resource{
+					Attributes: []*KeyValue{
+						{Key: "host", Value: "localhost"},
+						{Key: "empty", Value: ""},
+						{Key: "enabled", Value: false},
+						{Key: "count", Value: int64(-3)},
+						{Key: "list", Value: []interface{}{"a", 1.5}},
+						{Key: "map", Value: []*KeyValue{{Key: "k", Value: []byte("v")}}},
+					},
+				},
+				ScopeMetrics: []*ScopeMetrics{
+					{
+						Scope: &InstrumentationScope{Name: "telegraf", Version: "1.3"},
+						Metrics: []*Metric{
+							{
+								Name: "cpu_usage_idle",
+								Unit: "%",
+								Gauge: &Gauge{DataPoints: []*NumberDataPoint{
+									{
+										Attributes:   []*KeyValue{{Key: "cpu", Value: "cpu0"}},
+										TimeUnixNano: 1487067700000000000,
+										Value:        98.5,
+									},
+									{TimeUnixNano: 1487067710000000000, Value: 0.0},
+								}},
+							},
+							{
+								Name: "net_bytes_recv",
+								Sum: &Sum{
+									DataPoints: []*NumberDataPoint{
+										{StartTimeUnixNano: 1, TimeUnixNano: 2, Value: int64(0)},
+									},
+									AggregationTemporality: AggregationTemporalityCumulative,
+									IsMonotonic:            true,
+								},
+							},
+							{
+								Name: "http_request_duration_seconds",
+								Histogram: &Histogram{
+									DataPoints: []*HistogramDataPoint{
+										{
+											TimeUnixNano:   2,
+											Count:          10,
+											Sum:            &sum,
+											BucketCounts:   []uint64{1, 2, 7},
+											ExplicitBounds: []float64{0.1, 0.5},
+										},
+									},
+									AggregationTemporality: AggregationTemporalityCumulative,
+								},
+							},
+							{
+								Name: "rpc_duration_seconds",
+								Summary: &Summary{DataPoints: []*SummaryDataPoint{
+									{
+										TimeUnixNano: 2,
+										Count:        3,
+										Sum:          4.5,
+										QuantileValues: []*ValueAtQuantile{
+											{Quantile: 0.5, Value: 1.5},
+											{Quantile: 0.99, Value: 2},
+										},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	b, err := req.Marshal()
+	require.NoError(t, err)
+
+	var decoded ExportMetricsServiceRequest
+	require.NoError(t, decoded.Unmarshal(b))
+	assert.Equal(t, req, &decoded)
+}
+
+func TestResponseMarshalUnmarshal(t *testing.T) {
+	resp := &ExportMetricsServiceResponse{
+		PartialSuccess: &ExportMetricsPartialSuccess{
+			RejectedDataPoints: 2,
+			ErrorMessage:       "invalid data points",
+		},
+	}
+	b, err := resp.Marshal()
+	require.NoError(t, err)
+
+	var decoded ExportMetricsServiceResponse
+	require.NoError(t, decoded.Unmarshal(b))
+	assert.Equal(t, resp, &decoded)
+
+	// an empty response is a success
+	require.NoError(t, decoded.Unmarshal(nil))
+	assert.Nil(t, decoded.PartialSuccess)
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	var e encoder
+	e.varintField(15, 42)
+	e.fixed64Field(16, 42)
+	e.stringField(17, "unknown")
+	e.tag(18, wireFixed32)
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	e.messageField(1, func(e *encoder) {
+		e.stringField(3, "https://opentelemetry.io/schemas/1.9.0")
+	})
+
+	var req ExportMetricsServiceRequest
+	require.NoError(t, req.Unmarshal(e.buf))
+	require.Len(t, req.ResourceMetrics, 1)
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.9.0", req.ResourceMetrics[0].SchemaURL)
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	var req ExportMetricsServiceRequest
+	// truncated resource_metrics
+	assert.Error(t, req.Unmarshal([]byte{0x0a, 0x05}))
+	// invalid wire type of resource_metrics
+	assert.Error(t, req.Unmarshal([]byte{0x09, 0, 0, 0, 0, 0, 0, 0, 0}))
+	// truncated varint
+	assert.Error(t, req.Unmarshal([]byte{0x80}))
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	req := &ExportMetricsServiceRequest{
+		ResourceMetrics: []*ResourceMetrics{{
+			ScopeMetrics: []*ScopeMetrics{{
+				Metrics: []*Metric{{Name: "cpu"}},
+			}},
+		}},
+	}
+	_, err := req.Marshal()
+	assert.Error(t, err)
+
+	req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge = &Gauge{
+		DataPoints: []*NumberDataPoint{{Value: "idle"}},
+	}
+	_, err = req.Marshal()
+	assert.Error(t, err)
+
+	req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge = &Gauge{
+		DataPoints: []*NumberDataPoint{{
+			Value:      1.0,
+			Attributes: []*KeyValue{{Key: "cpu", Value: 0}},
+		}},
+	}
+	_, err = req.Marshal()
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("otlp: truncated message")
+
+// encoder appends the protobuf encoding of the fields to buf. Fields with a
+// zero value are omitted, as in proto3.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) tag(field int, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) fixed64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) varintField(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(v)
+}
+
+func (e *encoder) boolField(field int, v bool) {
+	if !v {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(1)
+}
+
+func (e *encoder) fixed64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireFixed64)
+	e.fixed64(v)
+}
+
+func (e *encoder) doubleField(field int, v float64) {
+	e.fixed64Field(field, math.Float64bits(v))
+}
+
+func (e *encoder) bytesField(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) stringField(field int, s string) {
+	if len(s) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// messageField encodes a nested message with the marshal function. The field
+// is always written, so that an empty message is distinct from a missing one.
+func (e *encoder) messageField(field int, marshal func(*encoder)) {
+	var sub encoder
+	marshal(&sub)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(sub.buf)))
+	e.buf = append(e.buf, sub.buf...)
+}
+
+func (e *encoder) packedFixed64Field(field int, values []uint64) {
+	if len(values) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(values)))
+	for _, v := range values {
+		e.fixed64(v)
+	}
+}
+
+func (e *encoder) packedDoubleField(field int, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.varint(uint64(8 * len(values)))
+	for _, v := range values {
+		e.fixed64(math.Float64bits(v))
+	}
+}
+
+// decoder reads the fields of a protobuf message.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) done() bool {
+	return d.pos >= len(d.buf)
+}
+
+// next returns the number and the wire type of the next field.
+func (d *decoder) next() (int, int, error) {
+	key, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	field := int(key >> 3)
+	if field <= 0 {
+		return 0, 0, fmt.Errorf("otlp: invalid field number %d", field)
+	}
+	return field, int(key & 0x07), nil
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.pos >= len(d.buf) {
+			return 0, errTruncated
+		}
+		b := d.buf[d.pos]
+		d.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("otlp: varint overflow")
+}
+
+func (d *decoder) fixed64() (uint64, error) {
+	if len(d.buf)-d.pos < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(d.buf[d.pos:])
+	d.pos += 8
+	return v, nil
+}
+
+func (d *decoder) fixed32() (uint32, error) {
+	if len(d.buf)-d.pos < 4 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint32(d.buf[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+// bytes returns the content of a length delimited field, without copying it.
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errTruncated
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+// skip skips the value of a field of an unknown number.
+func (d *decoder) skip(wireType int) error {
+	var err error
+	switch wireType {
+	case wireVarint:
+		_, err = d.varint()
+	case wireFixed64:
+		_, err = d.fixed64()
+	case wireBytes:
+		_, err = d.bytes()
+	case wireFixed32:
+		_, err = d.fixed32()
+	default:
+		err = fmt.Errorf("otlp: unsupported wire type %d", wireType)
+	}
+	return err
+}
+
+// check returns an error if the wire type of a known field is not the
+// expected one.
+func check(field, wireType, expected int) error {
+	if wireType != expected {
+		return fmt.Errorf("otlp: invalid wire type %d for field %d", wireType, field)
+	}
+	return nil
+}
+
+// repeatedFixed64 decodes a repeated fixed64 field, in the packed or in the
+// unpacked encoding.
+func (d *decoder) repeatedFixed64(field, wireType int, values []uint64) ([]uint64, error) {
+	switch wireType {
+	case wireFixed64:
+		v, err := d.fixed64()
+		if err != nil {
+			return nil, err
+		}
+		return append(values, v), nil
+	case wireBytes:
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(b)%8 != 0 {
+			return nil, fmt.Errorf("otlp: invalid packed length for field %d", field)
+		}
+		for i := 0; i < len(b); i += 8 {
+			values = append(values, binary.LittleEndian.Uint64(b[i:]))
+		}
+		return values, nil
+	}
+	return nil, check(field, wireType, wireBytes)
+}
@@ -0,0 +1,115 @@
+// Package prompb contains the messages of the Prometheus remote storage
+// protocol, as defined in prometheus/prompb/remote.proto and types.proto.
+//
+// A remote write request is a snappy compressed, protobuf encoded
+// WriteRequest sent in the body of an HTTP POST.
+package prompb
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// MIME types and headers of a remote write request.
+const (
+	ContentType     = "application/x-protobuf"
+	ContentEncoding = "snappy"
+	VersionHeader   = "X-Prometheus-Remote-Write-Version"
+	Version         = "0.1.0"
+)
+
+// MetricNameLabel is the name of the label holding the metric name.
+const MetricNameLabel = "__name__"
+
+// LabelPair is a Prometheus label, as found in the exposition formats and in
+// the remote write protocol.
+type LabelPair interface {
+	GetName() string
+	GetValue() string
+}
+
+// AddLabelTag adds the label to the tags of a metric. The metric name label
+// is skipped, as it is the measurement name. It is shared by the prometheus
+// input and the prometheus_remote_write_listener input.
+func AddLabelTag(tags map[string]string, label LabelPair) {
+	if label.GetName() == MetricNameLabel {
+		return
+	}
+	tags[label.GetName()] = label.GetValue()
+}
+
+// StaleNaN is the bit pattern of the NaN value of the sample marking the end
+// of a series.
+const StaleNaN uint64 = 0x7ff0000000000002
+
+type WriteRequest struct {
+	Timeseries []*TimeSeries `protobuf:"bytes,1,rep,name=timeseries" json:"timeseries,omitempty"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+
+type TimeSeries struct {
+	Labels  []*Label  `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+	Samples []*Sample `protobuf:"bytes,2,rep,name=samples" json:"samples,omitempty"`
+}
+
+func (m *TimeSeries) Reset()         { *m = TimeSeries{} }
+func (m *TimeSeries) String() string { return proto.CompactTextString(m) }
+func (*TimeSeries) ProtoMessage()    {}
+
+type Label struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Label) Reset()         { *m = Label{} }
+func (m *Label) String() string { return proto.CompactTextString(m) }
+func (*Label) ProtoMessage()    {}
+
+func (m *Label) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Label) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type Sample struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+	// Timestamp in milliseconds since the epoch.
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (m *Sample) Reset()         { *m = Sample{} }
+func (m *Sample) String() string { return proto.CompactTextString(m) }
+func (*Sample) ProtoMessage()    {}
+
+// Encode returns the body of a remote write request.
+func Encode(req *WriteRequest) ([]byte, error) {
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, b), nil
+}
+
+// Decode parses the body of a remote write request.
+func Decode(body []byte) (*WriteRequest, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+	req := &WriteRequest{}
+	if err := proto.Unmarshal(b, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
@@ -0,0 +1,61 @@
+package prompb
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleWireFormat(t *testing.T) {
+	b, err := proto.Marshal(&Sample{Value: 1, Timestamp: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, // value, fixed64 field 1
+		0x10, 0x02, // timestamp, varint field 2
+	}, b)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	req := &WriteRequest{
+		Timeseries: []*TimeSeries{
+			{
+				Labels: []*Label{
+					{Name: MetricNameLabel, Value: "cpu_usage_idle"},
+					{Name: "host", Value: "localhost"},
+				},
+				Samples: []*Sample{
+					{Value: 98.5, Timestamp: 1487067700000},
+					{Value: 97.5, Timestamp: 1487067710000},
+				},
+			},
+		},
+	}
+
+	body, err := Encode(req)
+	require.NoError(t, err)
+	_, err = snappy.Decode(nil, body)
+	require.NoError(t, err)
+
+	decoded, err := Decode(body)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(req, decoded))
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte("not snappy"))
+	assert.Error(t, err)
+
+	_, err = Decode(snappy.Encode(nil, []byte{0x0a, 0x05}))
+	assert.Error(t, err)
+}
+
+func TestAddLabelTag(t *testing.T) {
+	tags := map[string]string{}
+	AddLabelTag(tags, &Label{Name: MetricNameLabel, Value: "up"})
+	AddLabelTag(tags, &Label{Name: "job", Value: "prometheus"})
+	assert.Equal(t, map[string]string{"job": "prometheus"}, tags)
+}
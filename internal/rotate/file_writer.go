@@ -0,0 +1,321 @@
+package rotate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// archives are named after the file and the time of their rotation, ie:
+	// metrics.2017-03-01T12-00-00.000000000.out
+	archiveTimeFormat = "2006-01-02T15-04-05.000000000"
+
+	// how often the file is checked for an external rotation.
+	checkInterval = time.Second
+)
+
+var errClosed = errors.New("file writer is closed")
+
+// FileWriter writes to a file, which is rotated once it is older than the
+// rotation interval or would grow over the maximum size. Rotated files are
+// renamed after the time of their rotation, optionally compressed in the
+// background, and the oldest archives are removed to keep at most maxArchives
+// of them.
+//
+// The file is also reopened if it was moved or removed by another process,
+// such as logrotate.
+type FileWriter struct {
+	filename    string
+	interval    time.Duration
+	maxSize     int64
+	maxArchives int
+	compress    bool
+
+	// the file is nil when it could not be reopened after a rotation, it is
+	// reopened on the next write.
+	file      *os.File
+	closed    bool
+	size      int64
+	expire    time.Time
+	lastCheck time.Time
+
+	// the archives being compressed, and the lock serializing their
+	// compression and the removal of the oldest archives.
+	archiving sync.WaitGroup
+	archiveMu sync.Mutex
+
+	// header returns the data written at the beginning of a new file.
+	header func() []byte
+
+	// now returns the current time, overridden in tests.
+	now func() time.Time
+
+	sync.Mutex
+}
+
+// NewFileWriter opens the file for appending, creating it if needed. A zero
+// interval or maxSize disables rotation on this criteria, and a negative
+// maxArchives keeps all archives.
+func NewFileWriter(
+	filename string,
+	interval time.Duration,
+	maxSize int64,
+	maxArchives int,
+	compress bool,
+) (*FileWriter, error) {
+	w := &FileWriter{
+		filename:    filename,
+		interval:    interval,
+		maxSize:     maxSize,
+		maxArchives: maxArchives,
+		compress:    compress,
+		now:         time.Now,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetHeader sets the function returning the data to write at the beginning of
+// the files opened by a rotation, such as the header row of a CSV document. It
+// is not written again if the data written to the new file starts with it.
+func (w *FileWriter) SetHeader(header func() []byte) {
+	w.Lock()
+	defer w.Unlock()
+	w.header = header
+}
+
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.closed {
+		return 0, errClosed
+	}
+	file := w.file
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := w.checkMoved(); err != nil {
+		return 0, err
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if w.file != file && w.size == 0 && w.header != nil {
+		if err := w.writeHeader(p); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *FileWriter) writeHeader(p []byte) error {
+	header := w.header()
+	if len(header) == 0 || bytes.HasPrefix(p, header) {
+		return nil
+	}
+	n, err := w.file.Write(header)
+	w.size += int64(n)
+	return err
+}
+
+// Close closes the file, and waits for the archives being compressed.
+func (w *FileWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.archiving.Wait()
+	return err
+}
+
+func (w *FileWriter) open() error {
+	file, err := os.OpenFile(w.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.expire = w.now().Add(w.interval)
+	w.lastCheck = w.now()
+	return nil
+}
+
+// checkMoved reopens the file if it is not at its path anymore.
+func (w *FileWriter) checkMoved() error {
+	now := w.now()
+	if now.Sub(w.lastCheck) < checkInterval {
+		return nil
+	}
+	w.lastCheck = now
+
+	opened, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(w.filename)
+	if err == nil && os.SameFile(opened, current) {
+		return nil
+	}
+
+	log.Printf("I! File %s was moved, reopening it\n", w.filename)
+	w.file.Close()
+	w.file = nil
+	return w.open()
+}
+
+func (w *FileWriter) shouldRotate(n int64) bool {
+	if w.interval > 0 && !w.now().Before(w.expire) {
+		return true
+	}
+	// a write larger than the maximum size still goes to an empty file.
+	return w.maxSize > 0 && w.size > 0 && w.size+n > w.maxSize
+}
+
+// rotate renames the file to an archive and opens a new file. If the file
+// can not be renamed, it is reopened and the rotation is tried again on the
+// next write.
+func (w *FileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(w.filename)
+	base := strings.TrimSuffix(w.filename, ext)
+	archive := base + "." + w.now().Format(archiveTimeFormat) + ext
+	if err := os.Rename(w.filename, archive); err != nil {
+		return err
+	}
+
+	if w.compress {
+		// the compression of a large archive would block the write.
+		w.archiving.Add(1)
+		go func() {
+			defer w.archiving.Done()
+			w.archive(archive, base, ext)
+		}()
+	} else {
+		w.archive(archive, base, ext)
+	}
+	return w.open()
+}
+
+// archive compresses the archive if needed, and removes the oldest archives.
+func (w *FileWriter) archive(archive, base, ext string) {
+	w.archiveMu.Lock()
+	defer w.archiveMu.Unlock()
+
+	if w.compress {
+		if err := compressFile(archive); err != nil {
+			log.Printf("E! Could not compress %s: %s\n", archive, err)
+		}
+	}
+	if w.maxArchives >= 0 {
+		w.purgeArchives(base, ext)
+	}
+}
+
+// purgeArchives removes the oldest archives over the maximum number.
+func (w *FileWriter) purgeArchives(base, ext string) {
+	matches, err := filepath.Glob(base + ".*" + ext + "*")
+	if err != nil {
+		log.Printf("E! Could not list archives of %s: %s\n", w.filename, err)
+		return
+	}
+
+	var archives []string
+	for _, match := range matches {
+		if isArchive(match, base, ext) {
+			archives = append(archives, match)
+		}
+	}
+	if len(archives) <= w.maxArchives {
+		return
+	}
+
+	// archive names sort by rotation time
+	sort.Strings(archives)
+	for _, archive := range archives[:len(archives)-w.maxArchives] {
+		if err := os.Remove(archive); err != nil {
+			log.Printf("E! Could not remove archive %s: %s\n", archive, err)
+		}
+	}
+}
+
+// isArchive returns true if the path is an archive of the file made of base
+// and ext, compressed or not.
+func isArchive(path, base, ext string) bool {
+	name := strings.TrimSuffix(path, ".gz")
+	if !strings.HasPrefix(name, base+".") || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	ts := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ext)
+	_, err := time.Parse(archiveTimeFormat, ts)
+	return err == nil
+}
+
+// compressFile replaces the file with its gzip compressed version.
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+	return os.Remove(filename)
+}
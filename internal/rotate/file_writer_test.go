@@ -0,0 +1,273 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWriter(
+	t *testing.T,
+	dir string,
+	interval time.Duration,
+	maxSize int64,
+	maxArchives int,
+	compress bool,
+	now *time.Time,
+) *FileWriter {
+	w, err := NewFileWriter(filepath.Join(dir, "metrics.out"),
+		interval, maxSize, maxArchives, compress)
+	require.NoError(t, err)
+	w.now = func() time.Time { return *now }
+	w.expire = now.Add(interval)
+	w.lastCheck = *now
+	return w
+}
+
+func archives(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "metrics.*.out*"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestFileWriterNoRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 0, 5, false, &now)
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		now = now.Add(time.Hour)
+	}
+
+	assert.Len(t, archives(t, dir), 0)
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "metrics.out"))
+	require.NoError(t, err)
+	assert.Len(t, buf, 100)
+}
+
+func TestFileWriterRotateSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 25, 5, false, &now)
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		now = now.Add(time.Millisecond)
+	}
+
+	// 20 bytes fit in a file, the third write rotates it.
+	assert.Len(t, archives(t, dir), 2)
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "metrics.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(buf))
+}
+
+func TestFileWriterRotateSizeLargeWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 5, 5, false, &now)
+	defer w.Close()
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	assert.Len(t, archives(t, dir), 0)
+}
+
+func TestFileWriterRotateInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, time.Minute, 0, 5, false, &now)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	assert.Len(t, archives(t, dir), 0)
+
+	now = now.Add(30 * time.Second)
+	_, err = w.Write([]byte("third\n"))
+	require.NoError(t, err)
+
+	archive := filepath.Join(dir, "metrics."+now.Format(archiveTimeFormat)+".out")
+	assert.Equal(t, []string{archive}, archives(t, dir))
+	buf, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(buf))
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "metrics.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(buf))
+}
+
+func TestFileWriterMaxArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 10, 2, false, &now)
+	defer w.Close()
+
+	var rotations []time.Time
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			rotations = append(rotations, now)
+		}
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+
+	// only the two most recent archives are kept.
+	expected := []string{
+		filepath.Join(dir, "metrics."+rotations[2].Format(archiveTimeFormat)+".out"),
+		filepath.Join(dir, "metrics."+rotations[3].Format(archiveTimeFormat)+".out"),
+	}
+	assert.Equal(t, expected, archives(t, dir))
+}
+
+func TestFileWriterKeepAllArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 10, -1, false, &now)
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		now = now.Add(time.Second)
+	}
+
+	assert.Len(t, archives(t, dir), 4)
+}
+
+func TestFileWriterCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 10, 5, true, &now)
+	defer w.Close()
+
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	rotation := now
+	_, err = w.Write([]byte("abcdefghij"))
+	require.NoError(t, err)
+
+	// the archive is compressed in the background.
+	w.archiving.Wait()
+	archive := filepath.Join(dir, "metrics."+rotation.Format(archiveTimeFormat)+".out.gz")
+	require.Equal(t, []string{archive}, archives(t, dir))
+
+	f, err := os.Open(archive)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(buf))
+}
+
+func TestFileWriterRotateFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 10, 5, false, &now)
+	defer w.Close()
+
+	// a non empty directory in place of the archive makes the rename fail.
+	archive := filepath.Join(dir, "metrics."+now.Format(archiveTimeFormat)+".out")
+	require.NoError(t, os.MkdirAll(filepath.Join(archive, "block"), 0755))
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("second\n"))
+	require.Error(t, err)
+
+	// the file is reopened by the next write.
+	require.NoError(t, os.RemoveAll(archive))
+	now = now.Add(time.Second)
+	_, err = w.Write([]byte("third\n"))
+	require.NoError(t, err)
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "metrics.out"))
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(buf))
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "metrics."+now.Format(archiveTimeFormat)+".out"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(buf))
+}
+
+func TestFileWriterExternalRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 0, 5, false, &now)
+	defer w.Close()
+
+	filename := filepath.Join(dir, "metrics.out")
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, os.Rename(filename, filename+".1"))
+	now = now.Add(2 * checkInterval)
+
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	buf, err := ioutil.ReadFile(filename + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(buf))
+	buf, err = ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(buf))
+}
+
+func TestFileWriterClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1500000000, 0)
+	w := newTestWriter(t, dir, 0, 0, 5, false, &now)
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("0123456789"))
+	assert.Error(t, err)
+	assert.NoError(t, w.Close())
+}
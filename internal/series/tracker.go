@@ -0,0 +1,132 @@
+package series
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// Default number of series kept in the tracker.
+	DEFAULT_CACHE_LIMIT = 100000
+)
+
+type entry struct {
+	id       uint64
+	name     string
+	tags     map[string]string
+	lastSeen time.Time
+}
+
+// Tracker keeps the last time each series was seen in a bounded cache, so that
+// series which stop receiving points can be detected.
+type Tracker struct {
+	expiration time.Duration
+	limit      int
+
+	// series are ordered from least to most recently seen.
+	series *list.List
+	index  map[uint64]*list.Element
+
+	mu sync.Mutex
+
+	SeriesTracked selfstat.Stat
+	SeriesExpired selfstat.Stat
+	SeriesEvicted selfstat.Stat
+}
+
+// NewTracker returns a Tracker that considers a series ended once it has not
+// been seen for the expiration duration. At most limit series are cached; if a
+// new series is added when the tracker is full, then the least recently seen
+// series is forgotten without being expired.
+func NewTracker(expiration time.Duration, limit int) *Tracker {
+	if limit <= 0 {
+		limit = DEFAULT_CACHE_LIMIT
+	}
+	return &Tracker{
+		expiration: expiration,
+		limit:      limit,
+		series:     list.New(),
+		index:      make(map[uint64]*list.Element),
+
+		SeriesTracked: selfstat.Register("agent", "series_tracked", map[string]string{}),
+		SeriesExpired: selfstat.Register("agent", "series_expired", map[string]string{}),
+		SeriesEvicted: selfstat.Register("agent", "series_evicted", map[string]string{}),
+	}
+}
+
+// Len returns the number of series currently tracked.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.series.Len()
+}
+
+// Add records that the series of each metric was seen at the given time.
+// Staleness markers are ignored.
+func (t *Tracker) Add(now time.Time, metrics ...telegraf.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, m := range metrics {
+		if metric.IsStaleMarker(m) {
+			continue
+		}
+
+		id := m.HashID()
+		if e, ok := t.index[id]; ok {
+			e.Value.(*entry).lastSeen = now
+			t.series.MoveToBack(e)
+			continue
+		}
+
+		if t.series.Len() >= t.limit {
+			oldest := t.series.Front()
+			t.series.Remove(oldest)
+			delete(t.index, oldest.Value.(*entry).id)
+			t.SeriesEvicted.Incr(1)
+		}
+
+		t.index[id] = t.series.PushBack(&entry{
+			id:       id,
+			name:     m.Name(),
+			tags:     m.Tags(),
+			lastSeen: now,
+		})
+	}
+	t.SeriesTracked.Set(int64(t.series.Len()))
+}
+
+// Expire removes all series that have not been seen within the expiration
+// interval before the given time, and returns a staleness marker for each of
+// them, oldest first.
+func (t *Tracker) Expire(now time.Time) []telegraf.Metric {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var markers []telegraf.Metric
+	for e := t.series.Front(); e != nil; e = t.series.Front() {
+		s := e.Value.(*entry)
+		if now.Sub(s.lastSeen) < t.expiration {
+			break
+		}
+		t.series.Remove(e)
+		delete(t.index, s.id)
+		t.SeriesExpired.Incr(1)
+
+		m, err := metric.NewStaleMarker(s.name, s.tags, now)
+		if err != nil {
+			log.Printf("E! Could not create staleness marker for %s: %s\n",
+				s.name, err)
+			continue
+		}
+		markers = append(markers, m)
+	}
+	t.SeriesTracked.Set(int64(t.series.Len()))
+	return markers
+}
@@ -0,0 +1,65 @@
+package series
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerExpire(t *testing.T) {
+	tr := NewTracker(time.Minute, 10)
+	now := time.Now()
+
+	tr.Add(now, testutil.TestMetric(1, "cpu"), testutil.TestMetric(1, "mem"))
+	tr.Add(now.Add(30*time.Second), testutil.TestMetric(2, "cpu"))
+	assert.Equal(t, 2, tr.Len())
+
+	// nothing has expired yet
+	assert.Empty(t, tr.Expire(now.Add(59*time.Second)))
+
+	markers := tr.Expire(now.Add(time.Minute))
+	require.Len(t, markers, 1)
+	assert.Equal(t, "mem", markers[0].Name())
+	assert.Equal(t, map[string]string{"tag1": "value1"}, markers[0].Tags())
+	assert.True(t, metric.IsStaleMarker(markers[0]))
+	assert.Equal(t, 1, tr.Len())
+
+	markers = tr.Expire(now.Add(2 * time.Minute))
+	require.Len(t, markers, 1)
+	assert.Equal(t, "cpu", markers[0].Name())
+	assert.Equal(t, 0, tr.Len())
+}
+
+func TestTrackerIgnoresStaleMarkers(t *testing.T) {
+	tr := NewTracker(time.Minute, 10)
+	m, err := metric.NewStaleMarker("cpu", map[string]string{}, time.Now())
+	require.NoError(t, err)
+
+	tr.Add(time.Now(), m)
+	assert.Equal(t, 0, tr.Len())
+}
+
+func TestTrackerEvictsLeastRecentlySeen(t *testing.T) {
+	tr := NewTracker(time.Minute, 2)
+	now := time.Now()
+	tr.SeriesEvicted.Set(0)
+
+	tr.Add(now, testutil.TestMetric(1, "cpu"))
+	tr.Add(now.Add(time.Second), testutil.TestMetric(1, "mem"))
+	// cpu is seen again, so mem becomes the least recently seen series
+	tr.Add(now.Add(2*time.Second), testutil.TestMetric(1, "cpu"))
+	tr.Add(now.Add(3*time.Second), testutil.TestMetric(1, "disk"))
+
+	assert.Equal(t, 2, tr.Len())
+	assert.Equal(t, int64(1), tr.SeriesEvicted.Get())
+
+	markers := tr.Expire(now.Add(time.Hour))
+	require.Len(t, markers, 2)
+	assert.Equal(t, "cpu", markers[0].Name())
+	assert.Equal(t, "disk", markers[1].Name())
+}
@@ -0,0 +1,54 @@
+package templating
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/influxdata/telegraf"
+)
+
+// MetricTemplate renders a string from a metric, with the syntax of the
+// text/template package, ie:
+//   telegraf.{{ .Name }}.{{ .Tag "host" }}
+//
+// The template has access to the methods of the metric, such as .Name and
+// .Tags, and to .Tag which returns the value of a tag or an empty string.
+type MetricTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+func NewMetricTemplate(text string) (*MetricTemplate, error) {
+	tmpl, err := template.New("metric").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricTemplate{text: text, tmpl: tmpl}, nil
+}
+
+// Execute returns the template rendered for the metric.
+func (t *MetricTemplate) Execute(m telegraf.Metric) (string, error) {
+	// strings without any action are rendered as is.
+	if !strings.Contains(t.text, "{{") {
+		return t.text, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, metricData{m}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *MetricTemplate) String() string {
+	return t.text
+}
+
+type metricData struct {
+	telegraf.Metric
+}
+
+func (m metricData) Tag(key string) string {
+	return m.Tags()[key]
+}
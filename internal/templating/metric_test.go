@@ -0,0 +1,52 @@
+package templating
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricTemplate(t *testing.T) {
+	m, err := metric.New("cpu",
+		map[string]string{"host": "localhost", "cpu": "cpu0"},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0))
+	require.NoError(t, err)
+
+	var tests = []struct {
+		text     string
+		expected string
+	}{
+		{"telegraf", "telegraf"},
+		{"telegraf.{{ .Name }}", "telegraf.cpu"},
+		{`{{ .Tag "host" }}/{{ .Name }}/{{ .Tag "cpu" }}`, "localhost/cpu/cpu0"},
+		{`{{ .Tag "missing" }}`, ""},
+		{`{{ index .Tags "host" }}`, "localhost"},
+		{`{{ with .Tag "host" }}{{ . }}{{ else }}unknown{{ end }}`, "localhost"},
+	}
+	for _, tt := range tests {
+		tmpl, err := NewMetricTemplate(tt.text)
+		require.NoError(t, err)
+		actual, err := tmpl.Execute(m)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, actual, tt.text)
+	}
+}
+
+func TestMetricTemplateErrors(t *testing.T) {
+	_, err := NewMetricTemplate("{{ .Name ")
+	assert.Error(t, err)
+
+	m, err := metric.New("cpu", map[string]string{},
+		map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	require.NoError(t, err)
+
+	tmpl, err := NewMetricTemplate("{{ .Unknown }}")
+	require.NoError(t, err)
+	_, err = tmpl.Execute(m)
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// StaleField is the name of the field set on a staleness marker. Outputs that
+// do not understand staleness markers will simply write it as a boolean field.
+const StaleField = "_stale"
+
+// NewStaleMarker returns a metric marking the end of the series identified
+// by the given name and tags.
+func NewStaleMarker(
+	name string,
+	tags map[string]string,
+	t time.Time,
+) (telegraf.Metric, error) {
+	return New(name, tags, map[string]interface{}{StaleField: true}, t)
+}
+
+// IsStaleMarker returns true if the given metric marks the end of a series.
+func IsStaleMarker(m telegraf.Metric) bool {
+	if !m.HasField(StaleField) {
+		return false
+	}
+	v, ok := m.Fields()[StaleField]
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	return ok && b
+}
@@ -0,0 +1,35 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleMarker(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{"host": "localhost"}
+	m, err := NewStaleMarker("cpu", tags, now)
+	require.NoError(t, err)
+
+	assert.True(t, IsStaleMarker(m))
+	assert.Equal(t, "cpu", m.Name())
+	assert.Equal(t, tags, m.Tags())
+	assert.Equal(t, now.UnixNano(), m.UnixNano())
+	assert.Equal(t, map[string]interface{}{StaleField: true}, m.Fields())
+}
+
+func TestIsStaleMarker(t *testing.T) {
+	now := time.Now()
+	m, err := New("cpu", map[string]string{},
+		map[string]interface{}{"usage_stale": 1.0}, now)
+	require.NoError(t, err)
+	assert.False(t, IsStaleMarker(m))
+
+	m, err = New("cpu", map[string]string{},
+		map[string]interface{}{StaleField: false}, now)
+	require.NoError(t, err)
+	assert.False(t, IsStaleMarker(m))
+}
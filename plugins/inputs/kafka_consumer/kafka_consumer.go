@@ -1,60 +1,103 @@
 package kafka_consumer
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 
 	"github.com/Shopify/sarama"
-	"github.com/wvanbergen/kafka/consumergroup"
 )
 
+// delay before consuming again after an error of the consumer group.
+const reconnectDelay = 5 * time.Second
+
 type Kafka struct {
-	ConsumerGroup   string
-	Topics          []string
-	ZookeeperPeers  []string
-	ZookeeperChroot string
-	Consumer        *consumergroup.ConsumerGroup
-
-	// Legacy metric buffer support
-	MetricBuffer int
-	// TODO remove PointBuffer, legacy support
-	PointBuffer int
-
-	Offset string
-	parser parsers.Parser
+	Brokers              []string
+	ConsumerGroup        string
+	Topics               []string
+	TopicRegexps         []string          `toml:"topic_regexps"`
+	TopicRefreshInterval internal.Duration `toml:"topic_refresh_interval"`
+	Offset               string
+	OffsetCommitInterval internal.Duration `toml:"offset_commit_interval"`
+	BalanceStrategy      string            `toml:"balance_strategy"`
+	MaxMessageLen        int               `toml:"max_message_len"`
+	Version              string            `toml:"version"`
+
+	// SASL authentication
+	SASLUsername string `toml:"sasl_username"`
+	SASLPassword string `toml:"sasl_password"`
 
-	sync.Mutex
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
 
-	// channel for all incoming kafka messages
-	in <-chan *sarama.ConsumerMessage
-	// channel for all kafka consumer errors
-	errs <-chan error
-	done chan struct{}
+	parser parsers.Parser
+
+	consumer sarama.ConsumerGroup
+	// client lists the topics of the cluster, only set with topic regexps.
+	client  sarama.Client
+	regexps []*regexp.Regexp
 
 	// keep the accumulator internally:
 	acc telegraf.Accumulator
 
-	// doNotCommitMsgs tells the parser not to call CommitUpTo on the consumer
-	// this is mostly for test purposes, but there may be a use-case for it later.
-	doNotCommitMsgs bool
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 var sampleConfig = `
+  ## kafka servers
+  brokers = ["localhost:9092"]
   ## topic(s) to consume
   topics = ["telegraf"]
-  ## an array of Zookeeper connection strings
-  zookeeper_peers = ["localhost:2181"]
-  ## Zookeeper Chroot
-  zookeeper_chroot = ""
+  ## regular expressions matching the topic(s) to consume, the topics of the
+  ## cluster are listed again every topic_refresh_interval.
+  # topic_regexps = ["^telegraf_.*"]
+  # topic_refresh_interval = "5m"
+
   ## the name of the consumer group
   consumer_group = "telegraf_metrics_consumers"
-  ## Offset (must be either "oldest" or "newest")
+  ## Offset of the partitions without a committed offset, must be either
+  ## "oldest" or "newest"
   offset = "oldest"
+  ## The offsets of the processed messages are committed at this interval.
+  # offset_commit_interval = "1s"
+  ## How the partitions are assigned to the members of the consumer group,
+  ## must be either "range" or "roundrobin"
+  # balance_strategy = "range"
+
+  ## Maximum length of a message to consume, longer messages are dropped.
+  ## Setting it to 0 disables the limit.
+  max_message_len = 1000000
+
+  ## Kafka version of the brokers, consumer groups require 0.10.2.0 or later.
+  # version = "0.10.2.0"
+
+  ## Optional SASL Config
+  # sasl_username = "kafka"
+  # sasl_password = "secret"
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
 
   ## Data format to consume.
   ## Each data format has it's own unique set of configuration options, read
@@ -76,98 +119,298 @@ func (k *Kafka) SetParser(parser parsers.Parser) {
 }
 
 func (k *Kafka) Start(acc telegraf.Accumulator) error {
-	k.Lock()
-	defer k.Unlock()
-	var consumerErr error
-
 	k.acc = acc
 
-	config := consumergroup.NewConfig()
-	config.Zookeeper.Chroot = k.ZookeeperChroot
+	config, err := k.config()
+	if err != nil {
+		return err
+	}
+
+	k.regexps = k.regexps[:0]
+	for _, expr := range k.TopicRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid topic regexp [%s]: %s", expr, err)
+		}
+		k.regexps = append(k.regexps, re)
+	}
+	if len(k.Topics) == 0 && len(k.regexps) == 0 {
+		return fmt.Errorf("topics or topic_regexps are required")
+	}
+	if len(k.regexps) > 0 && k.TopicRefreshInterval.Duration <= 0 {
+		return fmt.Errorf("topic_refresh_interval must be positive")
+	}
+
+	if len(k.regexps) > 0 {
+		k.client, err = sarama.NewClient(k.Brokers, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	k.consumer, err = sarama.NewConsumerGroup(k.Brokers, k.ConsumerGroup, config)
+	if err != nil {
+		if k.client != nil {
+			k.client.Close()
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+
+	k.wg.Add(2)
+	go k.logErrors()
+	go k.consume(ctx)
+
+	log.Printf("I! Started the kafka consumer service, brokers: %v, topics: %v, "+
+		"topic regexps: %v\n", k.Brokers, k.Topics, k.TopicRegexps)
+	return nil
+}
+
+func (k *Kafka) config() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	if k.OffsetCommitInterval.Duration > 0 {
+		config.Consumer.Offsets.CommitInterval = k.OffsetCommitInterval.Duration
+	}
+
+	config.Version = sarama.V0_10_2_0
+	if k.Version != "" {
+		version, err := sarama.ParseKafkaVersion(k.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version [%s]: %s", k.Version, err)
+		}
+		config.Version = version
+	}
+
 	switch strings.ToLower(k.Offset) {
 	case "oldest", "":
-		config.Offsets.Initial = sarama.OffsetOldest
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	case "newest":
-		config.Offsets.Initial = sarama.OffsetNewest
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
 	default:
-		log.Printf("I! WARNING: Kafka consumer invalid offset '%s', using 'oldest'\n",
-			k.Offset)
-		config.Offsets.Initial = sarama.OffsetOldest
-	}
-
-	if k.Consumer == nil || k.Consumer.Closed() {
-		k.Consumer, consumerErr = consumergroup.JoinConsumerGroup(
-			k.ConsumerGroup,
-			k.Topics,
-			k.ZookeeperPeers,
-			config,
-		)
-		if consumerErr != nil {
-			return consumerErr
-		}
+		return nil, fmt.Errorf("invalid offset [%s], must be oldest or newest", k.Offset)
+	}
 
-		// Setup message and error channels
-		k.in = k.Consumer.Messages()
-		k.errs = k.Consumer.Errors()
+	switch strings.ToLower(k.BalanceStrategy) {
+	case "range", "":
+		config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	case "roundrobin":
+		config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	default:
+		return nil, fmt.Errorf("invalid balance_strategy [%s], must be range or roundrobin",
+			k.BalanceStrategy)
 	}
 
-	k.done = make(chan struct{})
+	tlsConfig, err := internal.GetTLSConfig(
+		k.SSLCert, k.SSLKey, k.SSLCA, k.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		config.Net.TLS.Config = tlsConfig
+		config.Net.TLS.Enable = true
+	}
 
-	// Start the kafka message reader
-	go k.receiver()
-	log.Printf("I! Started the kafka consumer service, peers: %v, topics: %v\n",
-		k.ZookeeperPeers, k.Topics)
-	return nil
+	if k.SASLUsername != "" && k.SASLPassword != "" {
+		config.Net.SASL.User = k.SASLUsername
+		config.Net.SASL.Password = k.SASLPassword
+		config.Net.SASL.Enable = true
+	}
+	return config, nil
 }
 
-// receiver() reads all incoming messages from the consumer, and parses them into
-// influxdb metric points.
-func (k *Kafka) receiver() {
+// consume joins the consumer group until the context is cancelled. Each
+// session lasts until the partitions are rebalanced between the members of
+// the group, or until the topics matching the regexps change.
+func (k *Kafka) consume(ctx context.Context) {
+	defer k.wg.Done()
+
+	for ctx.Err() == nil {
+		topics, err := k.topics()
+		if err != nil {
+			log.Printf("E! Kafka Consumer Error listing topics: %s\n", err)
+		} else if len(topics) == 0 {
+			log.Printf("E! Kafka Consumer Error: no topic matching %v\n", k.TopicRegexps)
+		} else {
+			sessCtx, sessCancel := context.WithCancel(ctx)
+			if len(k.regexps) > 0 {
+				go k.watchTopics(sessCtx, sessCancel, topics)
+			}
+			err = k.consumer.Consume(sessCtx, topics, &consumerGroupHandler{k})
+			sessCancel()
+			if err == nil {
+				continue
+			}
+			log.Printf("E! Kafka Consumer Error: %s\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// watchTopics ends the session when the topics matching the regexps change,
+// so that the next session consumes the new topics.
+func (k *Kafka) watchTopics(ctx context.Context, cancel context.CancelFunc, topics []string) {
+	ticker := time.NewTicker(k.TopicRefreshInterval.Duration)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-k.done:
+		case <-ctx.Done():
 			return
-		case err := <-k.errs:
+		case <-ticker.C:
+			current, err := k.topics()
 			if err != nil {
-				log.Printf("E! Kafka Consumer Error: %s\n", err)
+				log.Printf("E! Kafka Consumer Error listing topics: %s\n", err)
+				continue
 			}
-		case msg := <-k.in:
-			metrics, err := k.parser.Parse(msg.Value)
-			if err != nil {
-				log.Printf("E! Kafka Message Parse Error\nmessage: %s\nerror: %s",
-					string(msg.Value), err.Error())
+			if !equalTopics(topics, current) {
+				log.Printf("I! Kafka Consumer topics changed to %v\n", current)
+				cancel()
+				return
 			}
+		}
+	}
+}
 
-			for _, metric := range metrics {
-				k.acc.AddFields(metric.Name(), metric.Fields(), metric.Tags(), metric.Time())
-			}
+// topics returns the sorted list of the topics to consume.
+func (k *Kafka) topics() ([]string, error) {
+	var all []string
+	if k.client != nil {
+		if err := k.client.RefreshMetadata(); err != nil {
+			return nil, err
+		}
+		var err error
+		all, err = k.client.Topics()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return k.matchTopics(all), nil
+}
 
-			if !k.doNotCommitMsgs {
-				// TODO(cam) this locking can be removed if this PR gets merged:
-				// https://github.com/wvanbergen/kafka/pull/84
-				k.Lock()
-				k.Consumer.CommitUpto(msg)
-				k.Unlock()
+// matchTopics returns the configured topics, and the topics of the cluster
+// matching any of the regexps.
+func (k *Kafka) matchTopics(all []string) []string {
+	set := make(map[string]bool)
+	for _, topic := range k.Topics {
+		set[topic] = true
+	}
+	for _, topic := range all {
+		for _, re := range k.regexps {
+			if re.MatchString(topic) {
+				set[topic] = true
+				break
 			}
 		}
 	}
+
+	topics := make([]string, 0, len(set))
+	for topic := range set {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func equalTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (k *Kafka) logErrors() {
+	defer k.wg.Done()
+	for err := range k.consumer.Errors() {
+		log.Printf("E! Kafka Consumer Error: %s\n", err)
+	}
+}
+
+// onMessage parses a message into metrics and adds them to the accumulator.
+func (k *Kafka) onMessage(msg *sarama.ConsumerMessage) {
+	if k.MaxMessageLen != 0 && len(msg.Value) > k.MaxMessageLen {
+		log.Printf("E! Kafka Message longer than max_message_len (%d > %d), "+
+			"dropping it\n", len(msg.Value), k.MaxMessageLen)
+		return
+	}
+
+	metrics, err := k.parser.Parse(msg.Value)
+	if err != nil {
+		log.Printf("E! Kafka Message Parse Error\nmessage: %s\nerror: %s",
+			string(msg.Value), err.Error())
+	}
+
+	for _, metric := range metrics {
+		k.acc.AddFields(metric.Name(), metric.Fields(), metric.Tags(), metric.Time())
+	}
 }
 
 func (k *Kafka) Stop() {
-	k.Lock()
-	defer k.Unlock()
-	close(k.done)
-	if err := k.Consumer.Close(); err != nil {
+	k.cancel()
+	if err := k.consumer.Close(); err != nil {
 		log.Printf("E! Error closing kafka consumer: %s\n", err.Error())
 	}
+	k.wg.Wait()
+
+	if k.client != nil {
+		if err := k.client.Close(); err != nil {
+			log.Printf("E! Error closing kafka client: %s\n", err.Error())
+		}
+	}
 }
 
 func (k *Kafka) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
+// consumerGroupHandler processes the messages of the partitions claimed by a
+// session of the consumer group.
+type consumerGroupHandler struct {
+	k *Kafka
+}
+
+func (h *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Printf("I! Kafka Consumer joined group %s, generation %d, claims: %v\n",
+		h.k.ConsumerGroup, session.GenerationID(), session.Claims())
+	return nil
+}
+
+func (h *consumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim marks each message as processed once its metrics are added to
+// the accumulator, the marked offsets are committed periodically.
+func (h *consumerGroupHandler) ConsumeClaim(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+) error {
+	for msg := range claim.Messages() {
+		h.k.onMessage(msg)
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
 func init() {
 	inputs.Add("kafka_consumer", func() telegraf.Input {
-		return &Kafka{}
+		return &Kafka{
+			ConsumerGroup:        "telegraf_metrics_consumers",
+			Offset:               "oldest",
+			MaxMessageLen:        1000000,
+			TopicRefreshInterval: internal.Duration{Duration: 5 * time.Minute},
+			OffsetCommitInterval: internal.Duration{Duration: time.Second},
+		}
 	})
 }
@@ -1,8 +1,10 @@
 package kafka_consumer
 
 import (
+	"context"
+	"regexp"
+	"strings"
 	"testing"
-	"time"
 
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/testutil"
@@ -18,62 +20,110 @@ const (
 	invalidMsg      = "cpu_load_short,host=server01 1422568543702900257\n"
 )
 
-func newTestKafka() (*Kafka, chan *sarama.ConsumerMessage) {
-	in := make(chan *sarama.ConsumerMessage, 1000)
+type testSession struct {
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *testSession) Claims() map[string][]int32 { return nil }
+func (s *testSession) MemberID() string           { return "" }
+func (s *testSession) GenerationID() int32        { return 0 }
+func (s *testSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (s *testSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (s *testSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg)
+}
+func (s *testSession) Context() context.Context { return context.Background() }
+
+type testClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *testClaim) Topic() string                            { return "telegraf" }
+func (c *testClaim) Partition() int32                         { return 0 }
+func (c *testClaim) InitialOffset() int64                     { return 0 }
+func (c *testClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func newTestKafka() *Kafka {
 	k := Kafka{
-		ConsumerGroup:   "test",
-		Topics:          []string{"telegraf"},
-		ZookeeperPeers:  []string{"localhost:2181"},
-		Offset:          "oldest",
-		in:              in,
-		doNotCommitMsgs: true,
-		errs:            make(chan error, 1000),
-		done:            make(chan struct{}),
+		ConsumerGroup: "test",
+		Topics:        []string{"telegraf"},
+		Brokers:       []string{"localhost:9092"},
+		Offset:        "oldest",
+		MaxMessageLen: 1000000,
 	}
-	return &k, in
+	return &k
+}
+
+// consumeMessages consumes the messages with the handler of the consumer
+// group, and returns the messages marked as processed.
+func consumeMessages(t *testing.T, k *Kafka, msgs ...string) []*sarama.ConsumerMessage {
+	claim := &testClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.messages <- saramaMsg(msg)
+	}
+	// the channel is closed when the partitions are rebalanced.
+	close(claim.messages)
+
+	session := &testSession{}
+	handler := &consumerGroupHandler{k}
+	assert.NoError(t, handler.Setup(session))
+	assert.NoError(t, handler.ConsumeClaim(session, claim))
+	assert.NoError(t, handler.Cleanup(session))
+	return session.marked
 }
 
 // Test that the parser parses kafka messages into points
 func TestRunParser(t *testing.T) {
-	k, in := newTestKafka()
+	k := newTestKafka()
 	acc := testutil.Accumulator{}
 	k.acc = &acc
-	defer close(k.done)
 
 	k.parser, _ = parsers.NewInfluxParser()
-	go k.receiver()
-	in <- saramaMsg(testMsg)
-	time.Sleep(time.Millisecond * 5)
+	marked := consumeMessages(t, k, testMsg)
 
 	assert.Equal(t, acc.NFields(), 1)
+	assert.Len(t, marked, 1)
 }
 
 // Test that the parser ignores invalid messages
 func TestRunParserInvalidMsg(t *testing.T) {
-	k, in := newTestKafka()
+	k := newTestKafka()
 	acc := testutil.Accumulator{}
 	k.acc = &acc
-	defer close(k.done)
 
 	k.parser, _ = parsers.NewInfluxParser()
-	go k.receiver()
-	in <- saramaMsg(invalidMsg)
-	time.Sleep(time.Millisecond * 5)
+	marked := consumeMessages(t, k, invalidMsg)
 
 	assert.Equal(t, acc.NFields(), 0)
+	// invalid messages are skipped, not consumed again.
+	assert.Len(t, marked, 1)
+}
+
+// Test that messages longer than the maximum length are dropped
+func TestRunParserMaxMessageLen(t *testing.T) {
+	k := newTestKafka()
+	k.MaxMessageLen = len(testMsg) - 1
+	acc := testutil.Accumulator{}
+	k.acc = &acc
+
+	k.parser, _ = parsers.NewInfluxParser()
+	marked := consumeMessages(t, k, testMsg, strings.Repeat("x", len(testMsg)-1))
+
+	assert.Equal(t, acc.NFields(), 0)
+	assert.Len(t, marked, 2)
 }
 
 // Test that the parser parses kafka messages into points
 func TestRunParserAndGather(t *testing.T) {
-	k, in := newTestKafka()
+	k := newTestKafka()
 	acc := testutil.Accumulator{}
 	k.acc = &acc
-	defer close(k.done)
 
 	k.parser, _ = parsers.NewInfluxParser()
-	go k.receiver()
-	in <- saramaMsg(testMsg)
-	time.Sleep(time.Millisecond * 5)
+	consumeMessages(t, k, testMsg)
 
 	k.Gather(&acc)
 
@@ -84,15 +134,12 @@ func TestRunParserAndGather(t *testing.T) {
 
 // Test that the parser parses kafka messages into points
 func TestRunParserAndGatherGraphite(t *testing.T) {
-	k, in := newTestKafka()
+	k := newTestKafka()
 	acc := testutil.Accumulator{}
 	k.acc = &acc
-	defer close(k.done)
 
 	k.parser, _ = parsers.NewGraphiteParser("_", []string{}, nil)
-	go k.receiver()
-	in <- saramaMsg(testMsgGraphite)
-	time.Sleep(time.Millisecond * 5)
+	consumeMessages(t, k, testMsgGraphite)
 
 	k.Gather(&acc)
 
@@ -103,15 +150,12 @@ func TestRunParserAndGatherGraphite(t *testing.T) {
 
 // Test that the parser parses kafka messages into points
 func TestRunParserAndGatherJSON(t *testing.T) {
-	k, in := newTestKafka()
+	k := newTestKafka()
 	acc := testutil.Accumulator{}
 	k.acc = &acc
-	defer close(k.done)
 
 	k.parser, _ = parsers.NewJSONParser("kafka_json_test", []string{}, nil)
-	go k.receiver()
-	in <- saramaMsg(testMsgJSON)
-	time.Sleep(time.Millisecond * 5)
+	consumeMessages(t, k, testMsgJSON)
 
 	k.Gather(&acc)
 
@@ -123,6 +167,36 @@ func TestRunParserAndGatherJSON(t *testing.T) {
 		})
 }
 
+func TestMatchTopics(t *testing.T) {
+	k := newTestKafka()
+	k.Topics = []string{"telegraf", "metrics"}
+	k.regexps = []*regexp.Regexp{
+		regexp.MustCompile("^telegraf_"),
+		regexp.MustCompile("_metrics$"),
+	}
+
+	topics := k.matchTopics([]string{
+		"telegraf",
+		"telegraf_cpu",
+		"app_metrics",
+		"logs",
+		"__consumer_offsets",
+	})
+	assert.Equal(t, []string{"app_metrics", "metrics", "telegraf", "telegraf_cpu"}, topics)
+}
+
+func TestConfigErrors(t *testing.T) {
+	k := newTestKafka()
+	k.Offset = "latest"
+	_, err := k.config()
+	assert.Error(t, err)
+
+	k = newTestKafka()
+	k.BalanceStrategy = "sticky"
+	_, err = k.config()
+	assert.Error(t, err)
+}
+
 func saramaMsg(val string) *sarama.ConsumerMessage {
 	return &sarama.ConsumerMessage{
 		Key:       nil,
@@ -15,6 +15,15 @@ import (
 	"github.com/eclipse/paho.mqtt.golang"
 )
 
+// TopicParsing extracts tags from the levels of the topics matching a filter.
+type TopicParsing struct {
+	// Topic filter, with the "+" and "#" wildcards
+	Topic string
+	// Tag names separated by "/", one for each level of the topic, "_" skips
+	// a level
+	Tags string
+}
+
 type MQTTConsumer struct {
 	Servers  []string
 	Topics   []string
@@ -22,6 +31,10 @@ type MQTTConsumer struct {
 	Password string
 	QoS      int `toml:"qos"`
 
+	TopicTag       string         `toml:"topic_tag"`
+	TopicParsing   []TopicParsing `toml:"topic_parsing"`
+	IgnoreRetained bool
+
 	parser parsers.Parser
 
 	// Legacy metric buffer support
@@ -70,6 +83,13 @@ var sampleConfig = `
   # If empty, a random client ID will be generated.
   client_id = ""
 
+  ## Ignore the retained messages, that the broker sends on subscription
+  ## with the last value of the topics.
+  # ignore_retained = false
+
+  ## Tag added with the topic of the message, an empty value disables it.
+  # topic_tag = "topic"
+
   ## username and password to connect MQTT server.
   # username = "telegraf"
   # password = "metricsmetricsmetricsmetrics"
@@ -86,6 +106,14 @@ var sampleConfig = `
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
   data_format = "influx"
+
+  ## Extract tags from the levels of the topic. The first rule matching the
+  ## topic is used, "_" skips a level. For example the topic
+  ## "site/paris/device/sensor01" would add the tags site=paris and
+  ## device=sensor01:
+  # [[inputs.mqtt_consumer.topic_parsing]]
+  #   topic = "site/+/device/+"
+  #   tags = "_/site/_/device"
 `
 
 func (m *MQTTConsumer) SampleConfig() string {
@@ -114,26 +142,42 @@ func (m *MQTTConsumer) Start(acc telegraf.Accumulator) error {
 	if m.QoS > 2 || m.QoS < 0 {
 		return fmt.Errorf("MQTT Consumer, invalid QoS value: %d", m.QoS)
 	}
+	if m.PersistentSession && m.QoS == 0 {
+		log.Printf("W! MQTT Consumer: messages with QoS 0 are not kept by the" +
+			" broker while disconnected, use qos = 1 or 2 with persistent_session")
+	}
+
+	for _, p := range m.TopicParsing {
+		if len(strings.Split(p.Tags, "/")) > len(strings.Split(p.Topic, "/")) &&
+			!strings.HasSuffix(p.Topic, "#") {
+			return fmt.Errorf("MQTT Consumer, topic_parsing tags %q have more"+
+				" levels than the topic %q", p.Tags, p.Topic)
+		}
+	}
 
 	opts, err := m.createOpts()
 	if err != nil {
 		return err
 	}
 
+	// the channels are created before connecting, the messages of a
+	// persistent session can be received as soon as the client is connected.
+	m.in = make(chan mqtt.Message, 1000)
+	m.done = make(chan struct{})
+
 	m.client = mqtt.NewClient(opts)
 	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
 		return token.Error()
 	}
 
-	m.in = make(chan mqtt.Message, 1000)
-	m.done = make(chan struct{})
-
 	go m.receiver()
 
 	return nil
 }
 func (m *MQTTConsumer) onConnect(c mqtt.Client) {
 	log.Printf("I! MQTT Client Connected")
+	m.Lock()
+	defer m.Unlock()
 	if !m.PersistentSession || !m.started {
 		topics := make(map[string]byte)
 		for _, topic := range m.Topics {
@@ -163,20 +207,74 @@ func (m *MQTTConsumer) receiver() {
 		case <-m.done:
 			return
 		case msg := <-m.in:
-			topic := msg.Topic()
-			metrics, err := m.parser.Parse(msg.Payload())
-			if err != nil {
-				log.Printf("E! MQTT Parse Error\nmessage: %s\nerror: %s",
-					string(msg.Payload()), err.Error())
-			}
+			m.onMessage(msg)
+		}
+	}
+}
+
+func (m *MQTTConsumer) onMessage(msg mqtt.Message) {
+	if m.IgnoreRetained && msg.Retained() {
+		return
+	}
+
+	topic := msg.Topic()
+	metrics, err := m.parser.Parse(msg.Payload())
+	if err != nil {
+		log.Printf("E! MQTT Parse Error\nmessage: %s\nerror: %s",
+			string(msg.Payload()), err.Error())
+	}
+
+	topicTags := m.topicTags(topic)
+	for _, metric := range metrics {
+		tags := metric.Tags()
+		for k, v := range topicTags {
+			tags[k] = v
+		}
+		if m.TopicTag != "" {
+			tags[m.TopicTag] = topic
+		}
+		m.acc.AddFields(metric.Name(), metric.Fields(), tags, metric.Time())
+	}
+}
+
+// topicTags returns the tags extracted from the topic by the first matching
+// topic_parsing rule.
+func (m *MQTTConsumer) topicTags(topic string) map[string]string {
+	tags := make(map[string]string)
+	for _, p := range m.TopicParsing {
+		if !matchTopic(p.Topic, topic) {
+			continue
+		}
 
-			for _, metric := range metrics {
-				tags := metric.Tags()
-				tags["topic"] = topic
-				m.acc.AddFields(metric.Name(), metric.Fields(), tags, metric.Time())
+		levels := strings.Split(topic, "/")
+		for i, name := range strings.Split(p.Tags, "/") {
+			if name == "" || name == "_" || i >= len(levels) {
+				continue
 			}
+			tags[name] = levels[i]
+		}
+		break
+	}
+	return tags
+}
+
+// matchTopic returns true if the topic matches the filter, with the "+"
+// single level and "#" multi level wildcards.
+func matchTopic(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
 		}
 	}
+	return len(f) == len(t)
 }
 
 func (m *MQTTConsumer) recvMessage(_ mqtt.Client, msg mqtt.Message) {
@@ -243,6 +341,8 @@ func (m *MQTTConsumer) createOpts() (*mqtt.ClientOptions, error) {
 
 func init() {
 	inputs.Add("mqtt_consumer", func() telegraf.Input {
-		return &MQTTConsumer{}
+		return &MQTTConsumer{
+			TopicTag: "topic",
+		}
 	})
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/eclipse/paho.mqtt.golang"
 )
@@ -22,10 +23,11 @@ const (
 func newTestMQTTConsumer() (*MQTTConsumer, chan mqtt.Message) {
 	in := make(chan mqtt.Message, 100)
 	n := &MQTTConsumer{
-		Topics:  []string{"telegraf"},
-		Servers: []string{"localhost:1883"},
-		in:      in,
-		done:    make(chan struct{}),
+		Topics:   []string{"telegraf"},
+		Servers:  []string{"localhost:1883"},
+		TopicTag: "topic",
+		in:       in,
+		done:     make(chan struct{}),
 	}
 	return n, in
 }
@@ -167,6 +169,176 @@ func TestRunParserAndGatherJSON(t *testing.T) {
 		})
 }
 
+func TestTopicTags(t *testing.T) {
+	n, _ := newTestMQTTConsumer()
+	acc := testutil.Accumulator{}
+	n.acc = &acc
+	n.parser, _ = parsers.NewInfluxParser()
+	n.TopicParsing = []TopicParsing{
+		{Topic: "site/+/device/+", Tags: "_/site/_/device"},
+		{Topic: "site/#", Tags: "_/site"},
+	}
+
+	n.onMessage(&message{
+		topic:   "site/paris/device/sensor01",
+		payload: []byte(testMsg),
+	})
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(23422)},
+		map[string]string{
+			"host":   "server01",
+			"site":   "paris",
+			"device": "sensor01",
+			"topic":  "site/paris/device/sensor01",
+		})
+
+	acc.ClearMetrics()
+	n.onMessage(&message{
+		topic:   "site/lyon/gateway",
+		payload: []byte(testMsg),
+	})
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(23422)},
+		map[string]string{
+			"host":  "server01",
+			"site":  "lyon",
+			"topic": "site/lyon/gateway",
+		})
+}
+
+func TestTopicTagDisabled(t *testing.T) {
+	n, _ := newTestMQTTConsumer()
+	acc := testutil.Accumulator{}
+	n.acc = &acc
+	n.parser, _ = parsers.NewInfluxParser()
+	n.TopicTag = ""
+
+	n.onMessage(mqttMsg(testMsg))
+	acc.AssertContainsTaggedFields(t, "cpu_load_short",
+		map[string]interface{}{"value": float64(23422)},
+		map[string]string{"host": "server01"})
+}
+
+func TestIgnoreRetained(t *testing.T) {
+	n, _ := newTestMQTTConsumer()
+	acc := testutil.Accumulator{}
+	n.acc = &acc
+	n.parser, _ = parsers.NewInfluxParser()
+	n.IgnoreRetained = true
+
+	n.onMessage(&message{
+		topic:    "telegraf",
+		payload:  []byte(testMsg),
+		retained: true,
+	})
+	assert.Equal(t, 0, acc.NFields())
+
+	n.onMessage(mqttMsg(testMsg))
+	assert.Equal(t, 1, acc.NFields())
+}
+
+func TestMatchTopic(t *testing.T) {
+	var tests = []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"site/+/device/+", "site/paris/device/sensor01", true},
+		{"site/+/device/+", "site/paris/device", false},
+		{"site/+/device/+", "site/paris/device/sensor01/temp", false},
+		{"site/#", "site/paris/device/sensor01", true},
+		{"site/#", "site", true},
+		{"#", "site/paris", true},
+		{"site/paris", "site/lyon", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, matchTopic(tt.filter, tt.topic),
+			tt.filter+" "+tt.topic)
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	m := &MQTTConsumer{
+		Servers: []string{"localhost:1883"},
+		QoS:     3,
+	}
+	acc := testutil.Accumulator{}
+	assert.Error(t, m.Start(&acc))
+
+	m = &MQTTConsumer{
+		Servers: []string{"localhost:1883"},
+		TopicParsing: []TopicParsing{
+			{Topic: "site/+", Tags: "_/site/device"},
+		},
+	}
+	assert.Error(t, m.Start(&acc))
+}
+
+func TestConsumeFromBroker(t *testing.T) {
+	broker, err := testutil.NewMQTTBroker()
+	require.NoError(t, err)
+	defer broker.Close()
+
+	broker.Publish(testutil.MQTTMessage{
+		Topic:    "site/paris/device/sensor01",
+		Payload:  []byte("temperature value=21.5 1422568543702900257\n"),
+		QoS:      1,
+		Retained: true,
+	})
+
+	parser, _ := parsers.NewInfluxParser()
+	m := &MQTTConsumer{
+		Servers:           []string{broker.Addr()},
+		Topics:            []string{"site/#"},
+		QoS:               1,
+		PersistentSession: true,
+		ClientID:          "telegraf-test",
+		TopicTag:          "topic",
+		TopicParsing: []TopicParsing{
+			{Topic: "site/+/device/+", Tags: "_/site/_/device"},
+		},
+		parser: parser,
+	}
+	acc := testutil.Accumulator{}
+	require.NoError(t, m.Start(&acc))
+	defer m.Stop()
+
+	// the retained message is sent on subscription
+	waitForMetrics(t, &acc, 1)
+
+	broker.Publish(testutil.MQTTMessage{
+		Topic:   "site/lyon/device/sensor02",
+		Payload: []byte("temperature value=18.0 1422568543702900257\n"),
+		QoS:     2,
+	})
+	waitForMetrics(t, &acc, 2)
+
+	acc.AssertContainsTaggedFields(t, "temperature",
+		map[string]interface{}{"value": float64(21.5)},
+		map[string]string{
+			"site":   "paris",
+			"device": "sensor01",
+			"topic":  "site/paris/device/sensor01",
+		})
+	acc.AssertContainsTaggedFields(t, "temperature",
+		map[string]interface{}{"value": float64(18)},
+		map[string]string{
+			"site":   "lyon",
+			"device": "sensor02",
+			"topic":  "site/lyon/device/sensor02",
+		})
+}
+
+func waitForMetrics(t *testing.T, acc *testutil.Accumulator, n uint64) {
+	for i := 0; i < 200; i++ {
+		if acc.NMetrics() >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d metrics, got %d", n, acc.NMetrics())
+}
+
 func mqttMsg(val string) mqtt.Message {
 	return &message{
 		topic:   "telegraf/unit_test",
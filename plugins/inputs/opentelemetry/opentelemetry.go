@@ -0,0 +1,384 @@
+package opentelemetry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/otlp"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// DEFAULT_MAX_MESSAGE_SIZE is the default maximum size of a request, in
+	// bytes, after decompression.
+	// 32 MB
+	DEFAULT_MAX_MESSAGE_SIZE = 32 * 1024 * 1024
+)
+
+const sampleConfig = `
+  ## Address and port to receive the metrics with OTLP/gRPC on, an empty
+  ## address disables the gRPC receiver.
+  service_address = ":4317"
+  ## Address and port to receive the metrics with OTLP/HTTP on, at the
+  ## /v1/metrics path, an empty address disables the HTTP receiver.
+  http_service_address = ":4318"
+
+  ## Maximum allowed size of a request in bytes, after decompression.
+  ## 0 means to use the default of 33,554,432 bytes (32 mebibytes)
+  max_message_size = 0
+
+  ## Attributes of the resource of the metrics added as tags, such as the
+  ## "service.name" attribute. The attributes of the data points take
+  ## precedence.
+  # resource_tags = ["service.name"]
+
+  ## Optional SSL configuration.
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key  = "/etc/telegraf/key.pem"
+  ## Enables client authentication if set.
+  # ssl_allowed_client_ca = ["/etc/telegraf/clientca.pem"]
+`
+
+type OpenTelemetry struct {
+	ServiceAddress     string
+	HTTPServiceAddress string   `toml:"http_service_address"`
+	MaxMessageSize     int      `toml:"max_message_size"`
+	ResourceTags       []string `toml:"resource_tags"`
+
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Paths to the CA files that client certificates must be signed by
+	SSLAllowedClientCA []string `toml:"ssl_allowed_client_ca"`
+
+	mu sync.Mutex
+	wg sync.WaitGroup
+
+	// the OTLP/HTTP requests being handled, waited for on Stop, as the
+	// server can not be shut down.
+	handlersMu sync.Mutex
+	handlers   sync.WaitGroup
+	stopped    bool
+
+	grpcServer   *grpcServer
+	httpListener net.Listener
+
+	acc telegraf.Accumulator
+
+	RequestsRecv      selfstat.Stat
+	BadRequests       selfstat.Stat
+	DataPointsRecv    selfstat.Stat
+	DataPointsDropped selfstat.Stat
+}
+
+func (o *OpenTelemetry) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpenTelemetry) Description() string {
+	return "Receive metrics with the OpenTelemetry protocol (OTLP)"
+}
+
+// All the work is done in the Start() function, so this is just a dummy
+// function.
+func (o *OpenTelemetry) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+// Start starts the gRPC and HTTP receivers.
+func (o *OpenTelemetry) Start(acc telegraf.Accumulator) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.ServiceAddress == "" && o.HTTPServiceAddress == "" {
+		return fmt.Errorf("service_address and http_service_address are both empty")
+	}
+
+	tags := map[string]string{
+		"address": o.ServiceAddress,
+	}
+	if o.ServiceAddress == "" {
+		tags["address"] = o.HTTPServiceAddress
+	}
+	o.RequestsRecv = selfstat.Register("opentelemetry", "requests_received", tags)
+	o.BadRequests = selfstat.Register("opentelemetry", "bad_requests", tags)
+	o.DataPointsRecv = selfstat.Register("opentelemetry", "data_points_received", tags)
+	o.DataPointsDropped = selfstat.Register("opentelemetry", "data_points_dropped", tags)
+
+	if o.MaxMessageSize == 0 {
+		o.MaxMessageSize = DEFAULT_MAX_MESSAGE_SIZE
+	}
+
+	o.acc = acc
+
+	if o.ServiceAddress != "" {
+		// the gRPC server modifies its TLS config.
+		tlsConfig, err := internal.GetServerTLSConfig(
+			o.SSLCert, o.SSLKey, o.SSLAllowedClientCA)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", o.ServiceAddress)
+		if err != nil {
+			return err
+		}
+		o.grpcServer = newGRPCServer(listener, tlsConfig, http.HandlerFunc(o.serveGRPC))
+		o.grpcServer.start()
+		log.Printf("I! Started OTLP/gRPC receiver on %s\n", o.ServiceAddress)
+	}
+
+	if o.HTTPServiceAddress != "" {
+		tlsConfig, err := internal.GetServerTLSConfig(
+			o.SSLCert, o.SSLKey, o.SSLAllowedClientCA)
+		if err != nil {
+			o.stop()
+			return err
+		}
+		listener, err := net.Listen("tcp", o.HTTPServiceAddress)
+		if err != nil {
+			o.stop()
+			return err
+		}
+		if tlsConfig != nil {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		o.httpListener = listener
+
+		o.handlersMu.Lock()
+		o.stopped = false
+		o.handlersMu.Unlock()
+
+		server := http.Server{Handler: o}
+		o.wg.Add(1)
+		go func() {
+			defer o.wg.Done()
+			server.Serve(listener)
+		}()
+		log.Printf("I! Started OTLP/HTTP receiver on %s\n", o.HTTPServiceAddress)
+	}
+
+	return nil
+}
+
+// Stop cleans up all resources
+func (o *OpenTelemetry) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.stop()
+	log.Println("I! Stopped OpenTelemetry receivers")
+}
+
+func (o *OpenTelemetry) stop() {
+	if o.grpcServer != nil {
+		o.grpcServer.close()
+		o.grpcServer = nil
+	}
+	if o.httpListener != nil {
+		o.httpListener.Close()
+		o.httpListener = nil
+	}
+	o.wg.Wait()
+
+	// the open connections may still send requests, they are rejected from
+	// now on.
+	o.handlersMu.Lock()
+	o.stopped = true
+	o.handlersMu.Unlock()
+	o.handlers.Wait()
+}
+
+// export adds the data points of an export request. The data points without
+// a value or with a NaN value are dropped, the others are always accepted.
+func (o *OpenTelemetry) export(req *otlp.ExportMetricsServiceRequest) *otlp.ExportMetricsServiceResponse {
+	for _, rm := range req.ResourceMetrics {
+		resourceTags := o.resourceTags(rm.Resource)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				o.addMetric(m, resourceTags)
+			}
+		}
+	}
+	return &otlp.ExportMetricsServiceResponse{}
+}
+
+func (o *OpenTelemetry) resourceTags(r *otlp.Resource) map[string]string {
+	tags := make(map[string]string)
+	if r == nil || len(o.ResourceTags) == 0 {
+		return tags
+	}
+	for _, kv := range r.Attributes {
+		for _, k := range o.ResourceTags {
+			if kv.Key != k {
+				continue
+			}
+			if v, ok := attributeValue(kv.Value); ok {
+				tags[k] = v
+			}
+		}
+	}
+	return tags
+}
+
+// addMetric adds a metric for each data point, named after the OpenTelemetry
+// metric. Gauges and sums have a "value" field, the cumulative monotonic sums
+// being counters. Histograms and summaries have the fields of the histograms and
+// summaries of the prometheus input.
+func (o *OpenTelemetry) addMetric(m *otlp.Metric, resourceTags map[string]string) {
+	switch {
+	case m.Gauge != nil:
+		for _, p := range m.Gauge.DataPoints {
+			o.addNumberDataPoint(m.Name, p, resourceTags, false)
+		}
+	case m.Sum != nil:
+		for _, p := range m.Sum.DataPoints {
+			o.addNumberDataPoint(m.Name, p, resourceTags, isCounter(m.Sum))
+		}
+	case m.Histogram != nil:
+		for _, p := range m.Histogram.DataPoints {
+			o.DataPointsRecv.Incr(1)
+			o.acc.AddFields(m.Name, histogramFields(p),
+				tagsOf(resourceTags, p.Attributes), timeOf(p.TimeUnixNano))
+		}
+	case m.Summary != nil:
+		for _, p := range m.Summary.DataPoints {
+			o.DataPointsRecv.Incr(1)
+			o.acc.AddFields(m.Name, summaryFields(p),
+				tagsOf(resourceTags, p.Attributes), timeOf(p.TimeUnixNano))
+		}
+	}
+}
+
+// isCounter reports whether the sum is a counter: the delta sums hold the
+// change since the previous data point, they are added as gauges.
+func isCounter(s *otlp.Sum) bool {
+	return s.IsMonotonic &&
+		s.AggregationTemporality == otlp.AggregationTemporalityCumulative
+}
+
+func (o *OpenTelemetry) addNumberDataPoint(
+	name string,
+	p *otlp.NumberDataPoint,
+	resourceTags map[string]string,
+	counter bool,
+) {
+	switch v := p.Value.(type) {
+	case float64:
+		if math.IsNaN(v) {
+			o.DataPointsDropped.Incr(1)
+			return
+		}
+	case int64:
+	default:
+		o.DataPointsDropped.Incr(1)
+		return
+	}
+	o.DataPointsRecv.Incr(1)
+
+	fields := map[string]interface{}{"value": p.Value}
+	tags := tagsOf(resourceTags, p.Attributes)
+	if counter {
+		o.acc.AddCounter(name, fields, tags, timeOf(p.TimeUnixNano))
+	} else {
+		o.acc.AddGauge(name, fields, tags, timeOf(p.TimeUnixNano))
+	}
+}
+
+// histogramFields returns the cumulative count of each bucket in a field
+// named after its upper bound, "+Inf" included, along with the "count" and
+// "sum" fields.
+func histogramFields(p *otlp.HistogramDataPoint) map[string]interface{} {
+	fields := map[string]interface{}{
+		"count": float64(p.Count),
+	}
+	if p.Sum != nil {
+		fields["sum"] = *p.Sum
+	}
+	// the bucket counts are optional.
+	if len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+		return fields
+	}
+
+	var cumulative uint64
+	for i, bound := range p.ExplicitBounds {
+		cumulative += p.BucketCounts[i]
+		fields[fmt.Sprint(bound)] = float64(cumulative)
+	}
+	fields["+Inf"] = float64(cumulative + p.BucketCounts[len(p.ExplicitBounds)])
+	return fields
+}
+
+// summaryFields returns the value of each quantile in a field named after the
+// quantile, along with the "count" and "sum" fields.
+func summaryFields(p *otlp.SummaryDataPoint) map[string]interface{} {
+	fields := map[string]interface{}{
+		"count": float64(p.Count),
+		"sum":   p.Sum,
+	}
+	for _, q := range p.QuantileValues {
+		if !math.IsNaN(q.Value) {
+			fields[fmt.Sprint(q.Quantile)] = q.Value
+		}
+	}
+	return fields
+}
+
+// tagsOf returns the tags of a data point, from the resource tags and the
+// attributes of the data point.
+func tagsOf(resourceTags map[string]string, attrs []*otlp.KeyValue) map[string]string {
+	tags := make(map[string]string, len(resourceTags)+len(attrs))
+	for k, v := range resourceTags {
+		tags[k] = v
+	}
+	for _, kv := range attrs {
+		if v, ok := attributeValue(kv.Value); ok {
+			tags[kv.Key] = v
+		}
+	}
+	return tags
+}
+
+// attributeValue returns the value of an attribute as a tag value, the bytes,
+// array and key-value list attributes are skipped.
+func attributeValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// timeOf returns the time of a data point, the current time if it is not
+// set.
+func timeOf(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, int64(ts))
+}
+
+func init() {
+	inputs.Add("opentelemetry", func() telegraf.Input {
+		return &OpenTelemetry{
+			ServiceAddress:     ":4317",
+			HTTPServiceAddress: ":4318",
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/otlp"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// accumulator records the measurements added as counters.
+type accumulator struct {
+	testutil.Accumulator
+	counters []string
+}
+
+func (a *accumulator) AddCounter(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	a.Lock()
+	a.counters = append(a.counters, measurement)
+	a.Unlock()
+	a.Accumulator.AddCounter(measurement, fields, tags, t...)
+}
+
+func newTestOpenTelemetry() *OpenTelemetry {
+	return &OpenTelemetry{
+		ServiceAddress:     "localhost:0",
+		HTTPServiceAddress: "localhost:0",
+	}
+}
+
+func grpcURL(o *OpenTelemetry) string {
+	return "http://" + o.grpcServer.listener.Addr().String()
+}
+
+func httpURL(o *OpenTelemetry) string {
+	return "http://" + o.httpListener.Addr().String()
+}
+
+// grpcPost sends a gRPC call without TLS, with HTTP/2 prior knowledge.
+func grpcPost(t *testing.T, url string, body []byte) *http.Response {
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", otlp.GRPCContentType)
+	req.Header.Set("TE", "trailers")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func grpcExport(t *testing.T, o *OpenTelemetry, req *otlp.ExportMetricsServiceRequest) {
+	msg, err := req.Marshal()
+	require.NoError(t, err)
+	var body bytes.Buffer
+	require.NoError(t, otlp.WriteFrame(&body, msg, true))
+
+	resp := grpcPost(t, grpcURL(o)+otlp.GRPCExportPath, body.Bytes())
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	respMsg, err := otlp.ReadFrame(resp.Body, 1024)
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+	require.NoError(t, (&otlp.ExportMetricsServiceResponse{}).Unmarshal(respMsg))
+}
+
+func testRequest() *otlp.ExportMetricsServiceRequest {
+	sum := 12.5
+	return &otlp.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlp.ResourceMetrics{
+			{
+				Resource: &otlp.Resource{
+					Attributes: []*otlp.KeyValue{
+						{Key: "service.name", Value: "checkout"},
+						{Key: "service.version", Value: "1.2.0"},
+					},
+				},
+				ScopeMetrics: []*otlp.ScopeMetrics{
+					{
+						Scope: &otlp.InstrumentationScope{Name: "app"},
+						Metrics: []*otlp.Metric{
+							{
+								Name: "queue_size",
+								Gauge: &otlp.Gauge{
+									DataPoints: []*otlp.NumberDataPoint{
+										{
+											Attributes: []*otlp.KeyValue{
+												{Key: "queue", Value: "orders"},
+											},
+											TimeUnixNano: 1500000000000000000,
+											Value:        3.5,
+										},
+									},
+								},
+							},
+							{
+								Name: "requests",
+								Sum: &otlp.Sum{
+									AggregationTemporality: otlp.AggregationTemporalityCumulative,
+									IsMonotonic:            true,
+									DataPoints: []*otlp.NumberDataPoint{
+										{
+											Attributes: []*otlp.KeyValue{
+												{Key: "code", Value: int64(200)},
+												{Key: "cached", Value: true},
+											},
+											TimeUnixNano: 1500000000000000000,
+											Value:        int64(42),
+										},
+									},
+								},
+							},
+							{
+								Name: "duration",
+								Histogram: &otlp.Histogram{
+									AggregationTemporality: otlp.AggregationTemporalityCumulative,
+									DataPoints: []*otlp.HistogramDataPoint{
+										{
+											TimeUnixNano:   1500000000000000000,
+											Count:          6,
+											Sum:            &sum,
+											BucketCounts:   []uint64{1, 2, 3},
+											ExplicitBounds: []float64{0.5, 1},
+										},
+									},
+								},
+							},
+							{
+								Name: "latency",
+								Summary: &otlp.Summary{
+									DataPoints: []*otlp.SummaryDataPoint{
+										{
+											TimeUnixNano: 1500000000000000000,
+											Count:        10,
+											Sum:          4,
+											QuantileValues: []*otlp.ValueAtQuantile{
+												{Quantile: 0.5, Value: 0.3},
+												{Quantile: 0.99, Value: 0.9},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func assertTestRequestMetrics(t *testing.T, acc *accumulator) {
+	ts := time.Unix(0, 1500000000000000000)
+	require.Len(t, acc.Metrics, 4)
+
+	assert.Equal(t, &testutil.Metric{
+		Measurement: "queue_size",
+		Tags:        map[string]string{"service.name": "checkout", "queue": "orders"},
+		Fields:      map[string]interface{}{"value": 3.5},
+		Time:        ts,
+	}, acc.Metrics[0])
+	assert.Equal(t, &testutil.Metric{
+		Measurement: "requests",
+		Tags: map[string]string{
+			"service.name": "checkout",
+			"code":         "200",
+			"cached":       "true",
+		},
+		Fields: map[string]interface{}{"value": int64(42)},
+		Time:   ts,
+	}, acc.Metrics[1])
+	assert.Equal(t, &testutil.Metric{
+		Measurement: "duration",
+		Tags:        map[string]string{"service.name": "checkout"},
+		Fields: map[string]interface{}{
+			"0.5":   float64(1),
+			"1":     float64(3),
+			"+Inf":  float64(6),
+			"count": float64(6),
+			"sum":   12.5,
+		},
+		Time: ts,
+	}, acc.Metrics[2])
+	assert.Equal(t, &testutil.Metric{
+		Measurement: "latency",
+		Tags:        map[string]string{"service.name": "checkout"},
+		Fields: map[string]interface{}{
+			"0.5":   0.3,
+			"0.99":  0.9,
+			"count": float64(10),
+			"sum":   float64(4),
+		},
+		Time: ts,
+	}, acc.Metrics[3])
+
+	assert.Equal(t, []string{"requests"}, acc.counters)
+}
+
+func TestExportGRPC(t *testing.T) {
+	o := newTestOpenTelemetry()
+	o.ResourceTags = []string{"service.name"}
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+
+	grpcExport(t, o, testRequest())
+	assertTestRequestMetrics(t, acc)
+}
+
+func TestExportHTTP(t *testing.T) {
+	o := newTestOpenTelemetry()
+	o.ResourceTags = []string{"service.name"}
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+
+	msg, err := testRequest().Marshal()
+	require.NoError(t, err)
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	zw.Write(msg)
+	require.NoError(t, zw.Close())
+
+	req, err := http.NewRequest("POST", httpURL(o)+otlp.HTTPMetricsPath, &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", otlp.ProtobufContentType)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, otlp.ProtobufContentType, resp.Header.Get("Content-Type"))
+	assertTestRequestMetrics(t, acc)
+}
+
+func TestExportHTTPErrors(t *testing.T) {
+	o := newTestOpenTelemetry()
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+	url := httpURL(o) + otlp.HTTPMetricsPath
+	badRequests := o.BadRequests.Get()
+
+	resp, err := http.Post(httpURL(o)+"/v1/traces", otlp.ProtobufContentType, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(url, "application/json", bytes.NewBufferString("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, err = http.Post(url, otlp.ProtobufContentType, bytes.NewBufferString("invalid"))
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var status otlp.Status
+	require.NoError(t, status.Unmarshal(body))
+	assert.Equal(t, otlp.InvalidArgument, status.Code)
+	assert.Contains(t, status.Message, "invalid export request")
+
+	assert.Empty(t, acc.Metrics)
+	assert.Equal(t, badRequests+1, o.BadRequests.Get())
+}
+
+func TestExportGRPCErrors(t *testing.T) {
+	o := newTestOpenTelemetry()
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+
+	resp := grpcPost(t, grpcURL(o)+"/opentelemetry.proto.collector.trace.v1.TraceService/Export", nil)
+	resp.Body.Close()
+	assert.Equal(t, "12", resp.Header.Get("Grpc-Status"))
+
+	var body bytes.Buffer
+	require.NoError(t, otlp.WriteFrame(&body, []byte("invalid"), false))
+	resp = grpcPost(t, grpcURL(o)+otlp.GRPCExportPath, body.Bytes())
+	resp.Body.Close()
+	assert.Equal(t, "3", resp.Header.Get("Grpc-Status"))
+	assert.Contains(t, otlp.DecodeGRPCMessage(resp.Header.Get("Grpc-Message")),
+		"invalid export request")
+
+	o.MaxMessageSize = 4
+	body.Reset()
+	require.NoError(t, otlp.WriteFrame(&body, make([]byte, 5), false))
+	resp = grpcPost(t, grpcURL(o)+otlp.GRPCExportPath, body.Bytes())
+	resp.Body.Close()
+	assert.Equal(t, "3", resp.Header.Get("Grpc-Status"))
+
+	assert.Empty(t, acc.Metrics)
+}
+
+func TestDroppedDataPoints(t *testing.T) {
+	o := newTestOpenTelemetry()
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+	// the stats are shared by the receivers on the same address.
+	received := o.DataPointsRecv.Get()
+	dropped := o.DataPointsDropped.Get()
+
+	grpcExport(t, o, &otlp.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlp.ResourceMetrics{
+			{
+				ScopeMetrics: []*otlp.ScopeMetrics{
+					{
+						Metrics: []*otlp.Metric{
+							{
+								Name: "temperature",
+								Gauge: &otlp.Gauge{
+									DataPoints: []*otlp.NumberDataPoint{
+										{Value: math.NaN()},
+										{Value: 21.5},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	// a data point without a value cannot be encoded.
+	o.addMetric(&otlp.Metric{
+		Name:  "temperature",
+		Gauge: &otlp.Gauge{DataPoints: []*otlp.NumberDataPoint{{}}},
+	}, nil)
+
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, map[string]interface{}{"value": 21.5}, acc.Metrics[0].Fields)
+	// the time is set when the data point has none.
+	assert.False(t, acc.Metrics[0].Time.IsZero())
+	assert.Equal(t, received+1, o.DataPointsRecv.Get())
+	assert.Equal(t, dropped+2, o.DataPointsDropped.Get())
+}
+
+func TestDeltaSums(t *testing.T) {
+	o := newTestOpenTelemetry()
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+	defer o.Stop()
+
+	o.addMetric(&otlp.Metric{
+		Name: "requests",
+		Sum: &otlp.Sum{
+			AggregationTemporality: otlp.AggregationTemporalityDelta,
+			IsMonotonic:            true,
+			DataPoints: []*otlp.NumberDataPoint{
+				{TimeUnixNano: 1500000000000000000, Value: int64(3)},
+			},
+		},
+	}, nil)
+
+	// the delta sums are not running totals, they are added as gauges.
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, "requests", acc.Metrics[0].Measurement)
+	assert.Equal(t, map[string]interface{}{"value": int64(3)}, acc.Metrics[0].Fields)
+	assert.Empty(t, acc.counters)
+}
+
+func TestHistogramFieldsWithoutBuckets(t *testing.T) {
+	fields := histogramFields(&otlp.HistogramDataPoint{Count: 3})
+	assert.Equal(t, map[string]interface{}{"count": float64(3)}, fields)
+}
+
+func TestStartErrors(t *testing.T) {
+	o := &OpenTelemetry{}
+	assert.Error(t, o.Start(&accumulator{}))
+
+	o = newTestOpenTelemetry()
+	o.SSLCert = "/nonexistent/cert.pem"
+	assert.Error(t, o.Start(&accumulator{}))
+}
+
+func TestStopRejectsRequests(t *testing.T) {
+	o := newTestOpenTelemetry()
+	acc := &accumulator{}
+	require.NoError(t, o.Start(acc))
+
+	msg, err := testRequest().Marshal()
+	require.NoError(t, err)
+
+	// the connection is kept alive after Stop
+	client := &http.Client{Transport: &http.Transport{}}
+	url := httpURL(o) + otlp.HTTPMetricsPath
+	resp, err := client.Post(url, otlp.ProtobufContentType, bytes.NewReader(msg))
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	o.Stop()
+
+	resp, err = client.Post(url, otlp.ProtobufContentType, bytes.NewReader(msg))
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	assert.Equal(t, uint64(4), acc.NMetrics())
+}
@@ -0,0 +1,274 @@
+package opentelemetry
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf/internal/otlp"
+
+	"golang.org/x/net/http2"
+)
+
+// grpcServer serves unary gRPC calls over HTTP/2. Without TLS, the clients
+// connect with prior knowledge of HTTP/2.
+type grpcServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	server    *http2.Server
+	handler   http.Handler
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newGRPCServer returns a server accepting the connections of the listener,
+// the TLS config, if any, is modified to negotiate HTTP/2.
+func newGRPCServer(listener net.Listener, tlsConfig *tls.Config, handler http.Handler) *grpcServer {
+	if tlsConfig != nil {
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+	}
+	return &grpcServer{
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		server:    &http2.Server{},
+		handler:   handler,
+		conns:     make(map[net.Conn]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *grpcServer) start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.serve()
+	}()
+}
+
+func (s *grpcServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				log.Printf("E! Error accepting OTLP/gRPC connection: %s", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		select {
+		case <-s.done:
+			// accepted while closing.
+			s.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+func (s *grpcServer) serveConn(conn net.Conn) {
+	if s.tlsConfig != nil {
+		tlsConn := tls.Server(conn, s.tlsConfig)
+		// the handshake must be done before serving, as the server checks
+		// the negotiated protocol.
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf("E! OTLP/gRPC TLS handshake with %s failed: %s",
+				conn.RemoteAddr(), err)
+			return
+		}
+		conn = tlsConn
+	}
+	s.server.ServeConn(conn, &http2.ServeConnOpts{Handler: s.handler})
+}
+
+// close stops accepting connections, closes the open connections and waits
+// for their calls to complete.
+func (s *grpcServer) close() {
+	s.mu.Lock()
+	close(s.done)
+	s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// serveGRPC handles the calls of the Export method of the metrics service.
+func (o *OpenTelemetry) serveGRPC(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		res.Header().Set("Allow", "POST")
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), otlp.GRPCContentType) {
+		http.Error(res, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	res.Header().Set("Content-Type", otlp.GRPCContentType)
+	res.Header().Set("Grpc-Accept-Encoding", "gzip")
+
+	if req.URL.Path != otlp.GRPCExportPath {
+		grpcError(res, otlp.Unimplemented, "unknown method "+req.URL.Path)
+		return
+	}
+	if enc := req.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" && enc != "gzip" {
+		grpcError(res, otlp.Unimplemented, "unsupported compression "+enc)
+		return
+	}
+	o.RequestsRecv.Incr(1)
+
+	msg, err := otlp.ReadFrame(req.Body, o.MaxMessageSize)
+	if err != nil && err != io.EOF {
+		o.BadRequests.Incr(1)
+		grpcError(res, otlp.InvalidArgument, err.Error())
+		return
+	}
+	exportReq := &otlp.ExportMetricsServiceRequest{}
+	if err := exportReq.Unmarshal(msg); err != nil {
+		o.BadRequests.Incr(1)
+		grpcError(res, otlp.InvalidArgument, fmt.Sprintf("invalid export request: %s", err))
+		return
+	}
+
+	respMsg, err := o.export(exportReq).Marshal()
+	if err != nil {
+		grpcError(res, otlp.Internal, err.Error())
+		return
+	}
+
+	// the status is sent in the trailers, after the response.
+	res.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	res.WriteHeader(http.StatusOK)
+	if err := otlp.WriteFrame(res, respMsg, false); err != nil {
+		return
+	}
+	res.Header().Set("Grpc-Status", "0")
+}
+
+// grpcError sends a response without a body, with the status in the headers.
+func grpcError(res http.ResponseWriter, code otlp.Code, msg string) {
+	res.Header().Set("Grpc-Status", strconv.FormatUint(uint64(code), 10))
+	res.Header().Set("Grpc-Message", otlp.EncodeGRPCMessage(msg))
+	res.WriteHeader(http.StatusOK)
+}
+
+// ServeHTTP handles the OTLP/HTTP requests, in the protobuf encoding.
+func (o *OpenTelemetry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	o.handlersMu.Lock()
+	if o.stopped {
+		o.handlersMu.Unlock()
+		res.Header().Set("Connection", "close")
+		httpError(res, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	o.handlers.Add(1)
+	o.handlersMu.Unlock()
+	defer o.handlers.Done()
+
+	o.serveHTTP(res, req)
+}
+
+func (o *OpenTelemetry) serveHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != otlp.HTTPMetricsPath {
+		http.NotFound(res, req)
+		return
+	}
+	if req.Method != "POST" {
+		res.Header().Set("Allow", "POST")
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), otlp.ProtobufContentType) {
+		httpError(res, http.StatusUnsupportedMediaType,
+			"unsupported content type, only "+otlp.ProtobufContentType+" is supported")
+		return
+	}
+	o.RequestsRecv.Incr(1)
+
+	body := io.Reader(http.MaxBytesReader(res, req.Body, int64(o.MaxMessageSize)))
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			o.BadRequests.Incr(1)
+			httpError(res, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer zr.Close()
+		// limit the decompressed size as well.
+		body = io.LimitReader(zr, int64(o.MaxMessageSize)+1)
+	default:
+		httpError(res, http.StatusUnsupportedMediaType, "unsupported content encoding")
+		return
+	}
+
+	msg, err := ioutil.ReadAll(body)
+	if err != nil {
+		o.BadRequests.Incr(1)
+		httpError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(msg) > o.MaxMessageSize {
+		o.BadRequests.Incr(1)
+		httpError(res, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	exportReq := &otlp.ExportMetricsServiceRequest{}
+	if err := exportReq.Unmarshal(msg); err != nil {
+		o.BadRequests.Incr(1)
+		httpError(res, http.StatusBadRequest, fmt.Sprintf("invalid export request: %s", err))
+		return
+	}
+
+	respMsg, err := o.export(exportReq).Marshal()
+	if err != nil {
+		httpError(res, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res.Header().Set("Content-Type", otlp.ProtobufContentType)
+	res.WriteHeader(http.StatusOK)
+	res.Write(respMsg)
+}
+
+// httpError sends an error with a status in the body, as OTLP/HTTP clients
+// expect.
+func httpError(res http.ResponseWriter, code int, msg string) {
+	status := &otlp.Status{Code: otlp.InvalidArgument, Message: msg}
+	if code >= 500 {
+		status.Code = otlp.Internal
+	}
+	body, _ := status.Marshal()
+	res.Header().Set("Content-Type", otlp.ProtobufContentType)
+	res.WriteHeader(code)
+	res.Write(body)
+}
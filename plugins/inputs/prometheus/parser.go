@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/prompb"
 	"github.com/influxdata/telegraf/metric"
 
 	"github.com/matttproud/golang_protobuf_extensions/pbutil"
@@ -121,7 +122,7 @@ func makeBuckets(m *dto.Metric) map[string]interface{} {
 func makeLabels(m *dto.Metric) map[string]string {
 	result := map[string]string{}
 	for _, lp := range m.Label {
-		result[lp.GetName()] = lp.GetValue()
+		prompb.AddLabelTag(result, lp)
 	}
 	return result
 }
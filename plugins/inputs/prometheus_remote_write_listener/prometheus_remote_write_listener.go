@@ -0,0 +1,280 @@
+package prometheus_remote_write_listener
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// DEFAULT_MAX_BODY_SIZE is the default maximum request body size, in
+	// bytes, before decompression.
+	// 32 MB
+	DEFAULT_MAX_BODY_SIZE = 32 * 1024 * 1024
+)
+
+type PrometheusRemoteWriteListener struct {
+	ServiceAddress string
+	Path           string
+	ReadTimeout    internal.Duration
+	WriteTimeout   internal.Duration
+	MaxBodySize    int64
+	SplitNames     bool `toml:"split_metric_names"`
+
+	mu sync.Mutex
+	wg sync.WaitGroup
+
+	// the requests being handled, waited for on Stop, as the server can not
+	// be shut down.
+	handlersMu sync.Mutex
+	handlers   sync.WaitGroup
+	stopped    bool
+
+	listener net.Listener
+
+	acc telegraf.Accumulator
+
+	RequestsRecv   selfstat.Stat
+	BadRequests    selfstat.Stat
+	SamplesRecv    selfstat.Stat
+	StaleMarkers   selfstat.Stat
+	SamplesDropped selfstat.Stat
+}
+
+const sampleConfig = `
+  ## Address and port to host the remote write listener on
+  service_address = ":1234"
+  ## Path of the remote write endpoint
+  path = "/receive"
+
+  ## maximum duration before timing out read of the request
+  read_timeout = "10s"
+  ## maximum duration before timing out write of the response
+  write_timeout = "10s"
+
+  ## Maximum allowed size of the compressed request body in bytes.
+  ## 0 means to use the default of 33,554,432 bytes (32 mebibytes)
+  max_body_size = 0
+
+  ## Split the metric names at their first underscore into the measurement
+  ## and field names, as the prometheus_remote_write output joins them, ie
+  ## "mem_free" becomes the "free" field of the "mem" measurement. Names
+  ## without an underscore have their sample in a "value" field. If false,
+  ## the metrics are named after the Prometheus metric.
+  # split_metric_names = false
+`
+
+func (p *PrometheusRemoteWriteListener) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PrometheusRemoteWriteListener) Description() string {
+	return "Prometheus remote write listener"
+}
+
+// All the work is done in the Start() function, so this is just a dummy
+// function.
+func (p *PrometheusRemoteWriteListener) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+// Start starts the remote write listener service.
+func (p *PrometheusRemoteWriteListener) Start(acc telegraf.Accumulator) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tags := map[string]string{
+		"address": p.ServiceAddress,
+	}
+	p.RequestsRecv = selfstat.Register("prometheus_remote_write_listener", "requests_received", tags)
+	p.BadRequests = selfstat.Register("prometheus_remote_write_listener", "bad_requests", tags)
+	p.SamplesRecv = selfstat.Register("prometheus_remote_write_listener", "samples_received", tags)
+	p.StaleMarkers = selfstat.Register("prometheus_remote_write_listener", "stale_markers", tags)
+	p.SamplesDropped = selfstat.Register("prometheus_remote_write_listener", "samples_dropped", tags)
+
+	if p.MaxBodySize == 0 {
+		p.MaxBodySize = DEFAULT_MAX_BODY_SIZE
+	}
+	if p.Path == "" {
+		p.Path = "/receive"
+	}
+	if p.ReadTimeout.Duration < time.Second {
+		p.ReadTimeout.Duration = time.Second * 10
+	}
+	if p.WriteTimeout.Duration < time.Second {
+		p.WriteTimeout.Duration = time.Second * 10
+	}
+
+	p.acc = acc
+	p.stopped = false
+
+	listener, err := net.Listen("tcp", p.ServiceAddress)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+
+	server := http.Server{
+		Handler:      p,
+		ReadTimeout:  p.ReadTimeout.Duration,
+		WriteTimeout: p.WriteTimeout.Duration,
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		server.Serve(p.listener)
+	}()
+
+	log.Printf("I! Started Prometheus remote write listener service on %s\n",
+		p.ServiceAddress)
+	return nil
+}
+
+// Stop cleans up all resources
+func (p *PrometheusRemoteWriteListener) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.listener.Close()
+	p.wg.Wait()
+
+	// the open connections may still send requests, they are rejected from
+	// now on.
+	p.handlersMu.Lock()
+	p.stopped = true
+	p.handlersMu.Unlock()
+	p.handlers.Wait()
+
+	log.Println("I! Stopped Prometheus remote write listener service on ",
+		p.ServiceAddress)
+}
+
+func (p *PrometheusRemoteWriteListener) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	p.handlersMu.Lock()
+	if p.stopped {
+		p.handlersMu.Unlock()
+		res.Header().Set("Connection", "close")
+		http.Error(res, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	p.handlers.Add(1)
+	p.handlersMu.Unlock()
+	defer p.handlers.Done()
+
+	p.serveWrite(res, req)
+}
+
+func (p *PrometheusRemoteWriteListener) serveWrite(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != p.Path {
+		http.NotFound(res, req)
+		return
+	}
+	if req.Method != "POST" {
+		res.Header().Set("Allow", "POST")
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p.RequestsRecv.Incr(1)
+
+	if req.ContentLength > p.MaxBodySize {
+		p.BadRequests.Incr(1)
+		http.Error(res, "http: request body too large",
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, p.MaxBodySize))
+	if err != nil {
+		p.BadRequests.Incr(1)
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wr, err := prompb.Decode(body)
+	if err != nil {
+		p.BadRequests.Incr(1)
+		http.Error(res, fmt.Sprintf("invalid remote write request: %s", err),
+			http.StatusBadRequest)
+		return
+	}
+
+	for _, ts := range wr.Timeseries {
+		p.addTimeSeries(ts)
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// addTimeSeries adds a metric for each sample of the time series, with the
+// labels as tags. The metric is named after the Prometheus metric with a
+// "value" field, like the untyped metrics of the prometheus input, or after
+// the measurement and field names split from it.
+func (p *PrometheusRemoteWriteListener) addTimeSeries(ts *prompb.TimeSeries) {
+	var name string
+	tags := make(map[string]string, len(ts.Labels))
+	for _, l := range ts.Labels {
+		if l.GetName() == prompb.MetricNameLabel {
+			name = l.GetValue()
+		}
+		prompb.AddLabelTag(tags, l)
+	}
+	if name == "" {
+		p.SamplesDropped.Incr(int64(len(ts.Samples)))
+		return
+	}
+
+	field := "value"
+	if p.SplitNames {
+		if i := strings.Index(name, "_"); i > 0 && i < len(name)-1 {
+			name, field = name[:i], name[i+1:]
+		}
+	}
+
+	for _, s := range ts.Samples {
+		t := time.Unix(0, s.Timestamp*int64(time.Millisecond))
+		switch {
+		case math.Float64bits(s.Value) == prompb.StaleNaN:
+			// the series has ended
+			p.StaleMarkers.Incr(1)
+			p.acc.AddFields(name,
+				map[string]interface{}{metric.StaleField: true}, copyTags(tags), t)
+		case math.IsNaN(s.Value):
+			p.SamplesDropped.Incr(1)
+		default:
+			p.SamplesRecv.Incr(1)
+			p.acc.AddFields(name,
+				map[string]interface{}{field: s.Value}, copyTags(tags), t)
+		}
+	}
+}
+
+// copyTags copies the tags, as the accumulator may modify them.
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+func init() {
+	inputs.Add("prometheus_remote_write_listener", func() telegraf.Input {
+		return &PrometheusRemoteWriteListener{
+			ServiceAddress: ":1234",
+			Path:           "/receive",
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package prometheus_remote_write_listener
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListener() *PrometheusRemoteWriteListener {
+	return &PrometheusRemoteWriteListener{
+		ServiceAddress: "localhost:0",
+		Path:           "/receive",
+	}
+}
+
+func url(p *PrometheusRemoteWriteListener) string {
+	return "http://" + p.listener.Addr().String() + p.Path
+}
+
+func post(t *testing.T, p *PrometheusRemoteWriteListener, wr *prompb.WriteRequest) *http.Response {
+	body, err := prompb.Encode(wr)
+	require.NoError(t, err)
+	resp, err := http.Post(url(p), prompb.ContentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestWriteRequest(t *testing.T) {
+	listener := newTestListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp := post(t, listener, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{
+					{Name: "__name__", Value: "go_goroutines"},
+					{Name: "instance", Value: "localhost:9090"},
+					{Name: "job", Value: "prometheus"},
+				},
+				Samples: []*prompb.Sample{
+					{Value: 42, Timestamp: 1487067700000},
+					{Value: 43, Timestamp: 1487067710000},
+				},
+			},
+		},
+	})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	require.Len(t, acc.Metrics, 2)
+	tags := map[string]string{"instance": "localhost:9090", "job": "prometheus"}
+	m := acc.Metrics[0]
+	assert.Equal(t, "go_goroutines", m.Measurement)
+	assert.Equal(t, tags, m.Tags)
+	assert.Equal(t, map[string]interface{}{"value": float64(42)}, m.Fields)
+	assert.Equal(t, time.Unix(1487067700, 0), m.Time)
+
+	m = acc.Metrics[1]
+	assert.Equal(t, tags, m.Tags)
+	assert.Equal(t, map[string]interface{}{"value": float64(43)}, m.Fields)
+	assert.Equal(t, time.Unix(1487067710, 0), m.Time)
+}
+
+func TestStaleAndNaNSamples(t *testing.T) {
+	listener := newTestListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp := post(t, listener, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{
+					{Value: math.NaN(), Timestamp: 1487067700000},
+					{Value: math.Float64frombits(prompb.StaleNaN), Timestamp: 1487067710000},
+				},
+			},
+			{
+				// no metric name
+				Labels:  []*prompb.Label{{Name: "job", Value: "prometheus"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1487067700000}},
+			},
+		},
+	})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	require.Len(t, acc.Metrics, 1)
+	m := acc.Metrics[0]
+	assert.Equal(t, "up", m.Measurement)
+	assert.Equal(t, map[string]interface{}{"_stale": true}, m.Fields)
+	assert.Equal(t, time.Unix(1487067710, 0), m.Time)
+}
+
+func TestSplitMetricNames(t *testing.T) {
+	listener := newTestListener()
+	listener.SplitNames = true
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp := post(t, listener, &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{
+					{Name: "__name__", Value: "cpu_usage_idle"},
+					{Name: "host", Value: "a"},
+				},
+				Samples: []*prompb.Sample{
+					{Value: 98, Timestamp: 1487067700000},
+					{Value: math.Float64frombits(prompb.StaleNaN), Timestamp: 1487067710000},
+				},
+			},
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1487067700000}},
+			},
+		},
+	})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	require.Len(t, acc.Metrics, 3)
+	m := acc.Metrics[0]
+	assert.Equal(t, "cpu", m.Measurement)
+	assert.Equal(t, map[string]string{"host": "a"}, m.Tags)
+	assert.Equal(t, map[string]interface{}{"usage_idle": float64(98)}, m.Fields)
+
+	m = acc.Metrics[1]
+	assert.Equal(t, "cpu", m.Measurement)
+	assert.Equal(t, map[string]interface{}{"_stale": true}, m.Fields)
+
+	m = acc.Metrics[2]
+	assert.Equal(t, "up", m.Measurement)
+	assert.Equal(t, map[string]interface{}{"value": float64(1)}, m.Fields)
+}
+
+func TestStopRejectsRequests(t *testing.T) {
+	listener := newTestListener()
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+
+	body, err := prompb.Encode(&prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "up"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 1487067700000}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// the connection is kept alive after Stop
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Post(url(listener), prompb.ContentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	addr := url(listener)
+	listener.Stop()
+
+	resp, err = client.Post(addr, prompb.ContentType, bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	assert.Equal(t, uint64(1), acc.NMetrics())
+}
+
+func TestBadRequests(t *testing.T) {
+	listener := newTestListener()
+	listener.MaxBodySize = 64
+	acc := &testutil.Accumulator{}
+	require.NoError(t, listener.Start(acc))
+	defer listener.Stop()
+
+	resp, err := http.Post(url(listener), prompb.ContentType,
+		bytes.NewBufferString("not snappy"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(url(listener), prompb.ContentType,
+		bytes.NewReader(make([]byte, 128)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp, err = http.Get(url(listener))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post("http://"+listener.listener.Addr().String()+"/write",
+		prompb.ContentType, bytes.NewBufferString(""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	assert.Empty(t, acc.Metrics)
+}
@@ -0,0 +1,400 @@
+package socket_listener
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+type setReadBufferer interface {
+	SetReadBuffer(bytes int) error
+}
+
+type streamSocketListener struct {
+	net.Listener
+	*SocketListener
+
+	sockType string
+
+	connections    map[net.Conn]struct{}
+	connectionsMtx sync.Mutex
+}
+
+func (ssl *streamSocketListener) listen() {
+	defer ssl.wg.Done()
+	ssl.connections = map[net.Conn]struct{}{}
+
+	for {
+		c, err := ssl.Accept()
+		if err != nil {
+			select {
+			case <-ssl.done:
+			default:
+				ssl.AddError(err)
+			}
+			break
+		}
+
+		if ssl.ReadBufferSize > 0 {
+			if srb, ok := c.(setReadBufferer); ok {
+				srb.SetReadBuffer(ssl.ReadBufferSize)
+			} else {
+				log.Printf("W! Unable to set read buffer on a %s socket\n",
+					ssl.sockType)
+			}
+		}
+
+		if tcpc, ok := c.(*net.TCPConn); ok {
+			if err := ssl.setKeepAlive(tcpc); err != nil {
+				ssl.AddError(fmt.Errorf("unable to configure keep alive (%s): %s",
+					ssl.ServiceAddress, err))
+			}
+		}
+
+		if ssl.tlsConfig != nil {
+			c = tls.Server(c, ssl.tlsConfig)
+		}
+
+		ssl.connectionsMtx.Lock()
+		if ssl.MaxConnections > 0 && len(ssl.connections) >= ssl.MaxConnections {
+			ssl.connectionsMtx.Unlock()
+			c.Close()
+			ssl.ConnectionsRefused.Incr(1)
+			log.Printf("W! Refused connection from %s, maximum of %d "+
+				"connections reached on %s\n",
+				c.RemoteAddr(), ssl.MaxConnections, ssl.ServiceAddress)
+			continue
+		}
+		// the connections are not keyed by remote address, as unix
+		// sockets share the same one.
+		ssl.connections[c] = struct{}{}
+		ssl.CurrentConnections.Set(int64(len(ssl.connections)))
+		ssl.connectionsMtx.Unlock()
+
+		ssl.wg.Add(1)
+		go ssl.read(c)
+	}
+
+	ssl.connectionsMtx.Lock()
+	for c := range ssl.connections {
+		c.Close()
+	}
+	ssl.connectionsMtx.Unlock()
+}
+
+func (ssl *streamSocketListener) setKeepAlive(c *net.TCPConn) error {
+	if ssl.KeepAlivePeriod == nil {
+		return nil
+	}
+	if ssl.KeepAlivePeriod.Duration == 0 {
+		return c.SetKeepAlive(false)
+	}
+	if err := c.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return c.SetKeepAlivePeriod(ssl.KeepAlivePeriod.Duration)
+}
+
+func (ssl *streamSocketListener) removeConnection(c net.Conn) {
+	ssl.connectionsMtx.Lock()
+	delete(ssl.connections, c)
+	ssl.CurrentConnections.Set(int64(len(ssl.connections)))
+	ssl.connectionsMtx.Unlock()
+}
+
+func (ssl *streamSocketListener) read(c net.Conn) {
+	defer ssl.wg.Done()
+	defer ssl.removeConnection(c)
+	defer c.Close()
+
+	scnr := bufio.NewScanner(c)
+	if ssl.ReadBufferSize > bufio.MaxScanTokenSize {
+		scnr.Buffer(make([]byte, 0, ssl.ReadBufferSize), ssl.ReadBufferSize)
+	}
+	for {
+		if ssl.ReadTimeout != nil && ssl.ReadTimeout.Duration > 0 {
+			c.SetReadDeadline(time.Now().Add(ssl.ReadTimeout.Duration))
+		}
+		if !scnr.Scan() {
+			break
+		}
+		ssl.parse(scnr.Bytes())
+	}
+
+	if err := scnr.Err(); err != nil {
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			log.Printf("D! Closing idle connection from %s on %s\n",
+				c.RemoteAddr(), ssl.ServiceAddress)
+			return
+		}
+		select {
+		case <-ssl.done:
+		default:
+			ssl.AddError(err)
+		}
+	}
+}
+
+type packetSocketListener struct {
+	net.PacketConn
+	*SocketListener
+}
+
+func (psl *packetSocketListener) listen() {
+	defer psl.wg.Done()
+
+	buf := make([]byte, 64*1024) // 64kb - maximum size of IP packet
+	for {
+		n, _, err := psl.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-psl.done:
+			default:
+				psl.AddError(err)
+			}
+			break
+		}
+		psl.parse(buf[:n])
+	}
+}
+
+const sampleConfig = `
+  ## URL to listen on
+  # service_address = "tcp://:8094"
+  # service_address = "tcp://127.0.0.1:http"
+  # service_address = "tcp4://:8094"
+  # service_address = "tcp6://:8094"
+  # service_address = "tcp6://[2001:db8::1]:8094"
+  # service_address = "udp://:8094"
+  # service_address = "udp4://:8094"
+  # service_address = "udp6://:8094"
+  # service_address = "unix:///tmp/telegraf.sock"
+  # service_address = "unixgram:///tmp/telegraf.sock"
+
+  ## Maximum number of concurrent connections.
+  ## Only applies to stream sockets (e.g. TCP).
+  ## 0 (default) is unlimited.
+  # max_connections = 1024
+
+  ## Read timeout.
+  ## Only applies to stream sockets (e.g. TCP).
+  ## 0 (default) is unlimited.
+  # read_timeout = "30s"
+
+  ## Optional SSL configuration.
+  ## Only applies to stream sockets (e.g. TCP).
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key  = "/etc/telegraf/key.pem"
+  ## Enables client authentication if set.
+  # ssl_allowed_client_ca = ["/etc/telegraf/clientca.pem"]
+
+  ## Maximum socket buffer size in bytes, applied to each connection.
+  ## For stream sockets, this is also the maximum length of a line.
+  ## For stream sockets, once the buffer fills up, the sender will start
+  ## backing up. For datagram sockets, once the buffer fills up, metrics will
+  ## start dropping.
+  ## Defaults to the OS default.
+  # read_buffer_size = 65535
+
+  ## Period between keep alive probes.
+  ## Only applies to TCP sockets.
+  ## 0 disables keep alive probes.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## Data format to consume.
+  ## Stream sockets are read line by line, each line being parsed on its own,
+  ## so the formats spanning several lines require datagram sockets.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  # data_format = "influx"
+`
+
+type SocketListener struct {
+	ServiceAddress  string
+	MaxConnections  int
+	ReadBufferSize  int
+	ReadTimeout     *internal.Duration
+	KeepAlivePeriod *internal.Duration
+
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Paths to the CA files that client certificates must be signed by
+	SSLAllowedClientCA []string `toml:"ssl_allowed_client_ca"`
+
+	tlsConfig *tls.Config
+
+	parsers.Parser
+	telegraf.Accumulator
+	io.Closer
+
+	// path of the socket file for unix sockets, removed on Stop
+	socketPath string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	CurrentConnections selfstat.Stat
+	ConnectionsRefused selfstat.Stat
+	BytesRecv          selfstat.Stat
+	ParseErrors        selfstat.Stat
+}
+
+func (sl *SocketListener) Description() string {
+	return "Generic socket listener capable of handling multiple socket types."
+}
+
+func (sl *SocketListener) SampleConfig() string {
+	return sampleConfig
+}
+
+// All the work is done in the Start() function, so this is just a dummy
+// function.
+func (sl *SocketListener) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (sl *SocketListener) SetParser(parser parsers.Parser) {
+	sl.Parser = parser
+}
+
+func (sl *SocketListener) Start(acc telegraf.Accumulator) error {
+	sl.Accumulator = acc
+	spl := strings.SplitN(sl.ServiceAddress, "://", 2)
+	if len(spl) != 2 {
+		return fmt.Errorf("invalid service address: %s", sl.ServiceAddress)
+	}
+	protocol, address := spl[0], spl[1]
+
+	tags := map[string]string{
+		"address": sl.ServiceAddress,
+	}
+	sl.CurrentConnections = selfstat.Register("socket_listener", "current_connections", tags)
+	sl.ConnectionsRefused = selfstat.Register("socket_listener", "connections_refused", tags)
+	sl.BytesRecv = selfstat.Register("socket_listener", "bytes_received", tags)
+	sl.ParseErrors = selfstat.Register("socket_listener", "parse_errors", tags)
+
+	var err error
+	sl.tlsConfig, err = internal.GetServerTLSConfig(
+		sl.SSLCert, sl.SSLKey, sl.SSLAllowedClientCA)
+	if err != nil {
+		return err
+	}
+
+	if protocol == "unix" || protocol == "unixgram" {
+		// a socket file left over by a previous run would prevent listening
+		os.Remove(address)
+		sl.socketPath = address
+	}
+
+	sl.done = make(chan struct{})
+	switch protocol {
+	case "tcp", "tcp4", "tcp6", "unix":
+		l, err := net.Listen(protocol, address)
+		if err != nil {
+			return err
+		}
+
+		ssl := &streamSocketListener{
+			Listener:       l,
+			SocketListener: sl,
+			sockType:       protocol,
+		}
+
+		sl.Closer = ssl
+		sl.wg.Add(1)
+		go ssl.listen()
+	case "udp", "udp4", "udp6", "unixgram":
+		if sl.tlsConfig != nil {
+			return fmt.Errorf("ssl is not supported for %s sockets", protocol)
+		}
+
+		pc, err := net.ListenPacket(protocol, address)
+		if err != nil {
+			return err
+		}
+
+		if sl.ReadBufferSize > 0 {
+			if srb, ok := pc.(setReadBufferer); ok {
+				srb.SetReadBuffer(sl.ReadBufferSize)
+			} else {
+				log.Printf("W! Unable to set read buffer on a %s socket\n",
+					protocol)
+			}
+		}
+
+		psl := &packetSocketListener{
+			PacketConn:     pc,
+			SocketListener: sl,
+		}
+
+		sl.Closer = psl
+		sl.wg.Add(1)
+		go psl.listen()
+	default:
+		return fmt.Errorf("unknown protocol '%s' in '%s'", protocol, sl.ServiceAddress)
+	}
+
+	log.Printf("I! Started socket listener service on %s\n", sl.ServiceAddress)
+	return nil
+}
+
+// parse parses a line or a packet and adds the resulting metrics to the
+// accumulator.
+func (sl *SocketListener) parse(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+	sl.BytesRecv.Incr(int64(len(buf)))
+
+	metrics, err := sl.Parse(buf)
+	if err != nil {
+		sl.ParseErrors.Incr(1)
+		sl.AddError(fmt.Errorf("unable to parse incoming data: %s", err))
+		return
+	}
+	for _, m := range metrics {
+		sl.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+	}
+}
+
+func (sl *SocketListener) Stop() {
+	if sl.Closer != nil {
+		close(sl.done)
+		sl.Close()
+		sl.Closer = nil
+		sl.wg.Wait()
+	}
+	if sl.socketPath != "" {
+		os.Remove(sl.socketPath)
+	}
+	log.Printf("I! Stopped socket listener service on %s\n", sl.ServiceAddress)
+}
+
+func newSocketListener() *SocketListener {
+	parser, _ := parsers.NewInfluxParser()
+
+	return &SocketListener{
+		Parser: parser,
+	}
+}
+
+func init() {
+	inputs.Add("socket_listener", func() telegraf.Input { return newSocketListener() })
+}
@@ -0,0 +1,376 @@
+package socket_listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMsgs = "test,foo=bar v=1i 123456789\ntest,foo=baz v=2i 123456790\n"
+
+// waitMetrics waits for the accumulator to hold n metrics.
+func waitMetrics(acc *testutil.Accumulator, n uint64) {
+	for i := 0; i < 200 && acc.NMetrics() < n; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func assertTestMetrics(t *testing.T, acc *testutil.Accumulator) {
+	waitMetrics(acc, 2)
+	require.Equal(t, uint64(2), acc.NMetrics())
+
+	acc.Lock()
+	defer acc.Unlock()
+	m1, m2 := acc.Metrics[0], acc.Metrics[1]
+	assert.Equal(t, map[string]string{"foo": "bar"}, m1.Tags)
+	assert.Equal(t, map[string]interface{}{"v": int64(1)}, m1.Fields)
+	assert.Equal(t, time.Unix(0, 123456789), m1.Time)
+	assert.Equal(t, map[string]string{"foo": "baz"}, m2.Tags)
+	assert.Equal(t, map[string]interface{}{"v": int64(2)}, m2.Fields)
+}
+
+// assertClosed asserts that the connection is closed by the listener.
+func assertClosed(t *testing.T, c net.Conn) {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := c.Read(make([]byte, 1))
+	require.Error(t, err)
+	if nerr, ok := err.(net.Error); ok {
+		assert.False(t, nerr.Timeout())
+	}
+}
+
+func addr(sl *SocketListener) string {
+	switch l := sl.Closer.(type) {
+	case *streamSocketListener:
+		return l.Addr().String()
+	case *packetSocketListener:
+		return l.LocalAddr().String()
+	}
+	return ""
+}
+
+func TestSocketListener_tcp(t *testing.T) {
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.ReadBufferSize = 1024
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+
+	client, err := net.Dial("tcp", addr(sl))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	assertTestMetrics(t, acc)
+}
+
+func TestSocketListener_udp(t *testing.T) {
+	sl := newSocketListener()
+	sl.ServiceAddress = "udp://127.0.0.1:0"
+	sl.ReadBufferSize = 1024
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+
+	client, err := net.Dial("udp", addr(sl))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	assertTestMetrics(t, acc)
+}
+
+func TestSocketListener_unix(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "sl.TestSocketListener_unix.sock")
+
+	// a stale socket file does not prevent listening
+	f, _ := os.Create(sock)
+	f.Close()
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "unix://" + sock
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+
+	client, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+
+	_, err = client.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	assertTestMetrics(t, acc)
+
+	client.Close()
+	sl.Stop()
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSocketListener_unixClients(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "sl.TestSocketListener_unixClients.sock")
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "unix://" + sock
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+
+	// the clients all have the same remote address.
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("unix", sock)
+		require.NoError(t, err)
+		defer client.Close()
+		clients = append(clients, client)
+
+		_, err = client.Write([]byte(testMsgs))
+		require.NoError(t, err)
+	}
+	waitMetrics(acc, 4)
+	require.Equal(t, uint64(4), acc.NMetrics())
+
+	stopped := make(chan struct{})
+	go func() {
+		sl.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return with the clients connected")
+	}
+	for _, client := range clients {
+		assertClosed(t, client)
+	}
+}
+
+func TestSocketListener_unixgram(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "sl.TestSocketListener_unixgram.sock")
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "unixgram://" + sock
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+
+	client, err := net.Dial("unixgram", sock)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	assertTestMetrics(t, acc)
+}
+
+func TestSocketListener_MaxConnections(t *testing.T) {
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.MaxConnections = 1
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+	refused := sl.ConnectionsRefused.Get()
+
+	client1, err := net.Dial("tcp", addr(sl))
+	require.NoError(t, err)
+	defer client1.Close()
+	_, err = client1.Write([]byte("test,foo=bar v=1i 123456789\n"))
+	require.NoError(t, err)
+	waitMetrics(acc, 1)
+
+	// the second connection is closed by the listener
+	client2, err := net.Dial("tcp", addr(sl))
+	require.NoError(t, err)
+	defer client2.Close()
+	assertClosed(t, client2)
+	assert.Equal(t, refused+1, sl.ConnectionsRefused.Get())
+}
+
+func TestSocketListener_ReadTimeout(t *testing.T) {
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.ReadTimeout = &internal.Duration{Duration: 50 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+
+	client, err := net.Dial("tcp", addr(sl))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// the idle connection is closed by the listener
+	assertClosed(t, client)
+	assert.Empty(t, acc.Errors)
+}
+
+func TestSocketListener_ParseError(t *testing.T) {
+	sl := newSocketListener()
+	sl.ServiceAddress = "udp://127.0.0.1:0"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+	parseErrors := sl.ParseErrors.Get()
+
+	client, err := net.Dial("udp", addr(sl))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("not line protocol\n"))
+	require.NoError(t, err)
+	for i := 0; i < 200 && sl.ParseErrors.Get() == parseErrors; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, parseErrors+1, sl.ParseErrors.Get())
+}
+
+func TestSocketListener_tls(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	certs := newTestCerts(t, tmpdir)
+
+	sl := newSocketListener()
+	sl.ServiceAddress = "tcp://127.0.0.1:0"
+	sl.SSLCert = certs.serverCert
+	sl.SSLKey = certs.serverKey
+	sl.SSLAllowedClientCA = []string{certs.ca}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, sl.Start(acc))
+	defer sl.Stop()
+
+	clientCfg, err := internal.GetTLSConfig(
+		certs.clientCert, certs.clientKey, certs.ca, false)
+	require.NoError(t, err)
+	clientCfg.ServerName = "localhost"
+
+	client, err := tls.Dial("tcp", addr(sl), clientCfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte(testMsgs))
+	require.NoError(t, err)
+	assertTestMetrics(t, acc)
+
+	// clients without a certificate are rejected
+	clientCfg, err = internal.GetTLSConfig("", "", certs.ca, false)
+	require.NoError(t, err)
+	clientCfg.ServerName = "localhost"
+	client2, err := tls.Dial("tcp", addr(sl), clientCfg)
+	if err == nil {
+		defer client2.Close()
+		client2.Write([]byte(testMsgs))
+		_, err = client2.Read(make([]byte, 1))
+	}
+	assert.Error(t, err)
+	assert.Equal(t, uint64(2), acc.NMetrics())
+}
+
+func TestSocketListener_InvalidAddress(t *testing.T) {
+	acc := &testutil.Accumulator{}
+
+	sl := newSocketListener()
+	sl.ServiceAddress = ":8094"
+	assert.Error(t, sl.Start(acc))
+
+	sl = newSocketListener()
+	sl.ServiceAddress = "sctp://:8094"
+	assert.Error(t, sl.Start(acc))
+}
+
+type testCerts struct {
+	ca         string
+	serverCert string
+	serverKey  string
+	clientCert string
+	clientKey  string
+}
+
+// newTestCerts writes a CA, and a server and a client certificate signed by
+// it, to the given directory.
+func newTestCerts(t *testing.T, dir string) testCerts {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Telegraf Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl,
+		&caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	writePEM := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path,
+			pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600))
+		return path
+	}
+
+	newCert := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			DNSNames:     []string{"localhost"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert,
+			&key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return writePEM(name+".pem", "CERTIFICATE", der),
+			writePEM(name+".key", "EC PRIVATE KEY", keyDER)
+	}
+
+	certs := testCerts{ca: writePEM("ca.pem", "CERTIFICATE", caDER)}
+	certs.serverCert, certs.serverKey = newCert("server", 2, x509.ExtKeyUsageServerAuth)
+	certs.clientCert, certs.clientKey = newCert("client", 3, x509.ExtKeyUsageClientAuth)
+	return certs
+}
@@ -130,7 +130,9 @@ func (t *Tail) receiver(tailer *tail.Tail) {
 		}
 		m, err = t.parser.ParseLine(line.Text)
 		if err == nil {
-			t.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+			if m != nil {
+				t.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+			}
 		} else {
 			log.Printf("E! Malformed log line in %s: [%s], Error: %s\n",
 				tailer.Filename, line.Text, err)
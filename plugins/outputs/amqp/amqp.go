@@ -24,6 +24,8 @@ type AMQP struct {
 	AuthMethod string
 	// Routing Key Tag
 	RoutingTag string `toml:"routing_tag"`
+	// Send a message per metric or per batch
+	BatchFormat string `toml:"batch_format"`
 	// InfluxDB database
 	Database string
 	// InfluxDB retention policy
@@ -73,6 +75,13 @@ var sampleConfig = `
   ##  ie, if this tag exists, it's value will be used as the routing key
   routing_tag = "host"
 
+  ## Send a message per metric ("metric"), or a message per batch of metrics
+  ## with the same routing key serialized as a batch ("batch"), ie a JSON
+  ## array with the json data format. If unset, a message is sent per batch
+  ## of metrics with the same routing key, holding the metrics serialized one
+  ## after the other.
+  # batch_format = ""
+
   ## InfluxDB retention policy
   # retention_policy = "default"
   ## InfluxDB database
@@ -100,6 +109,10 @@ func (q *AMQP) Connect() error {
 	q.Lock()
 	defer q.Unlock()
 
+	if err := serializers.CheckBatchFormat(q.BatchFormat); err != nil {
+		return err
+	}
+
 	q.headers = amqp.Table{
 		"database":         q.Database,
 		"retention_policy": q.RetentionPolicy,
@@ -177,39 +190,82 @@ func (q *AMQP) Write(metrics []telegraf.Metric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
-	outbuf := make(map[string][]byte)
 
-	for _, metric := range metrics {
-		var key string
-		if q.RoutingTag != "" {
-			if h, ok := metric.Tags()[q.RoutingTag]; ok {
-				key = h
+	if q.BatchFormat == serializers.BatchFormatMetric {
+		for _, metric := range metrics {
+			buf, err := q.serializer.Serialize(metric)
+			if err != nil {
+				return err
+			}
+			if err := q.publish(q.routingKey(metric), buf); err != nil {
+				return err
 			}
 		}
+		return nil
+	}
+
+	batches := make(map[string][]telegraf.Metric)
+	for _, metric := range metrics {
+		key := q.routingKey(metric)
+		batches[key] = append(batches[key], metric)
+	}
 
-		buf, err := q.serializer.Serialize(metric)
+	for key, batch := range batches {
+		buf, err := q.serializeBatch(batch)
 		if err != nil {
 			return err
 		}
+		if err := q.publish(key, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		outbuf[key] = append(outbuf[key], buf...)
+// serializeBatch serializes the metrics of a message. By default, the metrics
+// serialized one at a time are concatenated, as they always were, while the
+// "batch" format uses the batch serialization of the data format, ie a JSON
+// array.
+func (q *AMQP) serializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	if q.BatchFormat == serializers.BatchFormatBatch {
+		return serializers.SerializeBatch(q.serializer, metrics)
 	}
 
-	for key, buf := range outbuf {
-		err := q.channel.Publish(
-			q.Exchange, // exchange
-			key,        // routing key
-			false,      // mandatory
-			false,      // immediate
-			amqp.Publishing{
-				Headers:     q.headers,
-				ContentType: "text/plain",
-				Body:        buf,
-			})
+	var buf []byte
+	for _, metric := range metrics {
+		b, err := q.serializer.Serialize(metric)
 		if err != nil {
-			return fmt.Errorf("FAILED to send amqp message: %s", err)
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+func (q *AMQP) routingKey(metric telegraf.Metric) string {
+	var key string
+	if q.RoutingTag != "" {
+		if h, ok := metric.Tags()[q.RoutingTag]; ok {
+			key = h
 		}
 	}
+	return key
+}
+
+func (q *AMQP) publish(key string, buf []byte) error {
+	err := q.channel.Publish(
+		q.Exchange, // exchange
+		key,        // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			Headers:     q.headers,
+			ContentType: "text/plain",
+			Body:        buf,
+		})
+	if err != nil {
+		return fmt.Errorf("FAILED to send amqp message: %s", err)
+	}
 	return nil
 }
 
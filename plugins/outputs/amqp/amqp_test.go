@@ -1,8 +1,10 @@
 package amqp
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/require"
@@ -29,3 +31,20 @@ func TestConnectAndWrite(t *testing.T) {
 	err = q.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+func TestSerializeBatch(t *testing.T) {
+	s, _ := serializers.NewJsonSerializer()
+	q := &AMQP{serializer: s}
+	metrics := []telegraf.Metric{testutil.TestMetric(1), testutil.TestMetric(2)}
+
+	// the metrics are concatenated by default
+	buf, err := q.serializeBatch(metrics)
+	require.NoError(t, err)
+	require.Equal(t, byte('{'), buf[0])
+	require.Equal(t, 2, strings.Count(string(buf), "\n"))
+
+	q.BatchFormat = serializers.BatchFormatBatch
+	buf, err = q.serializeBatch(metrics)
+	require.NoError(t, err)
+	require.Equal(t, byte('['), buf[0])
+}
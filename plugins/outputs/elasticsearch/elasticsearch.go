@@ -0,0 +1,465 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// Type of the indexed documents.
+const docType = "metrics"
+
+// Keys of the documents that the fields of a metric, stored under the name
+// of the metric, would overwrite.
+var reservedNames = map[string]bool{
+	"@timestamp":       true,
+	"measurement_name": true,
+	"tag":              true,
+}
+
+var sampleConfig = `
+  ## The full HTTP endpoint URL for your Elasticsearch instance
+  ## Multiple urls can be specified as part of the same cluster,
+  ## this means that only ONE of the urls will be written to each interval.
+  urls = [ "http://localhost:9200" ] # required.
+  ## Elasticsearch client timeout, defaults to "5s" if not set.
+  timeout = "5s"
+  # username = "telegraf"
+  # password = "mypassword"
+
+  ## Index Config
+  ## The target index for metrics (Elasticsearch will create if it not exists).
+  ## You can use the date specifiers below to create indexes per time frame.
+  ## The metric timestamp will be used to decide the destination index name
+  # %Y - year (2016)
+  # %y - last two digits of year (00..99)
+  # %m - month (01..12)
+  # %d - day of month (e.g., 01)
+  # %H - hour (00..23)
+  ## Tag values can be used in the index name with {{tag_name}}, metrics
+  ## without the tag use the default_tag_value. Index names are lowercased.
+  index_name = "telegraf-%Y.%m.%d" # required.
+  # default_tag_value = "none"
+
+  ## Number of times the documents that failed to be indexed with a temporary
+  ## error are sent again, along with the next writes. The documents still
+  ## failing after the retries are logged and dropped.
+  # max_retries = 3
+
+  ## Template Config
+  ## Set to true if you want telegraf to manage its index template.
+  ## If enabled it will create a recommended index template for telegraf indexes,
+  ## index_name must then start with a static prefix.
+  manage_template = true
+  ## The template name used for telegraf indexes
+  template_name = "telegraf"
+  ## Set to true if you want telegraf to overwrite an existing template
+  overwrite_template = false
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+`
+
+type Elasticsearch struct {
+	URLs              []string `toml:"urls"`
+	IndexName         string
+	DefaultTagValue   string
+	Username          string
+	Password          string
+	Timeout           internal.Duration
+	MaxRetries        int
+	ManageTemplate    bool
+	TemplateName      string
+	OverwriteTemplate bool
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client *http.Client
+	// the documents that failed to be indexed with a temporary error, sent
+	// again with the next write.
+	pending []document
+}
+
+// document holds the bulk API action and source lines indexing a metric.
+type document struct {
+	body    []byte
+	retries int
+}
+
+// bulkResponse is the part of the response of the bulk API used to find the
+// documents that could not be indexed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (a *Elasticsearch) Connect() error {
+	if len(a.URLs) == 0 || a.IndexName == "" {
+		return fmt.Errorf("Elasticsearch urls or index_name is not defined")
+	}
+
+	tlsCfg, err := internal.GetTLSConfig(
+		a.SSLCert, a.SSLKey, a.SSLCA, a.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	a.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: a.Timeout.Duration,
+	}
+
+	if a.ManageTemplate {
+		if err := a.manageTemplate(); err != nil {
+			return err
+		}
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	return nil
+}
+
+// Write indexes the metrics, along with the documents of the previous writes
+// that failed to be indexed with a temporary error. The documents failing
+// with a temporary error are kept to be sent with the next write, so that the
+// documents already indexed are not sent again.
+func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
+	docs := make([]document, 0, len(a.pending)+len(metrics))
+	docs = append(docs, a.pending...)
+	for _, metric := range metrics {
+		body, err := a.document(metric)
+		if err != nil {
+			log.Printf("E! Elasticsearch failed to encode metric %s: %s\n",
+				metric.Name(), err)
+			continue
+		}
+		docs = append(docs, document{body: body})
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	url := a.URLs[rand.Intn(len(a.URLs))]
+	failed, err := a.bulk(url, docs)
+	if err != nil {
+		// nothing was indexed, the metrics are kept in the buffer and the
+		// pending documents are sent again with the next write.
+		return err
+	}
+
+	a.pending = a.pending[:0]
+	dropped := 0
+	for _, doc := range failed {
+		if doc.retries >= a.MaxRetries {
+			dropped++
+			continue
+		}
+		doc.retries++
+		a.pending = append(a.pending, doc)
+	}
+	if dropped > 0 {
+		log.Printf("E! Elasticsearch failed to index %d documents after "+
+			"%d retries, dropping them\n", dropped, a.MaxRetries)
+	}
+	return nil
+}
+
+// document returns the bulk API action and source lines indexing the metric.
+func (a *Elasticsearch) document(metric telegraf.Metric) ([]byte, error) {
+	name := metric.Name()
+	if reservedNames[name] {
+		return nil, fmt.Errorf("the measurement name would overwrite the %q key "+
+			"of the document", name)
+	}
+
+	m := make(map[string]interface{})
+	m["@timestamp"] = metric.Time()
+	m["measurement_name"] = name
+	m["tag"] = metric.Tags()
+	m[name] = metric.Fields()
+
+	action := map[string]interface{}{
+		"index": map[string]string{
+			"_index": a.GetIndexName(a.IndexName, metric.Time(), metric.Tags()),
+			"_type":  docType,
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(action); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bulk sends the documents to the bulk API and returns the documents that
+// failed to be indexed with a temporary error. Documents failing with a
+// permanent error, such as a mapping conflict, are logged and dropped. An
+// error is returned if the whole request failed.
+func (a *Elasticsearch) bulk(url string, docs []document) ([]document, error) {
+	var body []byte
+	for _, doc := range docs {
+		body = append(body, doc.body...)
+	}
+	resp, err := a.request("POST", strings.TrimRight(url, "/")+"/_bulk", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Elasticsearch bulk request to %s failed with "+
+			"status code %d: %s", url, resp.StatusCode, msg)
+	}
+
+	var br bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		return nil, fmt.Errorf("Elasticsearch bulk response could not be "+
+			"decoded: %s", err)
+	}
+	if !br.Errors {
+		return nil, nil
+	}
+
+	var failed []document
+	for i, item := range br.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, result := range item {
+			switch {
+			case result.Status < 300:
+			case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+				failed = append(failed, docs[i])
+			default:
+				log.Printf("E! Elasticsearch failed to index document, "+
+					"dropping it: %s\n", result.Error)
+			}
+		}
+	}
+	return failed, nil
+}
+
+func (a *Elasticsearch) request(method, url string, body []byte) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if a.Username != "" || a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	return a.client.Do(req)
+}
+
+func (a *Elasticsearch) manageTemplate() error {
+	if a.TemplateName == "" {
+		return fmt.Errorf("Elasticsearch template_name configuration not defined")
+	}
+	pattern, err := a.templatePattern()
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimRight(a.URLs[0], "/") + "/_template/" + a.TemplateName
+	resp, err := a.request("HEAD", url, nil)
+	if err != nil {
+		return fmt.Errorf("Elasticsearch template check failed, template name: %s, "+
+			"error: %s", a.TemplateName, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && !a.OverwriteTemplate {
+		log.Printf("D! Elasticsearch found existing template %s\n", a.TemplateName)
+		return nil
+	}
+
+	tmpl, err := json.Marshal(a.template(pattern))
+	if err != nil {
+		return err
+	}
+	resp, err = a.request("PUT", url, tmpl)
+	if err != nil {
+		return fmt.Errorf("Elasticsearch failed to create index template %s: %s",
+			a.TemplateName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Elasticsearch failed to create index template %s, "+
+			"status code %d: %s", a.TemplateName, resp.StatusCode, msg)
+	}
+
+	log.Printf("D! Elasticsearch template %s created or updated\n", a.TemplateName)
+	return nil
+}
+
+// templatePattern returns the pattern of the index template, matching all the
+// indexes of index_name. An index_name without a static prefix is rejected,
+// as the template would apply to all the indexes of the cluster.
+func (a *Elasticsearch) templatePattern() (string, error) {
+	prefix := strings.ToLower(a.IndexName)
+	if i := strings.IndexAny(prefix, "%{"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	if prefix == "" {
+		return "", fmt.Errorf("Elasticsearch index_name %q has no static "+
+			"prefix, the template %s would apply to all the indexes",
+			a.IndexName, a.TemplateName)
+	}
+	return prefix + "*", nil
+}
+
+// template returns the index template mapping tags as keywords and fields as
+// non indexed floats, for the indexes matching the pattern.
+func (a *Elasticsearch) template(pattern string) map[string]interface{} {
+	return map[string]interface{}{
+		"template": pattern,
+		"settings": map[string]interface{}{
+			"index": map[string]interface{}{
+				"refresh_interval":           "10s",
+				"mapping.total_fields.limit": 5000,
+			},
+		},
+		"mappings": map[string]interface{}{
+			"_default_": map[string]interface{}{
+				"_all": map[string]interface{}{"enabled": false},
+				"properties": map[string]interface{}{
+					"@timestamp":       map[string]interface{}{"type": "date"},
+					"measurement_name": map[string]interface{}{"type": "keyword"},
+				},
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"tags": map[string]interface{}{
+							"match_mapping_type": "string",
+							"path_match":         "tag.*",
+							"mapping": map[string]interface{}{
+								"ignore_above": 512,
+								"type":         "keyword",
+							},
+						},
+					},
+					map[string]interface{}{
+						"metrics_long": map[string]interface{}{
+							"match_mapping_type": "long",
+							"mapping": map[string]interface{}{
+								"type":  "float",
+								"index": false,
+							},
+						},
+					},
+					map[string]interface{}{
+						"metrics_double": map[string]interface{}{
+							"match_mapping_type": "double",
+							"mapping": map[string]interface{}{
+								"type":  "float",
+								"index": false,
+							},
+						},
+					},
+					map[string]interface{}{
+						"text_fields": map[string]interface{}{
+							"match": "*",
+							"mapping": map[string]interface{}{
+								"norms": false,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var tagPlaceholder = regexp.MustCompile(`{{\s*([^}\s]+)\s*}}`)
+
+// GetIndexName replaces the date specifiers and the tag placeholders of the
+// index name with the values of the metric. The index name is lowercased, as
+// Elasticsearch requires.
+func (a *Elasticsearch) GetIndexName(
+	indexName string,
+	eventTime time.Time,
+	tags map[string]string,
+) string {
+	if strings.Contains(indexName, "%") {
+		var dateReplacer = strings.NewReplacer(
+			"%Y", eventTime.UTC().Format("2006"),
+			"%y", eventTime.UTC().Format("06"),
+			"%m", eventTime.UTC().Format("01"),
+			"%d", eventTime.UTC().Format("02"),
+			"%H", eventTime.UTC().Format("15"),
+		)
+		indexName = dateReplacer.Replace(indexName)
+	}
+
+	indexName = tagPlaceholder.ReplaceAllStringFunc(indexName, func(s string) string {
+		key := tagPlaceholder.FindStringSubmatch(s)[1]
+		if v, ok := tags[key]; ok {
+			return v
+		}
+		return a.DefaultTagValue
+	})
+	return strings.ToLower(indexName)
+}
+
+func (a *Elasticsearch) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Elasticsearch) Description() string {
+	return "Configuration for Elasticsearch to send metrics to."
+}
+
+func (a *Elasticsearch) Close() error {
+	return nil
+}
+
+func init() {
+	outputs.Add("elasticsearch", func() telegraf.Output {
+		return &Elasticsearch{
+			Timeout:         internal.Duration{Duration: time.Second * 5},
+			DefaultTagValue: "none",
+			MaxRetries:      3,
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubServer is a minimal Elasticsearch answering the bulk and template
+// APIs. The status of the bulk items is returned by the status function, for
+// each attempt of each document.
+type stubServer struct {
+	sync.Mutex
+	*httptest.Server
+
+	status   func(doc map[string]interface{}, attempt int) int
+	attempts map[string]int
+	indexed  []map[string]interface{}
+	actions  []map[string]map[string]string
+	template []byte
+	requests []string
+	// number of bulk requests to reject with a 429 status
+	rejected int
+}
+
+func newStubServer() *stubServer {
+	s := &stubServer{
+		status:   func(map[string]interface{}, int) int { return 201 },
+		attempts: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/_template/"):
+		if r.Method == "HEAD" {
+			if s.template == nil {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		s.template, _ = ioutil.ReadAll(r.Body)
+	case r.URL.Path == "/_bulk" && s.rejected > 0:
+		s.rejected--
+		w.WriteHeader(http.StatusTooManyRequests)
+	case r.URL.Path == "/_bulk":
+		var items []string
+		errors := false
+		scnr := bufio.NewScanner(r.Body)
+		for scnr.Scan() {
+			var action map[string]map[string]string
+			json.Unmarshal(scnr.Bytes(), &action)
+			scnr.Scan()
+			var doc map[string]interface{}
+			json.Unmarshal(scnr.Bytes(), &doc)
+
+			key := scnr.Text()
+			status := s.status(doc, s.attempts[key])
+			s.attempts[key]++
+			if status < 300 {
+				s.indexed = append(s.indexed, doc)
+				s.actions = append(s.actions, action)
+			} else {
+				errors = true
+			}
+			items = append(items,
+				fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"err"}}}`, status))
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":%v,"items":[%s]}`,
+			errors, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newElasticsearch(url string) *Elasticsearch {
+	return &Elasticsearch{
+		URLs:            []string{url},
+		IndexName:       "test-%Y.%m.%d",
+		DefaultTagValue: "none",
+		Timeout:         internal.Duration{Duration: time.Second},
+		MaxRetries:      3,
+		TemplateName:    "telegraf",
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	var metrics []telegraf.Metric
+	for i := 0; i < 3; i++ {
+		m, _ := metric.New(
+			"cpu",
+			map[string]string{"host": fmt.Sprintf("Host%d", i)},
+			map[string]interface{}{"value": float64(i)},
+			time.Date(2017, 2, 14, 10, 21, 40, 0, time.UTC),
+		)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestWrite(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	e.IndexName = "test-{{host}}-%Y.%m.%d"
+	require.NoError(t, e.Connect())
+	require.NoError(t, e.Write(testMetrics()))
+
+	require.Len(t, s.indexed, 3)
+	doc := s.indexed[0]
+	assert.Equal(t, "2017-02-14T10:21:40Z", doc["@timestamp"])
+	assert.Equal(t, "cpu", doc["measurement_name"])
+	assert.Equal(t, map[string]interface{}{"host": "Host0"}, doc["tag"])
+	assert.Equal(t, map[string]interface{}{"value": float64(0)}, doc["cpu"])
+
+	assert.Equal(t, "test-host0-2017.02.14", s.actions[0]["index"]["_index"])
+	assert.Equal(t, "metrics", s.actions[0]["index"]["_type"])
+	assert.Equal(t, []string{"POST /_bulk"}, s.requests)
+}
+
+func TestWriteRetriesFailedDocuments(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+	s.status = func(doc map[string]interface{}, attempt int) int {
+		switch doc["tag"].(map[string]interface{})["host"] {
+		case "Host1":
+			// rejected by a full queue once
+			if attempt == 0 {
+				return 429
+			}
+		case "Host2":
+			// mapping error, retrying is pointless
+			return 400
+		}
+		return 201
+	}
+
+	e := newElasticsearch(s.URL)
+	require.NoError(t, e.Connect())
+	require.NoError(t, e.Write(testMetrics()))
+	require.Len(t, s.requests, 1)
+	require.Len(t, s.indexed, 1)
+	require.Len(t, e.pending, 1)
+
+	// only Host1 is sent again, with the next write
+	require.NoError(t, e.Write(nil))
+	require.Len(t, s.requests, 2)
+	require.Len(t, s.indexed, 2)
+	assert.Equal(t, "Host0", s.indexed[0]["tag"].(map[string]interface{})["host"])
+	assert.Equal(t, "Host1", s.indexed[1]["tag"].(map[string]interface{})["host"])
+	assert.Empty(t, e.pending)
+}
+
+func TestWriteRetriesExhausted(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+	s.status = func(doc map[string]interface{}, attempt int) int {
+		return 503
+	}
+
+	e := newElasticsearch(s.URL)
+	e.MaxRetries = 2
+	require.NoError(t, e.Connect())
+	// the batch is not kept in the buffer, the documents are retried with the
+	// next writes and then dropped.
+	assert.NoError(t, e.Write(testMetrics()))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, e.Write(nil))
+	}
+	assert.Len(t, s.requests, 3)
+	assert.Len(t, s.indexed, 0)
+	assert.Empty(t, e.pending)
+}
+
+func TestWriteRetriesRejectedRequest(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+	s.rejected = 1
+
+	e := newElasticsearch(s.URL)
+	require.NoError(t, e.Connect())
+	// the whole request is rejected, the metrics are kept in the buffer
+	assert.Error(t, e.Write(testMetrics()))
+	assert.Empty(t, e.pending)
+	require.NoError(t, e.Write(testMetrics()))
+	assert.Len(t, s.requests, 2)
+	assert.Len(t, s.indexed, 3)
+}
+
+func TestWritePendingAfterBulkError(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+	s.status = func(doc map[string]interface{}, attempt int) int {
+		if doc["tag"].(map[string]interface{})["host"] == "Host1" && attempt == 0 {
+			return 503
+		}
+		return 201
+	}
+
+	e := newElasticsearch(s.URL)
+	require.NoError(t, e.Connect())
+	require.NoError(t, e.Write(testMetrics()))
+	require.Len(t, e.pending, 1)
+
+	// the pending document is kept when the next request fails
+	s.rejected = 1
+	assert.Error(t, e.Write(nil))
+	require.Len(t, e.pending, 1)
+	require.NoError(t, e.Write(nil))
+	assert.Len(t, s.indexed, 3)
+	assert.Empty(t, e.pending)
+}
+
+func TestWriteReservedName(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	require.NoError(t, e.Connect())
+
+	metrics := testMetrics()
+	m, _ := metric.New(
+		"tag",
+		map[string]string{"host": "Host3"},
+		map[string]interface{}{"value": float64(3)},
+		time.Date(2017, 2, 14, 10, 21, 40, 0, time.UTC),
+	)
+	require.NoError(t, e.Write(append(metrics, m)))
+	assert.Len(t, s.indexed, 3)
+}
+
+func TestWriteBulkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	e := newElasticsearch(ts.URL)
+	require.NoError(t, e.Connect())
+	assert.Error(t, e.Write(testMetrics()))
+}
+
+func TestManageTemplate(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	e.IndexName = "telegraf-{{host}}-%Y.%m.%d"
+	e.ManageTemplate = true
+	require.NoError(t, e.Connect())
+
+	assert.Equal(t,
+		[]string{"HEAD /_template/telegraf", "PUT /_template/telegraf"},
+		s.requests)
+	var tmpl map[string]interface{}
+	require.NoError(t, json.Unmarshal(s.template, &tmpl))
+	assert.Equal(t, "telegraf-*", tmpl["template"])
+
+	// an existing template is kept
+	s.requests = nil
+	require.NoError(t, e.Connect())
+	assert.Equal(t, []string{"HEAD /_template/telegraf"}, s.requests)
+
+	// unless it should be overwritten
+	s.requests = nil
+	e.OverwriteTemplate = true
+	require.NoError(t, e.Connect())
+	assert.Equal(t,
+		[]string{"HEAD /_template/telegraf", "PUT /_template/telegraf"},
+		s.requests)
+}
+
+func TestManageTemplateWithoutPrefix(t *testing.T) {
+	s := newStubServer()
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	e.IndexName = "{{host}}-%Y.%m.%d"
+	e.ManageTemplate = true
+	assert.Error(t, e.Connect())
+	assert.Empty(t, s.requests)
+
+	e.ManageTemplate = false
+	assert.NoError(t, e.Connect())
+}
+
+func TestGetIndexName(t *testing.T) {
+	e := &Elasticsearch{DefaultTagValue: "none"}
+	upper := &Elasticsearch{DefaultTagValue: "NONE"}
+	now := time.Date(2014, 12, 01, 23, 30, 00, 00, time.UTC)
+	tags := map[string]string{"tag1": "value1", "tag2": "VALUE2"}
+
+	var tests = []struct {
+		IndexName string
+		Expected  string
+	}{
+		{"indexname", "indexname"},
+		{"indexname-%Y", "indexname-2014"},
+		{"indexname-%Y-%m", "indexname-2014-12"},
+		{"indexname-%Y-%m-%d", "indexname-2014-12-01"},
+		{"indexname-%Y-%m-%d-%H", "indexname-2014-12-01-23"},
+		{"indexname-%y-%m", "indexname-14-12"},
+		{"indexname-{{tag1}}-%Y", "indexname-value1-2014"},
+		{"indexname-{{ tag2 }}-{{tag1}}", "indexname-value2-value1"},
+		{"indexname-{{tag3}}-%Y", "indexname-none-2014"},
+		{"IndexName-{{tag2}}-%Y", "indexname-value2-2014"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, e.GetIndexName(test.IndexName, now, tags))
+	}
+	assert.Equal(t, "indexname-none", upper.GetIndexName("indexname-{{tag3}}", now, tags))
+}
+
+func TestConnectMissingConfig(t *testing.T) {
+	e := &Elasticsearch{IndexName: "telegraf"}
+	assert.Error(t, e.Connect())
+
+	e = &Elasticsearch{URLs: []string{"http://localhost:9200"}}
+	assert.Error(t, e.Connect())
+}
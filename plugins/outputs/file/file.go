@@ -3,31 +3,66 @@ package file
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/rotate"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 )
 
 type File struct {
-	Files []string
+	Files       []string
+	BatchFormat string `toml:"batch_format"`
+
+	RotationInterval    internal.Duration `toml:"rotation_interval"`
+	RotationMaxSize     internal.Size     `toml:"rotation_max_size"`
+	RotationMaxArchives int               `toml:"rotation_max_archives"`
+	RotationCompress    bool              `toml:"rotation_compress"`
 
 	writer  io.Writer
 	closers []io.Closer
+	// metrics left to write after a partly written batch
+	pending []telegraf.Metric
 
 	serializer serializers.Serializer
 }
 
+// headerSerializer is a serializer starting its output with a header, such as
+// csv, which is written again at the beginning of the rotated files.
+type headerSerializer interface {
+	HeaderRow() []byte
+}
+
 var sampleConfig = `
   ## Files to write to, "stdout" is a specially handled file.
   files = ["stdout", "/tmp/metrics.out"]
 
+  ## The file is rotated once it is older than the interval, or once it would
+  ## grow over the maximum size, ie: "10MB". Rotated files are renamed after
+  ## the time of the rotation, ie: "/tmp/metrics.2017-03-01T12-00-00.000000000.out".
+  ## Setting the interval or the maximum size to zero disables this criteria.
+  # rotation_interval = "0h"
+  # rotation_max_size = "0MB"
+
+  ## Maximum number of rotated files to keep, older ones are removed.
+  ## If set to -1, no rotated files are removed.
+  # rotation_max_archives = 5
+
+  ## Compress the rotated files with gzip.
+  # rotation_compress = false
+
   ## Data format to output.
   ## Each data format has it's own unique set of configuration options, read
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
   data_format = "influx"
+
+  ## Write the metrics serialized one at a time ("metric"), or serialize each
+  ## batch of metrics at once ("batch"), ie: as a JSON array.
+  # batch_format = "metric"
 `
 
 func (f *File) SetSerializer(serializer serializers.Serializer) {
@@ -35,6 +70,10 @@ func (f *File) SetSerializer(serializer serializers.Serializer) {
 }
 
 func (f *File) Connect() error {
+	if err := serializers.CheckBatchFormat(f.BatchFormat); err != nil {
+		return err
+	}
+
 	writers := []io.Writer{}
 
 	if len(f.Files) == 0 {
@@ -46,17 +85,19 @@ func (f *File) Connect() error {
 			writers = append(writers, os.Stdout)
 			f.closers = append(f.closers, os.Stdout)
 		} else {
-			var of *os.File
-			var err error
-			if _, err := os.Stat(file); os.IsNotExist(err) {
-				of, err = os.Create(file)
-			} else {
-				of, err = os.OpenFile(file, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-			}
-
+			of, err := rotate.NewFileWriter(
+				file,
+				f.RotationInterval.Duration,
+				f.RotationMaxSize.Size,
+				f.RotationMaxArchives,
+				f.RotationCompress,
+			)
 			if err != nil {
 				return err
 			}
+			if hs, ok := f.serializer.(headerSerializer); ok {
+				of.SetHeader(hs.HeaderRow)
+			}
 			writers = append(writers, of)
 			f.closers = append(f.closers, of)
 		}
@@ -66,6 +107,10 @@ func (f *File) Connect() error {
 }
 
 func (f *File) Close() error {
+	if len(f.pending) > 0 {
+		log.Printf("E! File output dropped %d unwritten metrics\n", len(f.pending))
+		f.pending = nil
+	}
 	var errS string
 	for _, c := range f.closers {
 		if err := c.Close(); err != nil {
@@ -91,21 +136,50 @@ func (f *File) Write(metrics []telegraf.Metric) error {
 		return nil
 	}
 
-	for _, metric := range metrics {
+	if f.BatchFormat == serializers.BatchFormatBatch {
+		b, err := serializers.SerializeBatch(f.serializer, metrics)
+		if err != nil {
+			return fmt.Errorf("failed to serialize batch: %s", err)
+		}
+		if _, err = f.writer.Write(b); err != nil {
+			return fmt.Errorf("failed to write batch: %s", err)
+		}
+		return nil
+	}
+
+	// the metrics left from the previous batch are written first, once rows
+	// were written the batch is not failed as it would write them again.
+	pending := f.pending
+	metrics = append(pending, metrics...)
+	f.pending = nil
+	written := 0
+	for i, metric := range metrics {
 		b, err := f.serializer.Serialize(metric)
 		if err != nil {
-			return fmt.Errorf("failed to serialize message: %s", err)
+			log.Printf("E! Error serializing metric %s: %s\n", metric.Name(), err)
+			continue
 		}
 		_, err = f.writer.Write(b)
 		if err != nil {
-			return fmt.Errorf("failed to write message: %s, %s", metric.Serialize(), err)
+			if written == 0 {
+				f.pending = pending
+				return fmt.Errorf("failed to write message: %s, %s", metric.Serialize(), err)
+			}
+			log.Printf("W! Failed to write message: %s, writing the %d remaining "+
+				"metrics with the next batch\n", err, len(metrics)-i)
+			f.pending = metrics[i:]
+			return nil
 		}
+		written++
 	}
 	return nil
 }
 
 func init() {
 	outputs.Add("file", func() telegraf.Output {
-		return &File{}
+		return &File{
+			BatchFormat:         serializers.BatchFormatMetric,
+			RotationMaxArchives: 5,
+		}
 	})
 }
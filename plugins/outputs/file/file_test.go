@@ -5,10 +5,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
@@ -194,3 +196,139 @@ func validateFile(fname, expS string, t *testing.T) {
 	}
 	assert.Equal(t, expS, string(buf))
 }
+
+func TestFileBatchFormat(t *testing.T) {
+	s, _ := serializers.NewJsonSerializer()
+	fh := tmpFile()
+	f := File{
+		Files:       []string{fh},
+		BatchFormat: serializers.BatchFormatBatch,
+		serializer:  s,
+	}
+
+	err := f.Connect()
+	assert.NoError(t, err)
+
+	err = f.Write(testutil.MockMetrics())
+	assert.NoError(t, err)
+
+	validateFile(fh, `[{"fields":{"value":1},"name":"test1","tags":{"tag1":"value1"},"timestamp":1257894000}]`+"\n", t)
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	f.BatchFormat = "message"
+	assert.Error(t, f.Connect())
+}
+
+func TestFileRotationMaxSize(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fh := filepath.Join(dir, "metrics.out")
+	f := File{
+		Files:               []string{fh},
+		RotationMaxSize:     internal.Size{Size: int64(len(expNewFile))},
+		RotationMaxArchives: -1,
+		serializer:          s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = f.Write(testutil.MockMetrics())
+		assert.NoError(t, err)
+	}
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	validateFile(fh, expNewFile, t)
+	archives, err := filepath.Glob(filepath.Join(dir, "metrics.*.out"))
+	assert.NoError(t, err)
+	assert.Len(t, archives, 2)
+}
+
+func TestFileRotationCSVHeader(t *testing.T) {
+	s, _ := serializers.NewCSVSerializer(&serializers.Config{CSVHeader: true})
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fh := filepath.Join(dir, "metrics.out")
+	f := File{
+		Files:               []string{fh},
+		RotationMaxSize:     internal.Size{Size: 60},
+		RotationMaxArchives: -1,
+		serializer:          s,
+	}
+
+	err = f.Connect()
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		err = f.Write(testutil.MockMetrics())
+		assert.NoError(t, err)
+	}
+
+	err = f.Close()
+	assert.NoError(t, err)
+
+	// each file starts with the header
+	exp := "timestamp,measurement,tag1,value\n1257894000,test1,value1,1\n"
+	validateFile(fh, exp, t)
+	archives, err := filepath.Glob(filepath.Join(dir, "metrics.*.out"))
+	assert.NoError(t, err)
+	assert.Len(t, archives, 2)
+	for _, archive := range archives {
+		validateFile(archive, exp, t)
+	}
+}
+
+// failWriter fails the writes once it wrote n times.
+type failWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, io.ErrShortWrite
+	}
+	w.n--
+	return w.Buffer.Write(p)
+}
+
+func TestFilePartialWrite(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	w := &failWriter{n: 1}
+	f := File{
+		writer:     w,
+		serializer: s,
+	}
+
+	metrics := []telegraf.Metric{
+		testutil.TestMetric(1),
+		testutil.TestMetric(2),
+		testutil.TestMetric(3),
+	}
+
+	// nothing written fails the batch
+	w.n = 0
+	assert.Error(t, f.Write(metrics[:1]))
+	assert.Len(t, f.pending, 0)
+
+	// the metrics left after the first row are written with the next batch
+	w.n = 1
+	assert.NoError(t, f.Write(metrics[:2]))
+	assert.Len(t, f.pending, 1)
+	assert.Equal(t, "test1,tag1=value1 value=1i 1257894000000000000\n", w.String())
+
+	w.n = 10
+	assert.NoError(t, f.Write(metrics[2:]))
+	assert.Len(t, f.pending, 0)
+	assert.Equal(t, "test1,tag1=value1 value=1i 1257894000000000000\n"+
+		"test1,tag1=value1 value=2i 1257894000000000000\n"+
+		"test1,tag1=value1 value=3i 1257894000000000000\n", w.String())
+}
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+const (
+	defaultMethod = "POST"
+)
+
+var sampleConfig = `
+  ## URL is the address to send metrics to
+  url = "http://127.0.0.1:8080/metric"
+  ## HTTP method, one of: "POST" or "PUT"
+  # method = "POST"
+  ## Timeout for HTTP message
+  # timeout = "5s"
+
+  ## HTTP Basic Auth credentials
+  # username = "username"
+  # password = "pa$$word"
+  ## Bearer token sent in the Authorization header
+  # bearer_token = ""
+
+  ## Compress the body of the request, can be "identity" or "gzip"
+  # content_encoding = "identity"
+
+  ## Responses with these status codes are retried on the next flush, the
+  ## metrics are dropped on any other non 2xx status code.
+  # retryable_status_codes = [408, 429, 500, 502, 503, 504]
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format to output.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  data_format = "influx"
+
+  ## Additional HTTP headers
+  ## The Content-Type header is set to match the data_format, ie
+  ## "application/json" for json, and can be overridden here.
+  # [outputs.http.headers]
+  #   Content-Type = "text/plain; charset=utf-8"
+`
+
+type HTTP struct {
+	URL                  string `toml:"url"`
+	Method               string
+	Timeout              internal.Duration
+	Username             string
+	Password             string
+	BearerToken          string `toml:"bearer_token"`
+	ContentEncoding      string `toml:"content_encoding"`
+	RetryableStatusCodes []int  `toml:"retryable_status_codes"`
+	Headers              map[string]string
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client     *http.Client
+	serializer serializers.Serializer
+}
+
+func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
+	h.serializer = serializer
+}
+
+func (h *HTTP) Connect() error {
+	if h.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	if h.Method == "" {
+		h.Method = defaultMethod
+	}
+	h.Method = strings.ToUpper(h.Method)
+	if h.Method != "POST" && h.Method != "PUT" {
+		return fmt.Errorf("invalid method [%s], must be POST or PUT", h.Method)
+	}
+
+	switch h.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("invalid content_encoding [%s], must be identity or gzip",
+			h.ContentEncoding)
+	}
+
+	tlsCfg, err := internal.GetTLSConfig(
+		h.SSLCert, h.SSLKey, h.SSLCA, h.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	h.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: h.Timeout.Duration,
+	}
+	return nil
+}
+
+func (h *HTTP) Close() error {
+	return nil
+}
+
+func (h *HTTP) Description() string {
+	return "A plugin that can transmit metrics over HTTP"
+}
+
+func (h *HTTP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (h *HTTP) Write(metrics []telegraf.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	buf, err := serializers.SerializeBatch(h.serializer, metrics)
+	if err != nil {
+		return fmt.Errorf("failed to serialize batch: %s", err)
+	}
+
+	return h.write(buf)
+}
+
+func (h *HTTP) write(body []byte) error {
+	var reqBody io.Reader = bytes.NewReader(body)
+	if h.ContentEncoding == "gzip" {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		reqBody = &zbuf
+	}
+
+	req, err := http.NewRequest(h.Method, h.URL, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", serializers.ContentType(h.serializer))
+	if h.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	if h.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.BearerToken)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending metrics to %s: %s", h.URL, err)
+	}
+	defer resp.Body.Close()
+	// read the body so that the connection can be reused.
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	if h.isRetryable(resp.StatusCode) {
+		return fmt.Errorf("when writing to [%s] received status code: %d, %s",
+			h.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	// The request can not succeed later on, so drop the metrics instead of
+	// retrying them forever.
+	log.Printf("E! http: When writing to [%s] received status code: %d, "+
+		"dropping metrics: %s\n", h.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	return nil
+}
+
+func (h *HTTP) isRetryable(code int) bool {
+	for _, c := range h.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	outputs.Add("http", func() telegraf.Output {
+		return &HTTP{
+			Method:               defaultMethod,
+			Timeout:              internal.Duration{Duration: 5 * time.Second},
+			RetryableStatusCodes: []int{408, 429, 500, 502, 503, 504},
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/plugins/serializers/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getMetric() telegraf.Metric {
+	m, _ := metric.New(
+		"cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0),
+	)
+	return m
+}
+
+func newHTTP(url string) *HTTP {
+	return &HTTP{
+		URL:                  url,
+		RetryableStatusCodes: []int{503},
+		serializer:           &influx.InfluxSerializer{},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	h := newHTTP(ts.URL)
+	h.Method = "put"
+	h.Username = "telegraf"
+	h.Password = "secret"
+	h.Headers = map[string]string{"X-Custom": "value"}
+	require.NoError(t, h.Connect())
+	require.NoError(t, h.Write([]telegraf.Metric{getMetric(), getMetric()}))
+
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "value", req.Header.Get("X-Custom"))
+	assert.Equal(t, "text/plain; charset=utf-8", req.Header.Get("Content-Type"))
+	user, pass, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "telegraf", user)
+	assert.Equal(t, "secret", pass)
+	assert.Equal(t,
+		"cpu,host=localhost value=42 0\ncpu,host=localhost value=42 0\n",
+		string(body))
+}
+
+func TestWriteGzipBearer(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, _ = ioutil.ReadAll(zr)
+	}))
+	defer ts.Close()
+
+	h := newHTTP(ts.URL)
+	h.ContentEncoding = "gzip"
+	h.BearerToken = "token"
+	require.NoError(t, h.Connect())
+	require.NoError(t, h.Write([]telegraf.Metric{getMetric()}))
+
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	assert.Equal(t, "cpu,host=localhost value=42 0\n", string(body))
+}
+
+func TestWriteContentType(t *testing.T) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	}))
+	defer ts.Close()
+
+	h := newHTTP(ts.URL)
+	h.serializer = &json.JsonSerializer{}
+	require.NoError(t, h.Connect())
+	require.NoError(t, h.Write([]telegraf.Metric{getMetric()}))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+	// the header can be overridden
+	h.Headers = map[string]string{"Content-Type": "application/vnd.custom+json"}
+	require.NoError(t, h.Write([]telegraf.Metric{getMetric()}))
+	assert.Equal(t, "application/vnd.custom+json", req.Header.Get("Content-Type"))
+}
+
+func TestWriteStatusCodes(t *testing.T) {
+	var status int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	h := newHTTP(ts.URL)
+	require.NoError(t, h.Connect())
+
+	// retryable, the metrics are kept in the buffer
+	status = http.StatusServiceUnavailable
+	assert.Error(t, h.Write([]telegraf.Metric{getMetric()}))
+
+	// permanent, the metrics are dropped
+	status = http.StatusBadRequest
+	assert.NoError(t, h.Write([]telegraf.Metric{getMetric()}))
+}
+
+func TestWriteConnectionError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+
+	h := newHTTP(url)
+	require.NoError(t, h.Connect())
+	assert.Error(t, h.Write([]telegraf.Metric{getMetric()}))
+}
+
+func TestConnectInvalid(t *testing.T) {
+	h := newHTTP("")
+	assert.Error(t, h.Connect())
+
+	h = newHTTP("http://localhost")
+	h.Method = "GET"
+	assert.Error(t, h.Connect())
+
+	h = newHTTP("http://localhost")
+	h.ContentEncoding = "deflate"
+	assert.Error(t, h.Connect())
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -77,9 +78,27 @@ type HTTPConfig struct {
 	// TLSConfig is the tls auth settings to use for each request.
 	TLSConfig *tls.Config
 
-	// Gzip, if true, compresses each payload using gzip.
-	// TODO
-	// Gzip bool
+	// ContentEncoding of the write requests, "gzip" compresses each payload
+	// and anything else sends it as is.
+	ContentEncoding string
+}
+
+// APIError is returned when the server does not respond with the expected
+// status code, or responds with an error.
+type APIError struct {
+	StatusCode int
+	Expected   int
+	// Message is the error returned by the server, if any.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	var msg interface{}
+	if e.Message != "" {
+		msg = e.Message
+	}
+	return fmt.Sprintf("Response Error: Status Code [%d], expected [%d], [%v]",
+		e.StatusCode, e.Expected, msg)
 }
 
 // Response represents a list of statement results.
@@ -116,7 +135,7 @@ func (c *httpClient) Query(command string) error {
 func (c *httpClient) Write(b []byte) (int, error) {
 	req, err := c.makeWriteRequest(bytes.NewReader(b), len(b), c.writeURL)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	err = c.doRequest(req, http.StatusNoContent)
@@ -129,7 +148,7 @@ func (c *httpClient) Write(b []byte) (int, error) {
 func (c *httpClient) WriteWithParams(b []byte, wp WriteParams) (int, error) {
 	req, err := c.makeWriteRequest(bytes.NewReader(b), len(b), writeURL(c.url, wp))
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	err = c.doRequest(req, http.StatusNoContent)
@@ -142,7 +161,7 @@ func (c *httpClient) WriteWithParams(b []byte, wp WriteParams) (int, error) {
 func (c *httpClient) WriteStream(r io.Reader, contentLength int) (int, error) {
 	req, err := c.makeWriteRequest(r, contentLength, c.writeURL)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	err = c.doRequest(req, http.StatusNoContent)
@@ -159,7 +178,7 @@ func (c *httpClient) WriteStreamWithParams(
 ) (int, error) {
 	req, err := c.makeWriteRequest(r, contentLength, writeURL(c.url, wp))
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	err = c.doRequest(req, http.StatusNoContent)
@@ -199,8 +218,11 @@ func (c *httpClient) doRequest(
 	// Unexpected response code OR error in JSON response body overrides
 	// a JSON decode error:
 	if code != expectedCode || response.Error() != nil {
-		err = fmt.Errorf("Response Error: Status Code [%d], expected [%d], [%v]",
-			code, expectedCode, response.Error())
+		err = &APIError{
+			StatusCode: code,
+			Expected:   expectedCode,
+			Message:    response.Err,
+		}
 	}
 
 	return err
@@ -211,15 +233,27 @@ func (c *httpClient) makeWriteRequest(
 	contentLength int,
 	writeURL string,
 ) (*http.Request, error) {
+	if c.config.ContentEncoding == "gzip" {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := io.Copy(zw, body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = &zbuf
+		contentLength = zbuf.Len()
+	}
+
 	req, err := c.makeRequest(writeURL, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Length", fmt.Sprint(contentLength))
-	// TODO
-	// if gzip {
-	// 	req.Header.Set("Content-Encoding", "gzip")
-	// }
+	if c.config.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	return req, nil
 }
 
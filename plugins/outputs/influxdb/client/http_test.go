@@ -2,7 +2,9 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -206,6 +208,80 @@ func TestHTTPClient_Write_Errors(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestHTTPClient_Write_Gzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			if r.Header.Get("Content-Encoding") != "gzip" {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			body, err := ioutil.ReadAll(zr)
+			if err != nil || string(body) != "cpu value=99\n" {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	config := HTTPConfig{
+		URL:             ts.URL,
+		ContentEncoding: "gzip",
+	}
+	defaultWP := WriteParams{
+		Database: "test",
+	}
+	client, err := NewHTTP(config, defaultWP)
+	defer client.Close()
+	assert.NoError(t, err)
+
+	lp := []byte("cpu value=99\n")
+	n, err := client.Write(lp)
+	assert.Equal(t, 13, n)
+	assert.NoError(t, err)
+
+	n, err = client.WriteStreamWithParams(bytes.NewReader(lp), 13, defaultWP)
+	assert.Equal(t, 13, n)
+	assert.NoError(t, err)
+}
+
+func TestHTTPClient_Write_APIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"error":"partial write: unable to parse 'cpu value=': missing field value dropped=1"}`)
+		}
+	}))
+	defer ts.Close()
+
+	config := HTTPConfig{
+		URL: ts.URL,
+	}
+	defaultWP := WriteParams{
+		Database: "test",
+	}
+	client, err := NewHTTP(config, defaultWP)
+	defer client.Close()
+	assert.NoError(t, err)
+
+	_, err = client.Write([]byte("cpu value=\n"))
+	apiErr, ok := err.(*APIError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, http.StatusNoContent, apiErr.Expected)
+	assert.Equal(t, "partial write: unable to parse 'cpu value=': missing field value dropped=1",
+		apiErr.Message)
+}
+
 func TestNewHTTPErrors(t *testing.T) {
 	// No URL:
 	config := HTTPConfig{}
@@ -17,16 +17,19 @@ import (
 
 type InfluxDB struct {
 	// URL is only for backwards compatability
-	URL              string
-	URLs             []string `toml:"urls"`
-	Username         string
-	Password         string
-	Database         string
-	UserAgent        string
-	RetentionPolicy  string
-	WriteConsistency string
-	Timeout          internal.Duration
-	UDPPayload       int `toml:"udp_payload"`
+	URL                string
+	URLs               []string `toml:"urls"`
+	Username           string
+	Password           string
+	Database           string
+	DatabaseTag        string `toml:"database_tag"`
+	UserAgent          string
+	RetentionPolicy    string
+	RetentionPolicyTag string `toml:"retention_policy_tag"`
+	WriteConsistency   string
+	Timeout            internal.Duration
+	UDPPayload         int    `toml:"udp_payload"`
+	ContentEncoding    string `toml:"content_encoding"`
 
 	// Path to CA file
 	SSLCA string `toml:"ssl_ca"`
@@ -51,9 +54,17 @@ var sampleConfig = `
   urls = ["http://localhost:8086"] # required
   ## The target database for metrics (telegraf will create it if not exists).
   database = "telegraf" # required
+  ## Name of a tag whose value selects the database to write the metric to.
+  ## Metrics without this tag are written to the database above. Databases
+  ## that do not exist are created on the first write. Not supported with UDP.
+  # database_tag = ""
 
   ## Retention policy to write to. Empty string writes to the default rp.
   retention_policy = ""
+  ## Name of a tag whose value selects the retention policy to write the
+  ## metric to. Metrics without this tag use the retention policy above.
+  ## Not supported with UDP.
+  # retention_policy_tag = ""
   ## Write consistency (clusters only), can be: "any", "one", "quorum", "all"
   write_consistency = "any"
 
@@ -67,6 +78,9 @@ var sampleConfig = `
   ## Set UDP payload size, defaults to InfluxDB UDP Client default (512 bytes)
   # udp_payload = 512
 
+  ## Compress the body of HTTP write requests, can be "identity" or "gzip"
+  # content_encoding = "identity"
+
   ## Optional SSL Config
   # ssl_ca = "/etc/telegraf/ca.pem"
   # ssl_cert = "/etc/telegraf/cert.pem"
@@ -75,7 +89,30 @@ var sampleConfig = `
   # insecure_skip_verify = false
 `
 
+// errors returned by InfluxDB when some points of a write are invalid. The
+// valid points are still written and the invalid ones would fail again, so
+// the write is not retried.
+var pointErrors = []string{
+	"partial write",
+	"field type conflict",
+	"unable to parse",
+	"points beyond retention policy",
+}
+
+// batchKey is the destination of a batch of metrics.
+type batchKey struct {
+	database        string
+	retentionPolicy string
+}
+
 func (i *InfluxDB) Connect() error {
+	switch i.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("invalid content_encoding [%s], must be identity or gzip",
+			i.ContentEncoding)
+	}
+
 	var urls []string
 	for _, u := range i.URLs {
 		urls = append(urls, u)
@@ -96,6 +133,12 @@ func (i *InfluxDB) Connect() error {
 	for _, u := range urls {
 		switch {
 		case strings.HasPrefix(u, "udp"):
+			// the UDP listener of InfluxDB writes to a single database and
+			// retention policy, set in its own configuration.
+			if i.DatabaseTag != "" || i.RetentionPolicyTag != "" {
+				return fmt.Errorf("database_tag and retention_policy_tag are "+
+					"not supported with UDP [%s]", u)
+			}
 			config := client.UDPConfig{
 				URL:         u,
 				PayloadSize: i.UDPPayload,
@@ -108,10 +151,11 @@ func (i *InfluxDB) Connect() error {
 		default:
 			// If URL doesn't start with "udp", assume HTTP client
 			config := client.HTTPConfig{
-				URL:       u,
-				Timeout:   i.Timeout.Duration,
-				TLSConfig: tlsConfig,
-				UserAgent: i.UserAgent,
+				URL:             u,
+				Timeout:         i.Timeout.Duration,
+				TLSConfig:       tlsConfig,
+				UserAgent:       i.UserAgent,
+				ContentEncoding: i.ContentEncoding,
 			}
 			wp := client.WriteParams{
 				Database:        i.Database,
@@ -124,7 +168,7 @@ func (i *InfluxDB) Connect() error {
 			}
 			i.clients = append(i.clients, c)
 
-			err = c.Query("CREATE DATABASE " + i.Database)
+			err = c.Query(createDatabase(i.Database))
 			if err != nil {
 				log.Println("E! Database creation failed: " + err.Error())
 				continue
@@ -148,46 +192,130 @@ func (i *InfluxDB) Description() string {
 	return "Configuration for influxdb server to send metrics to"
 }
 
-// Choose a random server in the cluster to write to until a successful write
-// occurs, logging each unsuccessful. If all servers fail, return error.
+// Write groups the metrics by database and retention policy, and writes each
+// batch to a random server in the cluster until a successful write occurs,
+// logging each unsuccessful one. If a batch could not be written to any
+// server, return an error so that the metrics are retried.
 func (i *InfluxDB) Write(metrics []telegraf.Metric) error {
-	bufsize := 0
+	var keys []batchKey
+	batches := make(map[batchKey][]telegraf.Metric)
 	for _, m := range metrics {
-		bufsize += m.Len()
+		key := i.batchKey(m)
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], m)
 	}
-	r := metric.NewReader(metrics)
 
-	// This will get set to nil if a successful write occurs
-	err := fmt.Errorf("Could not write to any InfluxDB server in cluster")
+	var failed int
+	for _, key := range keys {
+		if err := i.writeBatch(key, batches[key]); err != nil {
+			log.Printf("E! InfluxDB Output Error: %s", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Could not write %d of %d batches to any InfluxDB server in cluster",
+			failed, len(keys))
+	}
+	return nil
+}
+
+func (i *InfluxDB) batchKey(m telegraf.Metric) batchKey {
+	key := batchKey{
+		database:        i.Database,
+		retentionPolicy: i.RetentionPolicy,
+	}
+	if i.DatabaseTag != "" {
+		if db, ok := m.Tags()[i.DatabaseTag]; ok && db != "" {
+			key.database = db
+		}
+	}
+	if i.RetentionPolicyTag != "" {
+		if rp, ok := m.Tags()[i.RetentionPolicyTag]; ok && rp != "" {
+			key.retentionPolicy = rp
+		}
+	}
+	return key
+}
+
+func (i *InfluxDB) writeBatch(key batchKey, metrics []telegraf.Metric) error {
+	wp := client.WriteParams{
+		Database:        key.database,
+		RetentionPolicy: key.retentionPolicy,
+		Consistency:     i.WriteConsistency,
+	}
 
 	p := rand.Perm(len(i.clients))
 	for _, n := range p {
-		if _, e := i.clients[n].WriteStream(r, bufsize); e != nil {
-			// If the database was not found, try to recreate it:
-			if strings.Contains(e.Error(), "database not found") {
-				if errc := i.clients[n].Query("CREATE DATABASE  " + i.Database); errc != nil {
-					log.Printf("E! Error: Database %s not found and failed to recreate\n",
-						i.Database)
-				}
+		c := i.clients[n]
+		err := writeStream(c, metrics, wp)
+		if isDatabaseNotFound(err) {
+			// If the database was not found, try to recreate it and write again:
+			if errc := c.Query(createDatabase(key.database)); errc != nil {
+				log.Printf("E! Error: Database %s not found and failed to recreate\n",
+					key.database)
+			} else {
+				err = writeStream(c, metrics, wp)
 			}
-			if strings.Contains(e.Error(), "field type conflict") {
-				log.Printf("E! Field type conflict, dropping conflicted points: %s", e)
-				// setting err to nil, otherwise we will keep retrying and points
-				// w/ conflicting types will get stuck in the buffer forever.
-				err = nil
-				break
-			}
-			// Log write failure
-			log.Printf("E! InfluxDB Output Error: %s", e)
-		} else {
-			err = nil
-			break
 		}
+		if err == nil {
+			return nil
+		}
+
+		if isPointError(err) {
+			// the server rejected some points and wrote the others, retrying
+			// would only keep the invalid points stuck in the buffer forever.
+			log.Printf("E! Invalid points in database %s, dropping them: %s",
+				key.database, err)
+			return nil
+		}
+		// Log write failure
+		log.Printf("E! InfluxDB Output Error: %s", err)
 	}
+	return fmt.Errorf("Could not write to database %s on any InfluxDB server in cluster",
+		key.database)
+}
 
+func writeStream(c client.Client, metrics []telegraf.Metric, wp client.WriteParams) error {
+	bufsize := 0
+	for _, m := range metrics {
+		bufsize += m.Len()
+	}
+	r := metric.NewReader(metrics)
+	_, err := c.WriteStreamWithParams(r, bufsize, wp)
 	return err
 }
 
+func isDatabaseNotFound(err error) bool {
+	apiErr, ok := err.(*client.APIError)
+	return ok && strings.Contains(apiErr.Message, "database not found")
+}
+
+// isPointError returns true if the server refused the write because of
+// invalid points.
+func isPointError(err error) bool {
+	apiErr, ok := err.(*client.APIError)
+	if !ok || apiErr.StatusCode/100 != 4 {
+		return false
+	}
+	for _, msg := range pointErrors {
+		if strings.Contains(apiErr.Message, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// createDatabase returns the query creating the database, with its name
+// quoted as an identifier.
+func createDatabase(database string) string {
+	return `CREATE DATABASE "` + identEscaper.Replace(database) + `"`
+}
+
+var identEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 func newInflux() *InfluxDB {
 	return &InfluxDB{
 		Timeout: internal.Duration{Duration: time.Second * 5},
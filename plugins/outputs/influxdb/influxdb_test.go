@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/require"
@@ -75,6 +79,20 @@ func TestUDPConnectError(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestUDPConnectError_DatabaseTag(t *testing.T) {
+	i := InfluxDB{
+		URLs:        []string{"udp://localhost:8089"},
+		DatabaseTag: "database",
+	}
+	require.Error(t, i.Connect())
+
+	i = InfluxDB{
+		URLs:               []string{"udp://localhost:8089"},
+		RetentionPolicyTag: "rp",
+	}
+	require.Error(t, i.Connect())
+}
+
 func TestHTTPConnectError_InvalidURL(t *testing.T) {
 	i := InfluxDB{
 		URLs: []string{"http://foobar:8089"},
@@ -164,3 +182,108 @@ func TestHTTPError_FieldTypeConflict(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, i.Close())
 }
+
+func TestHTTPError_PartialWrite(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"error":"partial write: points beyond retention policy dropped=1"}`)
+		}
+	}))
+	defer ts.Close()
+
+	i := InfluxDB{
+		URLs:     []string{ts.URL},
+		Database: "test",
+	}
+
+	err := i.Connect()
+	require.NoError(t, err)
+	err = i.Write(testutil.MockMetrics())
+	require.NoError(t, err)
+	require.NoError(t, i.Close())
+}
+
+func TestHTTPError_Retry(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"error":"engine is closed"}`)
+		}
+	}))
+	defer ts.Close()
+
+	i := InfluxDB{
+		URLs:     []string{ts.URL},
+		Database: "test",
+	}
+
+	err := i.Connect()
+	require.NoError(t, err)
+	err = i.Write(testutil.MockMetrics())
+	require.Error(t, err)
+	require.NoError(t, i.Close())
+}
+
+func TestHTTPInflux_DatabaseTag(t *testing.T) {
+	var mu sync.Mutex
+	writes := make(map[string]int)
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/write":
+			db := r.FormValue("db")
+			// the database of the tag does not exist before it is created.
+			if db == "other" && len(queries) < 2 {
+				w.WriteHeader(http.StatusNotFound)
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintln(w, `{"error":"database not found: \"other\""}`)
+				return
+			}
+			writes[db+"/"+r.FormValue("rp")]++
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			queries = append(queries, r.FormValue("q"))
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintln(w, `{"results":[{}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	i := newInflux()
+	i.URLs = []string{ts.URL}
+	i.Database = "test"
+	i.DatabaseTag = "db"
+	i.RetentionPolicyTag = "rp"
+
+	now := time.Now()
+	m1, _ := metric.New("cpu", map[string]string{"db": "other"},
+		map[string]interface{}{"value": 1}, now)
+	m2, _ := metric.New("cpu", map[string]string{"db": "other", "rp": "short"},
+		map[string]interface{}{"value": 1}, now)
+	m3, _ := metric.New("cpu", map[string]string{"host": "localhost"},
+		map[string]interface{}{"value": 1}, now)
+
+	err := i.Connect()
+	require.NoError(t, err)
+	err = i.Write([]telegraf.Metric{m1, m2, m3})
+	require.NoError(t, err)
+	require.NoError(t, i.Close())
+
+	require.Equal(t, map[string]int{
+		"other/":      1,
+		"other/short": 1,
+		"test/":       1,
+	}, writes)
+	require.Equal(t, []string{
+		`CREATE DATABASE "test"`,
+		`CREATE DATABASE "other"`,
+	}, queries)
+}
@@ -0,0 +1,141 @@
+package influxdb_v2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// APIError is returned when the server does not accept a write.
+type APIError struct {
+	StatusCode int
+	// Code and Message are read from the error returned by the server, if any.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("received status code %d: %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
+
+type HTTPConfig struct {
+	// URL of the server, ie: "http://localhost:8086"
+	URL             string
+	Token           string
+	Organization    string
+	UserAgent       string
+	ContentEncoding string
+	Headers         map[string]string
+	Timeout         time.Duration
+	TLSConfig       *tls.Config
+}
+
+// httpClient writes to a server with the write API of InfluxDB 2.x.
+type httpClient struct {
+	config HTTPConfig
+	url    *url.URL
+	client *http.Client
+}
+
+func newHTTPClient(config HTTPConfig) (*httpClient, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url [%s]: %s", config.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url [%s] scheme must be http(s), got %s",
+			config.URL, u.Scheme)
+	}
+
+	return &httpClient{
+		config: config,
+		url:    u,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: config.TLSConfig,
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+func (c *httpClient) URL() string {
+	return c.config.URL
+}
+
+// Write sends the line protocol in the body to the bucket.
+func (c *httpClient) Write(bucket string, body io.Reader) error {
+	if c.config.ContentEncoding == "gzip" {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := io.Copy(zw, body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = &zbuf
+	}
+
+	req, err := http.NewRequest("POST", c.writeURL(bucket), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if c.config.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+c.config.Token)
+	}
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// read the body so that the connection can be reused.
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var errBody struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(msg, &errBody) == nil {
+		apiErr.Code = errBody.Code
+		apiErr.Message = errBody.Message
+	}
+	return apiErr
+}
+
+func (c *httpClient) writeURL(bucket string) string {
+	params := url.Values{}
+	params.Set("org", c.config.Organization)
+	params.Set("bucket", bucket)
+
+	u := *c.url
+	u.Path = path.Join(u.Path, "/api/v2/write")
+	u.RawQuery = params.Encode()
+	return u.String()
+}
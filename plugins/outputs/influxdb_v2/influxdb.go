@@ -0,0 +1,226 @@
+package influxdb_v2
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+var sampleConfig = `
+  ## The URLs of the InfluxDB 2.x servers.
+  ## Multiple URLs can be specified for a single cluster, only ONE of the
+  ## urls will be written to each interval.
+  urls = ["http://127.0.0.1:8086"]
+
+  ## Token for authentication.
+  token = ""
+
+  ## Organization is the name of the organization you wish to write to.
+  organization = ""
+
+  ## Destination bucket to write into.
+  bucket = ""
+
+  ## Name of a tag whose value selects the bucket to write the metric to.
+  ## Metrics without this tag are written to the bucket above.
+  # bucket_tag = ""
+
+  ## Timeout for HTTP messages.
+  # timeout = "5s"
+
+  ## HTTP User-Agent
+  # user_agent = "telegraf"
+
+  ## Compress the body of the requests, can be "identity" or "gzip"
+  # content_encoding = "gzip"
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Additional HTTP headers
+  # [outputs.influxdb_v2.http_headers]
+  #   X-Special-Header = "Special-Value"
+`
+
+type InfluxDB struct {
+	URLs            []string `toml:"urls"`
+	Token           string
+	Organization    string
+	Bucket          string
+	BucketTag       string `toml:"bucket_tag"`
+	Timeout         internal.Duration
+	HTTPHeaders     map[string]string `toml:"http_headers"`
+	UserAgent       string            `toml:"user_agent"`
+	ContentEncoding string            `toml:"content_encoding"`
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	clients []*httpClient
+}
+
+func (i *InfluxDB) Connect() error {
+	if len(i.URLs) == 0 {
+		return fmt.Errorf("at least one url is required")
+	}
+	if i.Bucket == "" && i.BucketTag == "" {
+		return fmt.Errorf("bucket or bucket_tag is required")
+	}
+
+	switch i.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("invalid content_encoding [%s], must be identity or gzip",
+			i.ContentEncoding)
+	}
+
+	tlsConfig, err := internal.GetTLSConfig(
+		i.SSLCert, i.SSLKey, i.SSLCA, i.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range i.URLs {
+		c, err := newHTTPClient(HTTPConfig{
+			URL:             u,
+			Token:           i.Token,
+			Organization:    i.Organization,
+			UserAgent:       i.UserAgent,
+			ContentEncoding: i.ContentEncoding,
+			Headers:         i.HTTPHeaders,
+			Timeout:         i.Timeout.Duration,
+			TLSConfig:       tlsConfig,
+		})
+		if err != nil {
+			return fmt.Errorf("Error creating HTTP Client [%s]: %s", u, err)
+		}
+		i.clients = append(i.clients, c)
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	return nil
+}
+
+func (i *InfluxDB) Close() error {
+	return nil
+}
+
+func (i *InfluxDB) SampleConfig() string {
+	return sampleConfig
+}
+
+func (i *InfluxDB) Description() string {
+	return "Configuration for sending metrics to InfluxDB 2.x"
+}
+
+// Write groups the metrics by bucket, and writes each batch to a random
+// server until a successful write occurs, logging each unsuccessful one. If a
+// batch could not be written to any server, return an error so that the
+// metrics are retried.
+func (i *InfluxDB) Write(metrics []telegraf.Metric) error {
+	var buckets []string
+	batches := make(map[string][]telegraf.Metric)
+	for _, m := range metrics {
+		bucket := i.Bucket
+		if i.BucketTag != "" {
+			if b, ok := m.Tags()[i.BucketTag]; ok && b != "" {
+				bucket = b
+			}
+		}
+		if bucket == "" {
+			log.Printf("E! influxdb_v2: Metric %s has no %s tag and no default "+
+				"bucket, dropping it\n", m.Name(), i.BucketTag)
+			continue
+		}
+
+		if _, ok := batches[bucket]; !ok {
+			buckets = append(buckets, bucket)
+		}
+		batches[bucket] = append(batches[bucket], m)
+	}
+
+	var failed int
+	for _, bucket := range buckets {
+		if err := i.writeBatch(bucket, batches[bucket]); err != nil {
+			log.Printf("E! influxdb_v2: %s\n", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("could not write %d of %d batches to any server",
+			failed, len(buckets))
+	}
+	return nil
+}
+
+func (i *InfluxDB) writeBatch(bucket string, metrics []telegraf.Metric) error {
+	p := rand.Perm(len(i.clients))
+	for _, n := range p {
+		c := i.clients[n]
+		err := c.Write(bucket, metric.NewReader(metrics))
+		if err == nil {
+			return nil
+		}
+
+		if apiErr, ok := err.(*APIError); ok {
+			switch apiErr.StatusCode {
+			case http.StatusBadRequest, http.StatusUnprocessableEntity:
+				// the server rejected the points of the batch, retrying would
+				// only keep the invalid points stuck in the buffer forever.
+				log.Printf("E! influxdb_v2: Invalid points in bucket %s, "+
+					"dropping them: %s\n", bucket, err)
+				return nil
+			case http.StatusRequestEntityTooLarge:
+				if len(metrics) > 1 {
+					return i.splitBatch(bucket, metrics)
+				}
+				log.Printf("E! influxdb_v2: Metric too large for bucket %s, "+
+					"dropping it: %s\n", bucket, err)
+				return nil
+			}
+		}
+		// Log write failure
+		log.Printf("E! influxdb_v2: When writing to [%s]: %s\n", c.URL(), err)
+	}
+	return fmt.Errorf("could not write to bucket %s on any server", bucket)
+}
+
+// splitBatch writes a batch too large for the server in two halves.
+func (i *InfluxDB) splitBatch(bucket string, metrics []telegraf.Metric) error {
+	half := len(metrics) / 2
+	err := i.writeBatch(bucket, metrics[:half])
+	if errh := i.writeBatch(bucket, metrics[half:]); errh != nil {
+		err = errh
+	}
+	return err
+}
+
+func newInfluxDB() *InfluxDB {
+	return &InfluxDB{
+		Timeout:         internal.Duration{Duration: 5 * time.Second},
+		UserAgent:       "telegraf",
+		ContentEncoding: "gzip",
+	}
+}
+
+func init() {
+	outputs.Add("influxdb_v2", func() telegraf.Output { return newInfluxDB() })
+}
@@ -0,0 +1,241 @@
+package influxdb_v2
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/write" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.FormValue("org") != "example" || r.FormValue("bucket") != "telegraf" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"code":"not found","message":"bucket not found"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Test") != "test" || r.UserAgent() != "telegraf" {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			body = zr
+		}
+		buf, _ := ioutil.ReadAll(body)
+		if string(buf) != "test1,tag1=value1 value=1 1257894000000000000\n" {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	for _, encoding := range []string{"identity", "gzip"} {
+		i := newInfluxDB()
+		i.URLs = []string{ts.URL}
+		i.Token = "secret"
+		i.Organization = "example"
+		i.Bucket = "telegraf"
+		i.ContentEncoding = encoding
+		i.HTTPHeaders = map[string]string{"X-Test": "test"}
+
+		require.NoError(t, i.Connect())
+		require.NoError(t, i.Write(testutil.MockMetrics()), encoding)
+		require.NoError(t, i.Close())
+	}
+}
+
+func TestWriteBucketTag(t *testing.T) {
+	var mu sync.Mutex
+	writes := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		buf, _ := ioutil.ReadAll(r.Body)
+		writes[r.FormValue("bucket")] += string(buf)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	i := newInfluxDB()
+	i.URLs = []string{ts.URL}
+	i.Bucket = "telegraf"
+	i.BucketTag = "bucket"
+	i.ContentEncoding = "identity"
+
+	now := time.Unix(0, 0)
+	m1, _ := metric.New("cpu", map[string]string{"bucket": "other"},
+		map[string]interface{}{"value": 1}, now)
+	m2, _ := metric.New("mem", map[string]string{},
+		map[string]interface{}{"value": 2}, now)
+	m3, _ := metric.New("disk", map[string]string{"bucket": "other"},
+		map[string]interface{}{"value": 3}, now)
+
+	require.NoError(t, i.Connect())
+	require.NoError(t, i.Write([]telegraf.Metric{m1, m2, m3}))
+
+	require.Equal(t, map[string]string{
+		"other":    "cpu,bucket=other value=1i 0\ndisk,bucket=other value=3i 0\n",
+		"telegraf": "mem value=2i 0\n",
+	}, writes)
+}
+
+func TestWriteErrors(t *testing.T) {
+	var tests = []struct {
+		name   string
+		status int
+		body   string
+		err    bool
+	}{
+		{
+			name:   "invalid points are dropped",
+			status: http.StatusBadRequest,
+			body:   `{"code":"invalid","message":"unable to parse points"}`,
+		},
+		{
+			name:   "unauthorized is retried",
+			status: http.StatusUnauthorized,
+			body:   `{"code":"unauthorized","message":"unauthorized access"}`,
+			err:    true,
+		},
+		{
+			name:   "server error is retried",
+			status: http.StatusServiceUnavailable,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.body)
+			}))
+			defer ts.Close()
+
+			i := newInfluxDB()
+			i.URLs = []string{ts.URL}
+			i.Bucket = "telegraf"
+
+			require.NoError(t, i.Connect())
+			err := i.Write(testutil.MockMetrics())
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	var mu sync.Mutex
+	var written int
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		written++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer up.Close()
+
+	i := newInfluxDB()
+	i.URLs = []string{down.URL, up.URL}
+	i.Bucket = "telegraf"
+
+	require.NoError(t, i.Connect())
+	for n := 0; n < 5; n++ {
+		require.NoError(t, i.Write(testutil.MockMetrics()))
+	}
+	require.Equal(t, 5, written)
+}
+
+func TestWriteSplitTooLarge(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		buf, _ := ioutil.ReadAll(r.Body)
+		batch := strings.Split(strings.TrimSpace(string(buf)), "\n")
+		if len(batch) > 1 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		lines = append(lines, batch...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	i := newInfluxDB()
+	i.URLs = []string{ts.URL}
+	i.Bucket = "telegraf"
+	i.ContentEncoding = "identity"
+
+	var metrics []telegraf.Metric
+	for n := 0; n < 3; n++ {
+		m, _ := metric.New("cpu", map[string]string{},
+			map[string]interface{}{"value": n}, time.Unix(0, 0))
+		metrics = append(metrics, m)
+	}
+
+	require.NoError(t, i.Connect())
+	require.NoError(t, i.Write(metrics))
+	require.Equal(t, []string{
+		"cpu value=0i 0",
+		"cpu value=1i 0",
+		"cpu value=2i 0",
+	}, lines)
+}
+
+func TestConnectErrors(t *testing.T) {
+	i := newInfluxDB()
+	require.Error(t, i.Connect())
+
+	i = newInfluxDB()
+	i.URLs = []string{"http://localhost:8086"}
+	require.Error(t, i.Connect())
+
+	i = newInfluxDB()
+	i.URLs = []string{"udp://localhost:8086"}
+	i.Bucket = "telegraf"
+	require.Error(t, i.Connect())
+
+	i = newInfluxDB()
+	i.URLs = []string{"http://localhost:8086"}
+	i.Bucket = "telegraf"
+	i.ContentEncoding = "deflate"
+	require.Error(t, i.Connect())
+}
@@ -3,28 +3,55 @@ package kafka
 import (
 	"crypto/tls"
 	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/templating"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 
 	"github.com/Shopify/sarama"
 )
 
+const (
+	partitionKey        = "key"
+	partitionSeries     = "series"
+	partitionRoundRobin = "round_robin"
+)
+
 type Kafka struct {
 	// Kafka brokers to send metrics to
 	Brokers []string
-	// Kafka topic
+	// Kafka topic, can be a template
 	Topic string
+	// Tag holding the topic of the metric
+	TopicTag string `toml:"topic_tag"`
 	// Routing Key Tag
 	RoutingTag string `toml:"routing_tag"`
+	// Routing key template, overrides the routing tag
+	RoutingKey string `toml:"routing_key"`
+	// How messages are assigned to partitions
+	PartitionStrategy string `toml:"partition_strategy"`
 	// Compression Codec Tag
 	CompressionCodec int
 	// RequiredAcks Tag
 	RequiredAcks int
 	// MaxRetry Tag
 	MaxRetry int
+	// Send a message per metric or per batch
+	BatchFormat string `toml:"batch_format"`
+	// Kafka version of the brokers
+	Version string `toml:"version"`
+	// Headers added to each message
+	Headers map[string]string `toml:"headers"`
+	// Make sure each message is written exactly once in the partition
+	IdempotentWrites bool `toml:"idempotent_writes"`
+
+	// SASL authentication
+	SASLUsername string `toml:"sasl_username"`
+	SASLPassword string `toml:"sasl_password"`
 
 	// Legacy SSL config options
 	// TLS client certificate
@@ -47,17 +74,38 @@ type Kafka struct {
 	tlsConfig tls.Config
 	producer  sarama.SyncProducer
 
+	topic      *templating.MetricTemplate
+	routingKey *templating.MetricTemplate
+	headerKeys []string
+
 	serializer serializers.Serializer
 }
 
 var sampleConfig = `
   ## URLs of kafka brokers
   brokers = ["localhost:9092"]
-  ## Kafka topic for producer messages
+  ## Kafka topic for producer messages. The topic can be a template of the
+  ## metric, ie: "telegraf_{{ .Name }}" or "{{ .Tag \"site\" }}_metrics".
   topic = "telegraf"
+  ## Telegraf tag to use as the topic
+  ##  ie, if this tag exists, it's value will be used as the topic instead of
+  ##  the topic above
+  # topic_tag = ""
+
   ## Telegraf tag to use as a routing key
   ##  ie, if this tag exists, it's value will be used as the routing key
   routing_tag = "host"
+  ## Template of the routing key, overrides the routing tag,
+  ## ie: "{{ .Name }}_{{ .Tag \"host\" }}"
+  # routing_key = ""
+
+  ## How the messages are assigned to partitions:
+  ##   "key"         - hash of the routing key, messages without a routing
+  ##                   key are sent to a random partition.
+  ##   "series"      - hash of the series, the metrics of a series are sent
+  ##                   to the same partition. The routing key is not used.
+  ##   "round_robin" - each partition in turn.
+  # partition_strategy = "key"
 
   ## CompressionCodec represents the various compression codecs recognized by
   ## Kafka in messages.
@@ -85,6 +133,26 @@ var sampleConfig = `
   ##  The total number of times to retry sending a message
   max_retry = 3
 
+  ## Send a message per metric ("metric"), or a message per batch of metrics
+  ## with the same topic and routing key ("batch").
+  # batch_format = "metric"
+
+  ## Kafka version of the brokers, required by the headers and the idempotent
+  ## writes, ie: "0.11.0.0".
+  # version = ""
+
+  ## Headers added to each message, requires version 0.11 or later.
+  # [outputs.kafka.headers]
+  #   source = "telegraf"
+
+  ## Make sure each message is written exactly once in the partition, even
+  ## when it is retried. Requires version 0.11 or later and required_acks = -1.
+  # idempotent_writes = false
+
+  ## Optional SASL Config
+  # sasl_username = "kafka"
+  # sasl_password = "secret"
+
   ## Optional SSL Config
   # ssl_ca = "/etc/telegraf/ca.pem"
   # ssl_cert = "/etc/telegraf/cert.pem"
@@ -104,11 +172,66 @@ func (k *Kafka) SetSerializer(serializer serializers.Serializer) {
 }
 
 func (k *Kafka) Connect() error {
+	if err := serializers.CheckBatchFormat(k.BatchFormat); err != nil {
+		return err
+	}
+
+	var err error
+	k.topic, err = templating.NewMetricTemplate(k.Topic)
+	if err != nil {
+		return fmt.Errorf("invalid topic template [%s]: %s", k.Topic, err)
+	}
+	if k.RoutingKey != "" {
+		k.routingKey, err = templating.NewMetricTemplate(k.RoutingKey)
+		if err != nil {
+			return fmt.Errorf("invalid routing_key template [%s]: %s", k.RoutingKey, err)
+		}
+	}
+
 	config := sarama.NewConfig()
 
 	config.Producer.RequiredAcks = sarama.RequiredAcks(k.RequiredAcks)
 	config.Producer.Compression = sarama.CompressionCodec(k.CompressionCodec)
 	config.Producer.Retry.Max = k.MaxRetry
+	config.Producer.Return.Successes = true
+
+	switch k.PartitionStrategy {
+	case "", partitionKey, partitionSeries:
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case partitionRoundRobin:
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	default:
+		return fmt.Errorf("invalid partition_strategy [%s], must be %s, %s or %s",
+			k.PartitionStrategy, partitionKey, partitionSeries, partitionRoundRobin)
+	}
+
+	if k.Version != "" {
+		version, err := sarama.ParseKafkaVersion(k.Version)
+		if err != nil {
+			return fmt.Errorf("invalid version [%s]: %s", k.Version, err)
+		}
+		config.Version = version
+	}
+	if len(k.Headers) > 0 && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return fmt.Errorf("headers require version 0.11.0.0 or later")
+	}
+	k.headerKeys = k.headerKeys[:0]
+	for key := range k.Headers {
+		k.headerKeys = append(k.headerKeys, key)
+	}
+	sort.Strings(k.headerKeys)
+
+	if k.IdempotentWrites {
+		if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+			return fmt.Errorf("idempotent_writes require version 0.11.0.0 or later")
+		}
+		if config.Producer.RequiredAcks != sarama.WaitForAll {
+			return fmt.Errorf("idempotent_writes require required_acks = -1")
+		}
+		config.Producer.Idempotent = true
+		// a single request in flight keeps the messages in order on retries.
+		config.Net.MaxOpenRequests = 1
+	}
 
 	// Legacy support ssl config
 	if k.Certificate != "" {
@@ -128,6 +251,12 @@ func (k *Kafka) Connect() error {
 		config.Net.TLS.Enable = true
 	}
 
+	if k.SASLUsername != "" && k.SASLPassword != "" {
+		config.Net.SASL.User = k.SASLUsername
+		config.Net.SASL.Password = k.SASLPassword
+		config.Net.SASL.Enable = true
+	}
+
 	producer, err := sarama.NewSyncProducer(k.Brokers, config)
 	if err != nil {
 		return err
@@ -153,34 +282,123 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 		return nil
 	}
 
+	if k.BatchFormat == serializers.BatchFormatBatch {
+		return k.writeBatches(metrics)
+	}
+
+	msgs := make([]*sarama.ProducerMessage, 0, len(metrics))
 	for _, metric := range metrics {
+		dest, err := k.destination(metric)
+		if err != nil {
+			return err
+		}
+
 		buf, err := k.serializer.Serialize(metric)
 		if err != nil {
 			return err
 		}
+		msgs = append(msgs, k.message(dest, buf))
+	}
+	return k.send(msgs)
+}
+
+// destination is the topic and the routing key of a message.
+type destination struct {
+	topic string
+	key   string
+}
 
-		m := &sarama.ProducerMessage{
-			Topic: k.Topic,
-			Value: sarama.ByteEncoder(buf),
+func (k *Kafka) destination(metric telegraf.Metric) (destination, error) {
+	var dest destination
+
+	if t, ok := metric.Tags()[k.TopicTag]; ok && k.TopicTag != "" && t != "" {
+		dest.topic = t
+	} else {
+		topic, err := k.topic.Execute(metric)
+		if err != nil {
+			return dest, fmt.Errorf("failed to render topic of %s: %s", metric.Name(), err)
 		}
-		if h, ok := metric.Tags()[k.RoutingTag]; ok {
-			m.Key = sarama.StringEncoder(h)
+		dest.topic = topic
+	}
+
+	switch {
+	case k.PartitionStrategy == partitionSeries:
+		dest.key = strconv.FormatUint(metric.HashID(), 16)
+	case k.routingKey != nil:
+		key, err := k.routingKey.Execute(metric)
+		if err != nil {
+			return dest, fmt.Errorf("failed to render routing key of %s: %s", metric.Name(), err)
 		}
+		dest.key = key
+	default:
+		dest.key = metric.Tags()[k.RoutingTag]
+	}
+	return dest, nil
+}
 
-		_, _, err = k.producer.SendMessage(m)
+func (k *Kafka) message(dest destination, buf []byte) *sarama.ProducerMessage {
+	m := &sarama.ProducerMessage{
+		Topic: dest.topic,
+		Value: sarama.ByteEncoder(buf),
+	}
+	if dest.key != "" {
+		m.Key = sarama.StringEncoder(dest.key)
+	}
+	for _, key := range k.headerKeys {
+		m.Headers = append(m.Headers, sarama.RecordHeader{
+			Key:   []byte(key),
+			Value: []byte(k.Headers[key]),
+		})
+	}
+	return m
+}
 
+func (k *Kafka) send(msgs []*sarama.ProducerMessage) error {
+	err := k.producer.SendMessages(msgs)
+	if errs, ok := err.(sarama.ProducerErrors); ok && len(errs) > 0 {
+		return fmt.Errorf("FAILED to send %d of %d kafka messages: %s",
+			len(errs), len(msgs), errs[0].Err)
+	}
+	if err != nil {
+		return fmt.Errorf("FAILED to send kafka messages: %s", err)
+	}
+	return nil
+}
+
+// writeBatches sends a message for each topic and routing key, holding all
+// the metrics with this destination.
+func (k *Kafka) writeBatches(metrics []telegraf.Metric) error {
+	var dests []destination
+	batches := make(map[destination][]telegraf.Metric)
+	for _, metric := range metrics {
+		dest, err := k.destination(metric)
 		if err != nil {
-			return fmt.Errorf("FAILED to send kafka message: %s\n", err)
+			return err
+		}
+		if _, ok := batches[dest]; !ok {
+			dests = append(dests, dest)
 		}
+		batches[dest] = append(batches[dest], metric)
 	}
-	return nil
+
+	msgs := make([]*sarama.ProducerMessage, 0, len(dests))
+	for _, dest := range dests {
+		buf, err := serializers.SerializeBatch(k.serializer, batches[dest])
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, k.message(dest, buf))
+	}
+	return k.send(msgs)
 }
 
 func init() {
 	outputs.Add("kafka", func() telegraf.Output {
 		return &Kafka{
-			MaxRetry:     3,
-			RequiredAcks: -1,
+			MaxRetry:          3,
+			RequiredAcks:      -1,
+			BatchFormat:       serializers.BatchFormatMetric,
+			PartitionStrategy: partitionKey,
 		}
 	})
 }
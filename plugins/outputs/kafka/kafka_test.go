@@ -1,10 +1,17 @@
 package kafka
 
 import (
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/templating"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
+
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,3 +36,191 @@ func TestConnectAndWrite(t *testing.T) {
 	err = k.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+type mockProducer struct {
+	msgs []*sarama.ProducerMessage
+}
+
+func (p *mockProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.msgs = append(p.msgs, msg)
+	return 0, 0, nil
+}
+
+func (p *mockProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	return nil
+}
+
+func testMetrics() []telegraf.Metric {
+	now := time.Unix(0, 0)
+	m1, _ := metric.New("cpu",
+		map[string]string{"host": "a", "site": "paris"},
+		map[string]interface{}{"value": 1}, now)
+	m2, _ := metric.New("mem",
+		map[string]string{"host": "b"},
+		map[string]interface{}{"value": 2}, now)
+	m3, _ := metric.New("cpu",
+		map[string]string{"host": "a", "site": "paris"},
+		map[string]interface{}{"value": 3}, now)
+	return []telegraf.Metric{m1, m2, m3}
+}
+
+type message struct {
+	topic string
+	key   string
+	value string
+}
+
+func messages(t *testing.T, p *mockProducer) []message {
+	var out []message
+	for _, m := range p.msgs {
+		var msg message
+		msg.topic = m.Topic
+		if m.Key != nil {
+			key, err := m.Key.Encode()
+			require.NoError(t, err)
+			msg.key = string(key)
+		}
+		value, err := m.Value.Encode()
+		require.NoError(t, err)
+		msg.value = string(value)
+		out = append(out, msg)
+	}
+	return out
+}
+
+func TestWriteTopicRouting(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	p := &mockProducer{}
+	k := &Kafka{
+		Topic:      "telegraf_{{ .Name }}",
+		TopicTag:   "site",
+		RoutingTag: "host",
+		producer:   p,
+		serializer: s,
+	}
+	var err error
+	k.topic, err = templating.NewMetricTemplate(k.Topic)
+	require.NoError(t, err)
+
+	metrics := testMetrics()
+	require.NoError(t, k.Write(metrics))
+	require.Equal(t, []message{
+		{"paris", "a", string(metrics[0].Serialize())},
+		{"telegraf_mem", "b", string(metrics[1].Serialize())},
+		{"paris", "a", string(metrics[2].Serialize())},
+	}, messages(t, p))
+}
+
+func TestWriteRoutingKey(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	p := &mockProducer{}
+	k := &Kafka{
+		Topic:      "telegraf",
+		RoutingTag: "host",
+		producer:   p,
+		serializer: s,
+	}
+	var err error
+	k.topic, err = templating.NewMetricTemplate(k.Topic)
+	require.NoError(t, err)
+	k.routingKey, err = templating.NewMetricTemplate(`{{ .Name }}-{{ .Tag "host" }}`)
+	require.NoError(t, err)
+
+	metrics := testMetrics()
+	require.NoError(t, k.Write(metrics))
+	require.Equal(t, []message{
+		{"telegraf", "cpu-a", string(metrics[0].Serialize())},
+		{"telegraf", "mem-b", string(metrics[1].Serialize())},
+		{"telegraf", "cpu-a", string(metrics[2].Serialize())},
+	}, messages(t, p))
+}
+
+func TestWriteSeriesPartition(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	p := &mockProducer{}
+	k := &Kafka{
+		Topic:             "telegraf",
+		RoutingTag:        "host",
+		PartitionStrategy: partitionSeries,
+		producer:          p,
+		serializer:        s,
+	}
+	var err error
+	k.topic, err = templating.NewMetricTemplate(k.Topic)
+	require.NoError(t, err)
+
+	metrics := testMetrics()
+	require.NoError(t, k.Write(metrics))
+
+	msgs := messages(t, p)
+	require.Len(t, msgs, 3)
+	require.Equal(t, strconv.FormatUint(metrics[0].HashID(), 16), msgs[0].key)
+	require.Equal(t, msgs[0].key, msgs[2].key)
+	require.NotEqual(t, msgs[0].key, msgs[1].key)
+}
+
+func TestWriteBatchTopicRouting(t *testing.T) {
+	s, _ := serializers.NewInfluxSerializer()
+	p := &mockProducer{}
+	k := &Kafka{
+		Topic:       "telegraf_{{ .Name }}",
+		RoutingTag:  "host",
+		BatchFormat: serializers.BatchFormatBatch,
+		Headers:     map[string]string{"source": "telegraf"},
+		headerKeys:  []string{"source"},
+		producer:    p,
+		serializer:  s,
+	}
+	var err error
+	k.topic, err = templating.NewMetricTemplate(k.Topic)
+	require.NoError(t, err)
+
+	metrics := testMetrics()
+	require.NoError(t, k.Write(metrics))
+	require.Equal(t, []message{
+		{"telegraf_cpu", "a",
+			string(metrics[0].Serialize()) + string(metrics[2].Serialize())},
+		{"telegraf_mem", "b", string(metrics[1].Serialize())},
+	}, messages(t, p))
+	for _, m := range p.msgs {
+		require.Equal(t, []sarama.RecordHeader{
+			{Key: []byte("source"), Value: []byte("telegraf")},
+		}, m.Headers)
+	}
+}
+
+func TestConnectErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		kafka *Kafka
+	}{
+		{
+			name:  "invalid topic template",
+			kafka: &Kafka{Topic: "{{ .Name "},
+		},
+		{
+			name:  "invalid partition strategy",
+			kafka: &Kafka{Topic: "telegraf", PartitionStrategy: "sticky"},
+		},
+		{
+			name: "idempotent writes without acks from all replicas",
+			kafka: &Kafka{
+				Topic:            "telegraf",
+				Version:          "0.11.0.0",
+				RequiredAcks:     1,
+				IdempotentWrites: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.kafka.Connect())
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/templating"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 
@@ -21,6 +22,26 @@ var sampleConfig = `
   ##   ex: prefix/web01.example.com/mem
   topic_prefix = "telegraf"
 
+  ## Topic template, replaces the topic format above when set. The template
+  ## has access to the metric name and tags, ie:
+  ##   topic = 'telegraf/{{ .Tag "site" }}/{{ .Tag "host" }}/{{ .Name }}'
+  # topic = ""
+
+  ## QoS policy for messages
+  ##   0 = at most once
+  ##   1 = at least once
+  ##   2 = exactly once
+  # qos = 0
+
+  ## When true, the broker keeps the last message of each topic and sends it
+  ## to the new subscribers.
+  # retain = false
+
+  ## When true, the broker keeps the session, and the QoS 1 and 2 messages
+  ## in flight, when telegraf is disconnected.
+  ## NOTE: if true, client_id MUST be set
+  # persistent_session = false
+
   ## username and password to connect MQTT server.
   # username = "telegraf"
   # password = "metricsmetricsmetricsmetrics"
@@ -49,9 +70,13 @@ type MQTT struct {
 	Database    string
 	Timeout     internal.Duration
 	TopicPrefix string
+	Topic       string
 	QoS         int    `toml:"qos"`
 	ClientID    string `toml:"client_id"`
 
+	Retain            bool
+	PersistentSession bool
+
 	// Path to CA file
 	SSLCA string `toml:"ssl_ca"`
 	// Path to host cert file
@@ -63,6 +88,7 @@ type MQTT struct {
 
 	client paho.Client
 	opts   *paho.ClientOptions
+	topic  *templating.MetricTemplate
 
 	serializer serializers.Serializer
 
@@ -77,6 +103,23 @@ func (m *MQTT) Connect() error {
 		return fmt.Errorf("MQTT Output, invalid QoS value: %d", m.QoS)
 	}
 
+	if m.PersistentSession && m.ClientID == "" {
+		return fmt.Errorf("MQTT Output, when using persistent_session" +
+			" = true, you MUST also set client_id")
+	}
+
+	if strings.ContainsAny(m.TopicPrefix, "+#") {
+		return fmt.Errorf("MQTT Output, topic_prefix contains a wildcard: %s",
+			m.TopicPrefix)
+	}
+
+	if m.Topic != "" {
+		m.topic, err = templating.NewMetricTemplate(m.Topic)
+		if err != nil {
+			return fmt.Errorf("MQTT Output, invalid topic template: %s", err)
+		}
+	}
+
 	m.opts, err = m.createOpts()
 	if err != nil {
 		return err
@@ -121,17 +164,12 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	}
 
 	for _, metric := range metrics {
-		var t []string
-		if m.TopicPrefix != "" {
-			t = append(t, m.TopicPrefix)
-		}
-		if hostname != "" {
-			t = append(t, hostname)
+		topic, err := m.topicFor(metric, hostname)
+		if err != nil {
+			return fmt.Errorf("MQTT Could not render topic for metric %s: %s",
+				metric.Name(), err)
 		}
 
-		t = append(t, metric.Name())
-		topic := strings.Join(t, "/")
-
 		buf, err := m.serializer.Serialize(metric)
 		if err != nil {
 			return fmt.Errorf("MQTT Could not serialize metric: %s",
@@ -147,8 +185,69 @@ func (m *MQTT) Write(metrics []telegraf.Metric) error {
 	return nil
 }
 
+// topicFor returns the topic of the metric, built from the topic template or
+// from the prefix, the hostname and the metric name. The wildcards and the
+// level separator are replaced in the metric name and tag values, and the
+// empty levels are removed.
+func (m *MQTT) topicFor(metric telegraf.Metric, hostname string) (string, error) {
+	if m.topic != nil {
+		topic, err := m.topic.Execute(topicMetric{metric})
+		if err != nil {
+			return "", err
+		}
+		if strings.ContainsAny(topic, "+#") {
+			return "", fmt.Errorf("topic %q contains a wildcard", topic)
+		}
+		return joinTopicLevels(strings.Split(topic, "/"))
+	}
+
+	t := strings.Split(m.TopicPrefix, "/")
+	t = append(t, sanitizeTopicLevel(hostname), sanitizeTopicLevel(metric.Name()))
+	return joinTopicLevels(t)
+}
+
+var topicLevelReplacer = strings.NewReplacer("+", "_", "#", "_", "/", "_")
+
+// sanitizeTopicLevel replaces the characters of s which can not be used in a
+// topic level.
+func sanitizeTopicLevel(s string) string {
+	return topicLevelReplacer.Replace(s)
+}
+
+// joinTopicLevels joins the non empty levels into a topic.
+func joinTopicLevels(levels []string) (string, error) {
+	t := levels[:0]
+	for _, level := range levels {
+		if level != "" {
+			t = append(t, level)
+		}
+	}
+	if len(t) == 0 {
+		return "", fmt.Errorf("empty topic")
+	}
+	return strings.Join(t, "/"), nil
+}
+
+// topicMetric exposes the metric name and tags sanitized to the topic
+// template.
+type topicMetric struct {
+	telegraf.Metric
+}
+
+func (m topicMetric) Name() string {
+	return sanitizeTopicLevel(m.Metric.Name())
+}
+
+func (m topicMetric) Tags() map[string]string {
+	tags := make(map[string]string, len(m.Metric.Tags()))
+	for k, v := range m.Metric.Tags() {
+		tags[k] = sanitizeTopicLevel(v)
+	}
+	return tags
+}
+
 func (m *MQTT) publish(topic string, body []byte) error {
-	token := m.client.Publish(topic, byte(m.QoS), false, body)
+	token := m.client.Publish(topic, byte(m.QoS), m.Retain, body)
 	token.Wait()
 	if token.Error() != nil {
 		return token.Error()
@@ -195,6 +294,7 @@ func (m *MQTT) createOpts() (*paho.ClientOptions, error) {
 		opts.AddBroker(server)
 	}
 	opts.SetAutoReconnect(true)
+	opts.SetCleanSession(!m.PersistentSession)
 	return opts, nil
 }
 
@@ -2,10 +2,15 @@ package mqtt
 
 import (
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/templating"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,3 +34,145 @@ func TestConnectAndWrite(t *testing.T) {
 	err = m.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+func newTestMQTT(t *testing.T, broker *testutil.MQTTBroker) *MQTT {
+	s, err := serializers.NewInfluxSerializer()
+	require.NoError(t, err)
+	return &MQTT{
+		Servers:    []string{broker.Addr()},
+		serializer: s,
+	}
+}
+
+func TestWriteTopicPrefix(t *testing.T) {
+	broker, err := testutil.NewMQTTBroker()
+	require.NoError(t, err)
+	defer broker.Close()
+
+	m := newTestMQTT(t, broker)
+	m.TopicPrefix = "telegraf"
+	// QoS 1 waits for the broker acknowledgement
+	m.QoS = 1
+	require.NoError(t, m.Connect())
+	defer m.Close()
+
+	metrics := testutil.MockMetrics()
+	require.NoError(t, m.Write(metrics))
+
+	published := broker.Published()
+	require.Len(t, published, 1)
+	assert.Equal(t, "telegraf/test1", published[0].Topic)
+	assert.Equal(t, metrics[0].Serialize(), published[0].Payload)
+	assert.Equal(t, byte(1), published[0].QoS)
+	assert.False(t, published[0].Retained)
+}
+
+func TestWriteTopicTemplate(t *testing.T) {
+	broker, err := testutil.NewMQTTBroker()
+	require.NoError(t, err)
+	defer broker.Close()
+
+	m := newTestMQTT(t, broker)
+	m.TopicPrefix = "ignored"
+	m.Topic = `sites/{{ .Tag "site" }}/{{ .Tag "host" }}/{{ .Name }}`
+	m.QoS = 2
+	m.Retain = true
+	require.NoError(t, m.Connect())
+	defer m.Close()
+
+	m1, _ := metric.New("cpu",
+		map[string]string{"site": "paris", "host": "web01"},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0))
+	m2, _ := metric.New("mem",
+		map[string]string{"host": "web02"},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0))
+	require.NoError(t, m.Write([]telegraf.Metric{m1, m2}))
+
+	published := broker.Published()
+	require.Len(t, published, 2)
+	assert.Equal(t, "sites/paris/web01/cpu", published[0].Topic)
+	assert.Equal(t, "sites/web02/mem", published[1].Topic)
+	for _, msg := range published {
+		assert.Equal(t, byte(2), msg.QoS)
+		assert.True(t, msg.Retained)
+	}
+}
+
+func TestTopicForSanitize(t *testing.T) {
+	m1, _ := metric.New("cpu/total",
+		map[string]string{"site": "a+b#c/d", "host": "web01"},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0))
+	m2, _ := metric.New("mem",
+		map[string]string{},
+		map[string]interface{}{"value": 42.0},
+		time.Unix(0, 0))
+
+	m := &MQTT{TopicPrefix: "/telegraf//prod/"}
+	topic, err := m.topicFor(m1, "web/01")
+	require.NoError(t, err)
+	assert.Equal(t, "telegraf/prod/web_01/cpu_total", topic)
+	topic, err = m.topicFor(m2, "")
+	require.NoError(t, err)
+	assert.Equal(t, "telegraf/prod/mem", topic)
+
+	m.topic, err = templating.NewMetricTemplate(
+		`sites/{{ .Tag "site" }}/{{ .Tag "host" }}/{{ .Name }}/`)
+	require.NoError(t, err)
+	topic, err = m.topicFor(m1, "")
+	require.NoError(t, err)
+	assert.Equal(t, "sites/a_b_c_d/web01/cpu_total", topic)
+	topic, err = m.topicFor(m2, "")
+	require.NoError(t, err)
+	assert.Equal(t, "sites/mem", topic)
+
+	m.topic, err = templating.NewMetricTemplate(`sites/+/{{ .Name }}`)
+	require.NoError(t, err)
+	_, err = m.topicFor(m1, "")
+	assert.Error(t, err)
+
+	m.topic, err = templating.NewMetricTemplate(`{{ .Tag "site" }}`)
+	require.NoError(t, err)
+	_, err = m.topicFor(m2, "")
+	assert.Error(t, err)
+}
+
+func TestConnectErrors(t *testing.T) {
+	m := &MQTT{
+		Servers: []string{"localhost:1883"},
+		QoS:     3,
+	}
+	assert.Error(t, m.Connect())
+
+	m = &MQTT{
+		Servers:           []string{"localhost:1883"},
+		PersistentSession: true,
+	}
+	assert.Error(t, m.Connect())
+
+	m = &MQTT{
+		Servers: []string{"localhost:1883"},
+		Topic:   "telegraf/{{ .Name ",
+	}
+	assert.Error(t, m.Connect())
+
+	m = &MQTT{
+		Servers:     []string{"localhost:1883"},
+		TopicPrefix: "telegraf/#",
+	}
+	assert.Error(t, m.Connect())
+}
+
+func TestPersistentSession(t *testing.T) {
+	m := &MQTT{
+		Servers:           []string{"localhost:1883"},
+		ClientID:          "telegraf-test",
+		PersistentSession: true,
+	}
+	opts, err := m.createOpts()
+	require.NoError(t, err)
+	assert.False(t, opts.CleanSession)
+	assert.Equal(t, "telegraf-test", opts.ClientID)
+}
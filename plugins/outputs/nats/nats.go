@@ -19,6 +19,8 @@ type NATS struct {
 	Password string
 	// NATS subject to publish metrics to
 	Subject string
+	// Send a message per metric or per batch
+	BatchFormat string `toml:"batch_format"`
 
 	// Path to CA file
 	SSLCA string `toml:"ssl_ca"`
@@ -42,6 +44,10 @@ var sampleConfig = `
   ## NATS subject for producer messages
   subject = "telegraf"
 
+  ## Send a message per metric ("metric"), or a message per batch of metrics
+  ## ("batch").
+  # batch_format = "metric"
+
   ## Optional SSL Config
   # ssl_ca = "/etc/telegraf/ca.pem"
   # ssl_cert = "/etc/telegraf/cert.pem"
@@ -63,6 +69,10 @@ func (n *NATS) SetSerializer(serializer serializers.Serializer) {
 func (n *NATS) Connect() error {
 	var err error
 
+	if err = serializers.CheckBatchFormat(n.BatchFormat); err != nil {
+		return err
+	}
+
 	// set default NATS connection options
 	opts := nats_client.DefaultOptions
 
@@ -114,6 +124,19 @@ func (n *NATS) Write(metrics []telegraf.Metric) error {
 		return nil
 	}
 
+	if n.BatchFormat == serializers.BatchFormatBatch {
+		buf, err := serializers.SerializeBatch(n.serializer, metrics)
+		if err != nil {
+			return err
+		}
+
+		err = n.conn.Publish(n.Subject, buf)
+		if err != nil {
+			return fmt.Errorf("FAILED to send NATS message: %s", err)
+		}
+		return nil
+	}
+
 	for _, metric := range metrics {
 		buf, err := n.serializer.Serialize(metric)
 		if err != nil {
@@ -130,6 +153,8 @@ func (n *NATS) Write(metrics []telegraf.Metric) error {
 
 func init() {
 	outputs.Add("nats", func() telegraf.Output {
-		return &NATS{}
+		return &NATS{
+			BatchFormat: serializers.BatchFormatMetric,
+		}
 	})
 }
@@ -0,0 +1,295 @@
+package opentelemetry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/otlp"
+
+	"golang.org/x/net/http2"
+)
+
+// Maximum size of a response read from the collector.
+const maxResponseSize = 4 * 1024 * 1024
+
+// APIError is returned when an OTLP/HTTP request is not accepted.
+type APIError struct {
+	StatusCode int
+	// Message is read from the status returned by the collector, if any.
+	Message string
+	// RetryAfter is the delay requested by the collector before retrying.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("received status code %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
+
+type ClientConfig struct {
+	// URL of the collector, the path is only used by OTLP/HTTP.
+	URL         string
+	Compression string
+	UserAgent   string
+	Headers     map[string]string
+	Timeout     time.Duration
+	TLSConfig   *tls.Config
+}
+
+type client interface {
+	Export(req *otlp.ExportMetricsServiceRequest) (*otlp.ExportMetricsServiceResponse, error)
+}
+
+func parseURL(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url [%s]: %s", rawurl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url [%s] scheme must be http(s), got %s",
+			rawurl, u.Scheme)
+	}
+	return u, nil
+}
+
+// grpcClient calls the Export method of the metrics service, with unary gRPC
+// calls over HTTP/2. A "http" url connects without TLS.
+type grpcClient struct {
+	config ClientConfig
+	url    string
+	client *http.Client
+}
+
+func newGRPCClient(config ClientConfig) (*grpcClient, error) {
+	u, err := parseURL(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http2.Transport{TLSClientConfig: config.TLSConfig}
+	if u.Scheme == "http" {
+		// HTTP/2 without TLS, with prior knowledge.
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, config.Timeout)
+		}
+	}
+
+	return &grpcClient{
+		config: config,
+		url:    u.Scheme + "://" + u.Host + otlp.GRPCExportPath,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+func (c *grpcClient) Export(req *otlp.ExportMetricsServiceRequest) (*otlp.ExportMetricsServiceResponse, error) {
+	msg, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	compress := c.config.Compression == "gzip"
+	var body bytes.Buffer
+	if err := otlp.WriteFrame(&body, msg, compress); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", c.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	// custom metadata first, so that it cannot override the protocol headers.
+	for k, v := range c.config.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpReq.Header.Set("Content-Type", otlp.GRPCContentType)
+	httpReq.Header.Set("TE", "trailers")
+	httpReq.Header.Set("User-Agent", c.config.UserAgent)
+	httpReq.Header.Set("Grpc-Accept-Encoding", "gzip")
+	if compress {
+		httpReq.Header.Set("Grpc-Encoding", "gzip")
+	}
+	if c.config.Timeout > 0 {
+		httpReq.Header.Set("Grpc-Timeout",
+			strconv.FormatInt(int64(c.config.Timeout/time.Millisecond), 10)+"m")
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &otlp.Status{
+			Code:    httpStatusCode(resp.StatusCode),
+			Message: fmt.Sprintf("received http status code %d", resp.StatusCode),
+		}
+	}
+
+	respMsg, err := otlp.ReadFrame(resp.Body, maxResponseSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// the trailers are only known once the body has been read.
+	ioutil.ReadAll(resp.Body)
+
+	if status := grpcStatus(resp); status != nil {
+		return nil, status
+	}
+
+	exportResp := &otlp.ExportMetricsServiceResponse{}
+	if err := exportResp.Unmarshal(respMsg); err != nil {
+		return nil, err
+	}
+	return exportResp, nil
+}
+
+// grpcStatus returns the status of a call, read from the trailers, or from the
+// headers for a response without a body. A missing status is an error.
+func grpcStatus(resp *http.Response) *otlp.Status {
+	header := resp.Trailer
+	if header.Get("Grpc-Status") == "" {
+		header = resp.Header
+	}
+
+	value := header.Get("Grpc-Status")
+	if value == "" {
+		return &otlp.Status{Code: otlp.Internal, Message: "missing grpc-status"}
+	}
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return &otlp.Status{Code: otlp.Unknown, Message: "invalid grpc-status " + value}
+	}
+	if otlp.Code(code) == otlp.OK {
+		return nil
+	}
+	return &otlp.Status{
+		Code:    otlp.Code(code),
+		Message: otlp.DecodeGRPCMessage(header.Get("Grpc-Message")),
+	}
+}
+
+// httpStatusCode maps the status code of a response which is not a gRPC
+// response to a gRPC code, as defined in the gRPC specification.
+func httpStatusCode(status int) otlp.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return otlp.Internal
+	case http.StatusUnauthorized:
+		return otlp.Unauthenticated
+	case http.StatusForbidden:
+		return otlp.PermissionDenied
+	case http.StatusNotFound:
+		return otlp.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return otlp.Unavailable
+	}
+	return otlp.Unknown
+}
+
+// httpClient posts the requests in the protobuf encoding to an OTLP/HTTP
+// endpoint.
+type httpClient struct {
+	config ClientConfig
+	client *http.Client
+}
+
+func newHTTPClient(config ClientConfig) (*httpClient, error) {
+	if _, err := parseURL(config.URL); err != nil {
+		return nil, err
+	}
+
+	return &httpClient{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: config.TLSConfig,
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+func (c *httpClient) Export(req *otlp.ExportMetricsServiceRequest) (*otlp.ExportMetricsServiceResponse, error) {
+	msg, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = bytes.NewReader(msg)
+	if c.config.Compression == "gzip" {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(msg); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = &zbuf
+	}
+
+	httpReq, err := http.NewRequest("POST", c.config.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.config.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpReq.Header.Set("Content-Type", otlp.ProtobufContentType)
+	httpReq.Header.Set("User-Agent", c.config.UserAgent)
+	if c.config.Compression == "gzip" {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	isProtobuf := strings.HasPrefix(resp.Header.Get("Content-Type"), otlp.ProtobufContentType)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var status otlp.Status
+		if isProtobuf && status.Unmarshal(respBody) == nil {
+			apiErr.Message = status.Message
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, apiErr
+	}
+
+	exportResp := &otlp.ExportMetricsServiceResponse{}
+	if isProtobuf {
+		if err := exportResp.Unmarshal(respBody); err != nil {
+			return nil, err
+		}
+	}
+	return exportResp, nil
+}
@@ -0,0 +1,481 @@
+package opentelemetry
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/otlp"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+const (
+	defaultGRPCURL = "http://localhost:4317"
+	defaultHTTPURL = "http://localhost:4318" + otlp.HTTPMetricsPath
+
+	// Name of the instrumentation scope of the metrics.
+	scopeName = "telegraf"
+)
+
+var sampleConfig = `
+  ## Protocol used to send the metrics, "grpc" or "http" for OTLP/HTTP with
+  ## the protobuf encoding.
+  # protocol = "grpc"
+
+  ## URL of the collector. With grpc, use the https scheme to connect with
+  ## TLS, the path is ignored. With http, the url of the metrics endpoint.
+  ## Defaults to "http://localhost:4317" for grpc and to
+  ## "http://localhost:4318/v1/metrics" for http.
+  # url = "http://localhost:4317"
+
+  ## Timeout of a request.
+  # timeout = "5s"
+
+  ## Compress the requests, can be "none" or "gzip".
+  # compression = "gzip"
+
+  ## Tags set as attributes of the resource of the metrics, the other tags
+  ## are attributes of the data points.
+  # resource_tags = ["host"]
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Additional resource attributes, set on all the metrics.
+  # [outputs.opentelemetry.attributes]
+  #   "service.name" = "telegraf"
+
+  ## Additional HTTP headers, or gRPC metadata.
+  # [outputs.opentelemetry.headers]
+  #   X-Scope-OrgID = "telegraf"
+`
+
+type OpenTelemetry struct {
+	Protocol     string
+	URL          string
+	Timeout      internal.Duration
+	Compression  string
+	ResourceTags []string `toml:"resource_tags"`
+	Attributes   map[string]string
+	Headers      map[string]string
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client client
+	// the requests are not sent before this time, requested by the
+	// collector with a Retry-After header.
+	retryAfter time.Time
+}
+
+func (o *OpenTelemetry) Connect() error {
+	switch o.Compression {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("invalid compression [%s], must be none or gzip", o.Compression)
+	}
+
+	tlsConfig, err := internal.GetTLSConfig(
+		o.SSLCert, o.SSLKey, o.SSLCA, o.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	config := ClientConfig{
+		URL:         o.URL,
+		Compression: o.Compression,
+		UserAgent:   "telegraf",
+		Headers:     o.Headers,
+		Timeout:     o.Timeout.Duration,
+		TLSConfig:   tlsConfig,
+	}
+
+	switch o.Protocol {
+	case "", "grpc":
+		if config.URL == "" {
+			config.URL = defaultGRPCURL
+		}
+		o.client, err = newGRPCClient(config)
+	case "http":
+		if config.URL == "" {
+			config.URL = defaultHTTPURL
+		}
+		o.client, err = newHTTPClient(config)
+	default:
+		return fmt.Errorf("invalid protocol [%s], must be grpc or http", o.Protocol)
+	}
+	if err != nil {
+		return fmt.Errorf("Error creating OpenTelemetry client [%s]: %s", config.URL, err)
+	}
+	return nil
+}
+
+func (o *OpenTelemetry) Close() error {
+	return nil
+}
+
+func (o *OpenTelemetry) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpenTelemetry) Description() string {
+	return "Send metrics to an OpenTelemetry collector with OTLP"
+}
+
+// Write sends the metrics in a single export request. The metrics are kept
+// in the buffer, and sent again on the next flush, when the request fails
+// with a retryable error. The request is dropped if it is rejected by the
+// collector with a permanent error.
+func (o *OpenTelemetry) Write(metrics []telegraf.Metric) error {
+	req := o.convert(metrics)
+	if len(req.ResourceMetrics) == 0 {
+		return nil
+	}
+	if time.Now().Before(o.retryAfter) {
+		return fmt.Errorf("OpenTelemetry output: collector asked to retry after %s",
+			o.retryAfter.Format(time.RFC3339))
+	}
+
+	resp, err := o.client.Export(req)
+	if err == nil {
+		if p := resp.PartialSuccess; p != nil && (p.RejectedDataPoints > 0 || p.ErrorMessage != "") {
+			log.Printf("W! OpenTelemetry output: %d data points rejected: %s",
+				p.RejectedDataPoints, p.ErrorMessage)
+		}
+		return nil
+	}
+
+	delay, ok := retryable(err)
+	if !ok {
+		log.Printf("E! OpenTelemetry output: dropping %d metrics: %s", len(metrics), err)
+		return nil
+	}
+	if delay > 0 {
+		o.retryAfter = time.Now().Add(delay)
+	}
+	return fmt.Errorf("OpenTelemetry output: could not write metrics: %s", err)
+}
+
+// retryable returns whether a failed request can be retried, and the delay
+// requested by the collector if any. Network errors are retryable.
+func retryable(err error) (time.Duration, bool) {
+	switch err := err.(type) {
+	case *otlp.Status:
+		switch err.Code {
+		case otlp.Canceled, otlp.DeadlineExceeded, otlp.Aborted, otlp.OutOfRange,
+			otlp.Unavailable, otlp.DataLoss:
+			return 0, true
+		}
+		return 0, false
+	case *APIError:
+		switch err.StatusCode {
+		case 429, 502, 503, 504:
+			return err.RetryAfter, true
+		}
+		return 0, false
+	}
+	return 0, true
+}
+
+// convert returns the export request of the metrics. The metrics are grouped
+// by resource, the attributes of the resource being the resource tags, and a
+// metric is created for each field.
+func (o *OpenTelemetry) convert(metrics []telegraf.Metric) *otlp.ExportMetricsServiceRequest {
+	req := &otlp.ExportMetricsServiceRequest{}
+	resources := make(map[string]*resource)
+
+	for _, m := range metrics {
+		resourceTags, pointTags := o.splitTags(m.Tags())
+		key := tagsKey(resourceTags)
+		r, ok := resources[key]
+		if !ok {
+			r = newResource(o.resourceAttributes(resourceTags))
+			resources[key] = r
+			req.ResourceMetrics = append(req.ResourceMetrics, r.metrics)
+		}
+		r.add(m, attributes(pointTags))
+	}
+
+	// skip the resources without numeric fields.
+	var resourceMetrics []*otlp.ResourceMetrics
+	for _, rm := range req.ResourceMetrics {
+		if len(rm.ScopeMetrics[0].Metrics) > 0 {
+			resourceMetrics = append(resourceMetrics, rm)
+		}
+	}
+	req.ResourceMetrics = resourceMetrics
+	return req
+}
+
+func (o *OpenTelemetry) splitTags(tags map[string]string) (map[string]string, map[string]string) {
+	resourceTags := make(map[string]string)
+	for _, k := range o.ResourceTags {
+		if v, ok := tags[k]; ok {
+			resourceTags[k] = v
+		}
+	}
+
+	pointTags := make(map[string]string)
+	for k, v := range tags {
+		if _, ok := resourceTags[k]; !ok {
+			pointTags[k] = v
+		}
+	}
+	return resourceTags, pointTags
+}
+
+func (o *OpenTelemetry) resourceAttributes(tags map[string]string) map[string]string {
+	attrs := make(map[string]string)
+	for k, v := range o.Attributes {
+		attrs[k] = v
+	}
+	for k, v := range tags {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+func tagsKey(tags map[string]string) string {
+	var parts []string
+	for k, v := range tags {
+		parts = append(parts, k+"\x00"+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\x00")
+}
+
+// attributes returns the attributes of the tags, sorted by key.
+func attributes(tags map[string]string) []*otlp.KeyValue {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]*otlp.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, &otlp.KeyValue{Key: k, Value: tags[k]})
+	}
+	return attrs
+}
+
+// resource holds the metrics of a resource, with a single scope.
+type resource struct {
+	metrics *otlp.ResourceMetrics
+	scope   *otlp.ScopeMetrics
+	// index of the metrics by name and type.
+	index map[string]*otlp.Metric
+}
+
+func newResource(attrs map[string]string) *resource {
+	scope := &otlp.ScopeMetrics{
+		Scope: &otlp.InstrumentationScope{Name: scopeName},
+	}
+	return &resource{
+		metrics: &otlp.ResourceMetrics{
+			Resource:     &otlp.Resource{Attributes: attributes(attrs)},
+			ScopeMetrics: []*otlp.ScopeMetrics{scope},
+		},
+		scope: scope,
+		index: make(map[string]*otlp.Metric),
+	}
+}
+
+// metric returns the metric of the name and type, created on first use.
+func (r *resource) metric(name string, kind string) *otlp.Metric {
+	key := kind + ":" + name
+	if m, ok := r.index[key]; ok {
+		return m
+	}
+
+	m := &otlp.Metric{Name: name}
+	switch kind {
+	case "gauge":
+		m.Gauge = &otlp.Gauge{}
+	case "sum":
+		m.Sum = &otlp.Sum{
+			AggregationTemporality: otlp.AggregationTemporalityCumulative,
+			IsMonotonic:            true,
+		}
+	case "histogram":
+		m.Histogram = &otlp.Histogram{
+			AggregationTemporality: otlp.AggregationTemporalityCumulative,
+		}
+	}
+	r.index[key] = m
+	r.scope.Metrics = append(r.scope.Metrics, m)
+	return m
+}
+
+// add adds the data points of the fields of a metric. Counters are monotonic
+// sums, the other numeric fields are gauges, and the string and boolean
+// fields are skipped. The histograms of the prometheus input are converted
+// to histograms.
+func (r *resource) add(m telegraf.Metric, attrs []*otlp.KeyValue) {
+	ts := uint64(m.Time().UnixNano())
+	fields := m.Fields()
+
+	if p, ok := histogramPoint(fields); ok {
+		p.Attributes = attrs
+		p.TimeUnixNano = ts
+		h := r.metric(m.Name(), "histogram").Histogram
+		h.DataPoints = append(h.DataPoints, p)
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value, ok := numberValue(fields[k])
+		if !ok {
+			continue
+		}
+
+		name := m.Name() + "_" + k
+		if k == "value" {
+			name = m.Name()
+		}
+		p := &otlp.NumberDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: ts,
+			Value:        value,
+		}
+
+		if m.Type() == telegraf.Counter {
+			s := r.metric(name, "sum").Sum
+			s.DataPoints = append(s.DataPoints, p)
+		} else {
+			g := r.metric(name, "gauge").Gauge
+			g.DataPoints = append(g.DataPoints, p)
+		}
+	}
+}
+
+// numberValue returns the value of a numeric field as a float64 or an int64.
+func numberValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return v, true
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v), true
+		}
+		return int64(v), true
+	}
+	return nil, false
+}
+
+// histogramPoint returns the data point of a histogram of the prometheus
+// input: the cumulative count of each bucket is in a field named after its
+// upper bound, "+Inf" included, along with the "count" and "sum" fields.
+func histogramPoint(fields map[string]interface{}) (*otlp.HistogramDataPoint, bool) {
+	if _, ok := fields["+Inf"]; !ok {
+		return nil, false
+	}
+	count, ok := floatValue(fields["count"])
+	if !ok {
+		return nil, false
+	}
+	sum, ok := floatValue(fields["sum"])
+	if !ok {
+		return nil, false
+	}
+
+	var buckets []bucket
+	for k, v := range fields {
+		if k == "count" || k == "sum" || k == "+Inf" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(k, 64)
+		if err != nil {
+			return nil, false
+		}
+		cumulative, ok := floatValue(v)
+		if !ok {
+			return nil, false
+		}
+		buckets = append(buckets, bucket{bound, cumulative})
+	}
+	sort.Sort(byBound(buckets))
+
+	p := &otlp.HistogramDataPoint{
+		Count: uint64(count),
+		Sum:   &sum,
+	}
+	var previous float64
+	for _, b := range buckets {
+		p.ExplicitBounds = append(p.ExplicitBounds, b.bound)
+		p.BucketCounts = append(p.BucketCounts, bucketCount(b.cumulative, previous))
+		previous = b.cumulative
+	}
+	// the last bucket counts the values above the last bound.
+	p.BucketCounts = append(p.BucketCounts, bucketCount(count, previous))
+	return p, true
+}
+
+type bucket struct {
+	bound      float64
+	cumulative float64
+}
+
+type byBound []bucket
+
+func (b byBound) Len() int           { return len(b) }
+func (b byBound) Less(i, j int) bool { return b[i].bound < b[j].bound }
+func (b byBound) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// bucketCount returns the count of a bucket from the cumulative counts.
+func bucketCount(cumulative, previous float64) uint64 {
+	if cumulative < previous {
+		return 0
+	}
+	return uint64(cumulative - previous)
+}
+
+func floatValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func init() {
+	outputs.Add("opentelemetry", func() telegraf.Output {
+		return &OpenTelemetry{
+			Protocol:    "grpc",
+			Timeout:     internal.Duration{Duration: 5 * time.Second},
+			Compression: "gzip",
+		}
+	})
+}
@@ -0,0 +1,388 @@
+package opentelemetry
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/otlp"
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// testCollector records the export requests, and fails the first ones with
+// the given errors.
+type testCollector struct {
+	sync.Mutex
+	requests []*otlp.ExportMetricsServiceRequest
+	headers  []http.Header
+	// gRPC status codes, or HTTP status codes, of the next responses.
+	errors []int
+}
+
+func (c *testCollector) record(r *http.Request, body []byte) int {
+	req := &otlp.ExportMetricsServiceRequest{}
+	if err := req.Unmarshal(body); err != nil {
+		return -1
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header)
+	if len(c.errors) == 0 {
+		return 0
+	}
+	code := c.errors[0]
+	c.errors = c.errors[1:]
+	return code
+}
+
+func (c *testCollector) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", otlp.GRPCContentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	body, err := otlp.ReadFrame(r.Body, 1024*1024)
+	code := c.record(r, body)
+	if err != nil || r.URL.Path != otlp.GRPCExportPath || code < 0 {
+		w.Header().Set("Grpc-Status", strconv.Itoa(int(otlp.Internal)))
+		w.Header().Set("Grpc-Message", otlp.EncodeGRPCMessage("invalid request"))
+		return
+	}
+
+	if code != 0 {
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", otlp.EncodeGRPCMessage("export failed"))
+		return
+	}
+
+	resp, _ := (&otlp.ExportMetricsServiceResponse{}).Marshal()
+	otlp.WriteFrame(w, resp, false)
+	w.Header().Set("Grpc-Status", "0")
+}
+
+func (c *testCollector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	b, err := ioutil.ReadAll(body)
+	code := c.record(r, b)
+	if err != nil || code < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", otlp.ProtobufContentType)
+	if code != 0 {
+		if code == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "5")
+		}
+		w.WriteHeader(code)
+		status, _ := (&otlp.Status{Code: otlp.Unavailable, Message: "export failed"}).Marshal()
+		w.Write(status)
+		return
+	}
+
+	resp, _ := (&otlp.ExportMetricsServiceResponse{
+		PartialSuccess: &otlp.ExportMetricsPartialSuccess{RejectedDataPoints: 1},
+	}).Marshal()
+	w.Write(resp)
+}
+
+// startGRPCServer starts a gRPC server without TLS, with HTTP/2 prior
+// knowledge.
+func startGRPCServer(t *testing.T, handler http.HandlerFunc) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go (&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	return "http://" + l.Addr().String(), func() { l.Close() }
+}
+
+func newTestOpenTelemetry(protocol, url string) *OpenTelemetry {
+	return &OpenTelemetry{
+		Protocol:     protocol,
+		URL:          url,
+		Timeout:      internal.Duration{Duration: 5 * time.Second},
+		Compression:  "gzip",
+		ResourceTags: []string{"host"},
+		Headers:      map[string]string{"X-Scope-OrgID": "telegraf"},
+	}
+}
+
+func testMetrics() []telegraf.Metric {
+	m1, _ := metric.New("cpu",
+		map[string]string{"host": "localhost", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 98.5},
+		time.Unix(0, 1))
+	return []telegraf.Metric{m1}
+}
+
+func TestConvert(t *testing.T) {
+	ts := time.Unix(1487067700, 0)
+	cpu, _ := metric.New("cpu",
+		map[string]string{"host": "a", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 98.5, "usage_user": int64(1), "state": "on"},
+		ts)
+	netMetric, _ := metric.New("net",
+		map[string]string{"host": "a", "interface": "eth0"},
+		map[string]interface{}{"bytes_recv": int64(42)},
+		ts, telegraf.Counter)
+	disk, _ := metric.New("disk",
+		map[string]string{"host": "b"},
+		map[string]interface{}{"value": 1.5},
+		ts)
+	hist, _ := metric.New("http_duration_seconds",
+		map[string]string{"host": "a"},
+		map[string]interface{}{
+			"0.5": 3.0, "0.1": 1.0, "+Inf": 4.0, "count": 4.0, "sum": 1.2,
+		},
+		ts)
+	service, _ := metric.New("service",
+		map[string]string{"host": "c"},
+		map[string]interface{}{"state": "running"},
+		ts)
+
+	o := newTestOpenTelemetry("grpc", "")
+	o.Attributes = map[string]string{"service.name": "telegraf"}
+	req := o.convert([]telegraf.Metric{cpu, netMetric, disk, hist, service})
+
+	nanos := uint64(ts.UnixNano())
+	require.Len(t, req.ResourceMetrics, 2)
+
+	a := req.ResourceMetrics[0]
+	assert.Equal(t, []*otlp.KeyValue{
+		{Key: "host", Value: "a"},
+		{Key: "service.name", Value: "telegraf"},
+	}, a.Resource.Attributes)
+	require.Len(t, a.ScopeMetrics, 1)
+	assert.Equal(t, "telegraf", a.ScopeMetrics[0].Scope.Name)
+
+	sum := 1.2
+	assert.Equal(t, []*otlp.Metric{
+		{
+			Name: "cpu_usage_idle",
+			Gauge: &otlp.Gauge{DataPoints: []*otlp.NumberDataPoint{{
+				Attributes:   []*otlp.KeyValue{{Key: "cpu", Value: "cpu0"}},
+				TimeUnixNano: nanos,
+				Value:        98.5,
+			}}},
+		},
+		{
+			Name: "cpu_usage_user",
+			Gauge: &otlp.Gauge{DataPoints: []*otlp.NumberDataPoint{{
+				Attributes:   []*otlp.KeyValue{{Key: "cpu", Value: "cpu0"}},
+				TimeUnixNano: nanos,
+				Value:        int64(1),
+			}}},
+		},
+		{
+			Name: "net_bytes_recv",
+			Sum: &otlp.Sum{
+				DataPoints: []*otlp.NumberDataPoint{{
+					Attributes:   []*otlp.KeyValue{{Key: "interface", Value: "eth0"}},
+					TimeUnixNano: nanos,
+					Value:        int64(42),
+				}},
+				AggregationTemporality: otlp.AggregationTemporalityCumulative,
+				IsMonotonic:            true,
+			},
+		},
+		{
+			Name: "http_duration_seconds",
+			Histogram: &otlp.Histogram{
+				DataPoints: []*otlp.HistogramDataPoint{{
+					Attributes:     []*otlp.KeyValue{},
+					TimeUnixNano:   nanos,
+					Count:          4,
+					Sum:            &sum,
+					BucketCounts:   []uint64{1, 2, 1},
+					ExplicitBounds: []float64{0.1, 0.5},
+				}},
+				AggregationTemporality: otlp.AggregationTemporalityCumulative,
+			},
+		},
+	}, a.ScopeMetrics[0].Metrics)
+
+	b := req.ResourceMetrics[1]
+	assert.Equal(t, []*otlp.KeyValue{
+		{Key: "host", Value: "b"},
+		{Key: "service.name", Value: "telegraf"},
+	}, b.Resource.Attributes)
+	assert.Equal(t, []*otlp.Metric{
+		{
+			Name: "disk",
+			Gauge: &otlp.Gauge{DataPoints: []*otlp.NumberDataPoint{{
+				Attributes:   []*otlp.KeyValue{},
+				TimeUnixNano: nanos,
+				Value:        1.5,
+			}}},
+		},
+	}, b.ScopeMetrics[0].Metrics)
+}
+
+func TestConvertMergesDataPoints(t *testing.T) {
+	m1, _ := metric.New("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 98.5},
+		time.Unix(0, 1))
+	m2, _ := metric.New("cpu",
+		map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": 97.5},
+		time.Unix(0, 1))
+
+	o := newTestOpenTelemetry("grpc", "")
+	req := o.convert([]telegraf.Metric{m1, m2})
+	require.Len(t, req.ResourceMetrics, 1)
+	assert.Equal(t, []*otlp.KeyValue{}, req.ResourceMetrics[0].Resource.Attributes)
+
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 1)
+	assert.Len(t, metrics[0].Gauge.DataPoints, 2)
+}
+
+func TestWriteGRPC(t *testing.T) {
+	collector := &testCollector{}
+	url, stop := startGRPCServer(t, collector.serveGRPC)
+	defer stop()
+
+	o := newTestOpenTelemetry("grpc", url)
+	require.NoError(t, o.Connect())
+	require.NoError(t, o.Write(testMetrics()))
+
+	require.Len(t, collector.requests, 1)
+	headers := collector.headers[0]
+	assert.Equal(t, "application/grpc", headers.Get("Content-Type"))
+	assert.Equal(t, "gzip", headers.Get("Grpc-Encoding"))
+	assert.Equal(t, "5000m", headers.Get("Grpc-Timeout"))
+	assert.Equal(t, "telegraf", headers.Get("X-Scope-OrgID"))
+
+	rm := collector.requests[0].ResourceMetrics
+	require.Len(t, rm, 1)
+	assert.Equal(t, []*otlp.KeyValue{{Key: "host", Value: "localhost"}}, rm[0].Resource.Attributes)
+	assert.Equal(t, "cpu_usage_idle", rm[0].ScopeMetrics[0].Metrics[0].Name)
+}
+
+func TestWriteHTTP(t *testing.T) {
+	collector := &testCollector{}
+	ts := httptest.NewServer(http.HandlerFunc(collector.serveHTTP))
+	defer ts.Close()
+
+	o := newTestOpenTelemetry("http", ts.URL+otlp.HTTPMetricsPath)
+	require.NoError(t, o.Connect())
+	require.NoError(t, o.Write(testMetrics()))
+
+	require.Len(t, collector.requests, 1)
+	headers := collector.headers[0]
+	assert.Equal(t, "application/x-protobuf", headers.Get("Content-Type"))
+	assert.Equal(t, "gzip", headers.Get("Content-Encoding"))
+	assert.Equal(t, "telegraf", headers.Get("X-Scope-OrgID"))
+
+	rm := collector.requests[0].ResourceMetrics
+	require.Len(t, rm, 1)
+	assert.Equal(t, "cpu_usage_idle", rm[0].ScopeMetrics[0].Metrics[0].Name)
+}
+
+func TestWriteRetry(t *testing.T) {
+	collector := &testCollector{
+		errors: []int{int(otlp.Unavailable), int(otlp.Unavailable)},
+	}
+	url, stop := startGRPCServer(t, collector.serveGRPC)
+	defer stop()
+
+	// the metrics are kept in the buffer and sent again on the next flush.
+	o := newTestOpenTelemetry("grpc", url)
+	require.NoError(t, o.Connect())
+	assert.Error(t, o.Write(testMetrics()))
+	assert.Error(t, o.Write(testMetrics()))
+	assert.NoError(t, o.Write(testMetrics()))
+	assert.Len(t, collector.requests, 3)
+}
+
+func TestWriteRetryAfter(t *testing.T) {
+	collector := &testCollector{errors: []int{http.StatusTooManyRequests}}
+	ts := httptest.NewServer(http.HandlerFunc(collector.serveHTTP))
+	defer ts.Close()
+
+	o := newTestOpenTelemetry("http", ts.URL+otlp.HTTPMetricsPath)
+	require.NoError(t, o.Connect())
+	assert.Error(t, o.Write(testMetrics()))
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), o.retryAfter, time.Second)
+
+	// no request is sent before the delay has passed.
+	assert.Error(t, o.Write(testMetrics()))
+	assert.Len(t, collector.requests, 1)
+
+	o.retryAfter = time.Now()
+	assert.NoError(t, o.Write(testMetrics()))
+	assert.Len(t, collector.requests, 2)
+}
+
+func TestWriteDropsRejectedMetrics(t *testing.T) {
+	collector := &testCollector{errors: []int{int(otlp.InvalidArgument)}}
+	url, stop := startGRPCServer(t, collector.serveGRPC)
+	defer stop()
+
+	o := newTestOpenTelemetry("grpc", url)
+	require.NoError(t, o.Connect())
+	require.NoError(t, o.Write(testMetrics()))
+	assert.Len(t, collector.requests, 1)
+
+	collector = &testCollector{errors: []int{http.StatusBadRequest}}
+	ts := httptest.NewServer(http.HandlerFunc(collector.serveHTTP))
+	defer ts.Close()
+
+	o = newTestOpenTelemetry("http", ts.URL+otlp.HTTPMetricsPath)
+	require.NoError(t, o.Connect())
+	require.NoError(t, o.Write(testMetrics()))
+	assert.Len(t, collector.requests, 1)
+}
+
+func TestWriteConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + l.Addr().String()
+	l.Close()
+
+	o := newTestOpenTelemetry("grpc", url)
+	require.NoError(t, o.Connect())
+	assert.Error(t, o.Write(testMetrics()))
+}
+
+func TestConnectErrors(t *testing.T) {
+	o := newTestOpenTelemetry("thrift", "")
+	assert.Error(t, o.Connect())
+
+	o = newTestOpenTelemetry("grpc", "")
+	o.Compression = "snappy"
+	assert.Error(t, o.Connect())
+
+	o = newTestOpenTelemetry("http", "udp://localhost:4318")
+	assert.Error(t, o.Connect())
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -19,6 +20,9 @@ var invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 type MetricWithExpiration struct {
 	Metric     prometheus.Metric
 	Expiration time.Time
+
+	// seriesID is the HashID of the telegraf metric this metric was made from
+	seriesID uint64
 }
 
 type PrometheusClient struct {
@@ -113,6 +117,13 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 	}
 
 	for _, point := range metrics {
+		// a staleness marker means the series has ended, so stop exposing it
+		// and let prometheus mark it as stale.
+		if metric.IsStaleMarker(point) {
+			p.removeSeries(point.HashID())
+			continue
+		}
+
 		key := point.Name()
 		key = invalidNameCharRE.ReplaceAllString(key, "_")
 
@@ -179,12 +190,22 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 			p.metrics[desc.String()] = &MetricWithExpiration{
 				Metric:     metric,
 				Expiration: time.Now().Add(p.ExpirationInterval.Duration),
+				seriesID:   point.HashID(),
 			}
 		}
 	}
 	return nil
 }
 
+// removeSeries removes all metrics made from the given telegraf series.
+func (p *PrometheusClient) removeSeries(id uint64) {
+	for key, m := range p.metrics {
+		if m.seriesID == id {
+			delete(p.metrics, key)
+		}
+	}
+}
+
 func init() {
 	outputs.Add("prometheus_client", func() telegraf.Output {
 		return &PrometheusClient{
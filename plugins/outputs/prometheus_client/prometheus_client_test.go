@@ -147,6 +147,44 @@ func TestPrometheusExpireOldMetrics(t *testing.T) {
 	assert.Equal(t, 1, len(pClient.metrics))
 }
 
+func TestPrometheusStaleMarker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	pClient, p, err := setupPrometheus()
+	require.NoError(t, err)
+	defer pClient.Stop()
+
+	now := time.Now()
+	tags := map[string]string{"testtag": "testvalue"}
+	pt1, _ := metric.New(
+		"test_point_1",
+		tags,
+		map[string]interface{}{"value": 0.0, "other": 1.0},
+		now)
+	pt2, _ := metric.New(
+		"test_point_2",
+		tags,
+		map[string]interface{}{"value": 1.0},
+		now)
+	require.NoError(t, pClient.Write([]telegraf.Metric{pt1, pt2}))
+	assert.Equal(t, 3, len(pClient.metrics))
+
+	marker, _ := metric.NewStaleMarker("test_point_1", tags, now)
+	require.NoError(t, pClient.Write([]telegraf.Metric{marker}))
+
+	var acc testutil.Accumulator
+
+	require.NoError(t, p.Gather(&acc))
+	acc.AssertContainsFields(t, "test_point_2",
+		map[string]interface{}{"value": 1.0})
+	acc.AssertDoesNotContainMeasurement(t, "test_point_1")
+	acc.AssertDoesNotContainMeasurement(t, "test_point_1_other")
+
+	assert.Equal(t, 1, len(pClient.metrics))
+}
+
 func setupPrometheus() (*PrometheusClient, *prometheus.Prometheus, error) {
 	if pTesting == nil {
 		pTesting = &PrometheusClient{Listen: "localhost:9127"}
@@ -0,0 +1,420 @@
+package prometheus_remote_write
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// Same sanitization as the prometheus_client output, so that both outputs
+// produce the same series.
+var invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var sampleConfig = `
+  ## URL of the remote write endpoint
+  url = "http://localhost:9090/api/v1/write"
+  ## Timeout of each request
+  # timeout = "5s"
+
+  ## Maximum number of samples sent in a single request, a batch of metrics
+  ## is split in several requests if needed.
+  # max_samples_per_send = 1000
+
+  ## Number of times a request is retried after a connection error, a 429
+  ## or a 5xx status code. The wait between retries starts at retry_backoff
+  ## and doubles at each retry.
+  # max_retries = 3
+  # retry_backoff = "100ms"
+
+  ## Time the names of the series written are remembered, to send a stale
+  ## sample for each of them on a staleness marker of their measurement and
+  ## tag set. Set it longer than the series_expiration of the agent, plus its
+  ## interval and flush_interval. 0 disables the tracking of the series, the
+  ## staleness markers then only mark stale the "<measurement>" series.
+  # series_expiration = "0s"
+
+  ## HTTP Basic Auth credentials
+  # username = "telegraf"
+  # password = "metricsmetricsmetricsmetrics"
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Additional HTTP headers
+  # [outputs.prometheus_remote_write.headers]
+  #   X-Scope-OrgID = "telegraf"
+`
+
+type PrometheusRemoteWrite struct {
+	URL               string `toml:"url"`
+	Timeout           internal.Duration
+	MaxSamplesPerSend int
+	MaxRetries        int
+	RetryBackoff      internal.Duration
+	SeriesExpiration  internal.Duration
+	Username          string
+	Password          string
+	Headers           map[string]string
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client *http.Client
+
+	mu sync.Mutex
+	// the series written for each measurement and tag set, marked stale by
+	// the staleness markers of the measurement and tag set.
+	written map[string]*writtenSeries
+	// last time the series not written for series_expiration were forgotten.
+	expired time.Time
+	// the requests of a batch left unsent after a part of it was written,
+	// sent before the next batch so that the batch is not written twice.
+	pending []*prompb.WriteRequest
+}
+
+// writtenSeries holds the names of the series written for a measurement and
+// tag set.
+type writtenSeries struct {
+	names map[string]bool
+	// last time a series was written.
+	last time.Time
+}
+
+// recoverableError is a failed request that can be retried.
+type recoverableError struct {
+	error
+}
+
+func (p *PrometheusRemoteWrite) Connect() error {
+	if p.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+
+	tlsCfg, err := internal.GetTLSConfig(
+		p.SSLCert, p.SSLKey, p.SSLCA, p.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	p.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: p.Timeout.Duration,
+	}
+	return nil
+}
+
+func (p *PrometheusRemoteWrite) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) > 0 {
+		log.Printf("E! Prometheus remote write dropped %d unsent requests\n",
+			len(p.pending))
+		p.pending = nil
+	}
+	return nil
+}
+
+func (p *PrometheusRemoteWrite) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *PrometheusRemoteWrite) Description() string {
+	return "Configuration for the Prometheus remote write client to send metrics to"
+}
+
+// Write sends the metrics in one or more requests. When a request fails
+// after a part of the batch was written, the remaining requests are kept and
+// sent before the next batch, instead of failing the whole batch.
+func (p *PrometheusRemoteWrite) Write(metrics []telegraf.Metric) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.pending) > 0 {
+		if err := p.send(p.pending[0]); err != nil {
+			return err
+		}
+		p.pending = p.pending[1:]
+	}
+
+	requests := p.makeRequests(p.makeTimeSeries(metrics))
+	for i, req := range requests {
+		if err := p.send(req); err != nil {
+			if i == 0 {
+				return err
+			}
+			log.Printf("W! Prometheus remote write failed, %d requests left "+
+				"to send before the next batch: %s\n", len(requests)-i, err)
+			p.pending = requests[i:]
+			return nil
+		}
+	}
+	return nil
+}
+
+// makeRequests splits the series into requests of at most
+// max_samples_per_send samples, a series being never split.
+func (p *PrometheusRemoteWrite) makeRequests(series []*prompb.TimeSeries) []*prompb.WriteRequest {
+	var requests []*prompb.WriteRequest
+	for len(series) > 0 {
+		var batch []*prompb.TimeSeries
+		samples := 0
+		for len(series) > 0 {
+			n := len(series[0].Samples)
+			if len(batch) > 0 && p.MaxSamplesPerSend > 0 &&
+				samples+n > p.MaxSamplesPerSend {
+				break
+			}
+			batch = append(batch, series[0])
+			samples += n
+			series = series[1:]
+		}
+		requests = append(requests, &prompb.WriteRequest{Timeseries: batch})
+	}
+	return requests
+}
+
+// send sends the request, retrying it on recoverable errors.
+func (p *PrometheusRemoteWrite) send(req *prompb.WriteRequest) error {
+	body, err := prompb.Encode(req)
+	if err != nil {
+		return err
+	}
+
+	backoff := p.RetryBackoff.Duration
+	for attempt := 0; ; attempt++ {
+		err = p.post(body)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(recoverableError); !ok {
+			// The request can not succeed later on, so drop the samples
+			// instead of retrying them forever.
+			log.Printf("E! Prometheus remote write dropped %d series: %s\n",
+				len(req.Timeseries), err)
+			return nil
+		}
+		if attempt >= p.MaxRetries {
+			return err
+		}
+
+		log.Printf("D! Prometheus remote write failed, retrying in %s: %s\n",
+			backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (p *PrometheusRemoteWrite) post(body []byte) error {
+	req, err := http.NewRequest("POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", prompb.ContentType)
+	req.Header.Set("Content-Encoding", prompb.ContentEncoding)
+	req.Header.Set(prompb.VersionHeader, prompb.Version)
+	req.Header.Set("User-Agent", "Telegraf")
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	if p.Username != "" || p.Password != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("server returned HTTP status %s: %s",
+		resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// makeTimeSeries converts the numeric fields of the metrics into Prometheus
+// time series, named and labeled like the prometheus_client output does.
+// Staleness markers are converted into a stale sample for each series written
+// for the measurement and tag set, or for the series of the "value" field if
+// none was. Samples of the same series are grouped into a single time series.
+func (p *PrometheusRemoteWrite) makeTimeSeries(metrics []telegraf.Metric) []*prompb.TimeSeries {
+	now := time.Now()
+	p.expireWritten(now)
+
+	var series []*prompb.TimeSeries
+	index := make(map[string]*prompb.TimeSeries)
+	add := func(mname string, labels []*prompb.Label, sample *prompb.Sample) {
+		seriesLabels := make([]*prompb.Label, 0, len(labels)+1)
+		seriesLabels = append(seriesLabels,
+			&prompb.Label{Name: prompb.MetricNameLabel, Value: mname})
+		seriesLabels = append(seriesLabels, labels...)
+		// labels must be sorted by name
+		sort.Sort(byName(seriesLabels))
+
+		id := seriesKey(seriesLabels)
+		s, ok := index[id]
+		if !ok {
+			s = &prompb.TimeSeries{Labels: seriesLabels}
+			index[id] = s
+			series = append(series, s)
+		}
+		s.Samples = append(s.Samples, sample)
+	}
+
+	for _, point := range metrics {
+		key := invalidNameCharRE.ReplaceAllString(point.Name(), "_")
+
+		// convert tags into prometheus labels
+		var labels []*prompb.Label
+		for k, v := range point.Tags() {
+			k = invalidNameCharRE.ReplaceAllString(k, "_")
+			if len(k) == 0 {
+				continue
+			}
+			labels = append(labels, &prompb.Label{Name: k, Value: v})
+		}
+
+		sort.Sort(byName(labels))
+		written := key + "\x00" + seriesKey(labels)
+
+		ts := point.Time().UnixNano() / int64(time.Millisecond)
+		if metric.IsStaleMarker(point) {
+			names := map[string]bool{key: true}
+			if w, ok := p.written[written]; ok {
+				names = w.names
+			}
+			for mname := range names {
+				add(mname, labels, &prompb.Sample{
+					Value:     math.Float64frombits(prompb.StaleNaN),
+					Timestamp: ts,
+				})
+			}
+			delete(p.written, written)
+			continue
+		}
+
+		for n, val := range point.Fields() {
+			var value float64
+			switch val := val.(type) {
+			case int64:
+				value = float64(val)
+			case float64:
+				value = val
+			default:
+				// Ignore string and bool fields.
+				continue
+			}
+
+			// sanitize the measurement name
+			n = invalidNameCharRE.ReplaceAllString(n, "_")
+			var mname string
+			if n == "value" {
+				mname = key
+			} else {
+				mname = fmt.Sprintf("%s_%s", key, n)
+			}
+
+			add(mname, labels, &prompb.Sample{Value: value, Timestamp: ts})
+			if p.SeriesExpiration.Duration > 0 {
+				w, ok := p.written[written]
+				if !ok {
+					w = &writtenSeries{names: make(map[string]bool)}
+					p.written[written] = w
+				}
+				w.names[mname] = true
+				w.last = now
+			}
+		}
+	}
+
+	for _, s := range series {
+		sort.Stable(byTimestamp(s.Samples))
+	}
+	return series
+}
+
+// expireWritten forgets the series which have not been written for
+// series_expiration, at most once per series_expiration.
+func (p *PrometheusRemoteWrite) expireWritten(now time.Time) {
+	if p.written == nil {
+		p.written = make(map[string]*writtenSeries)
+	}
+	expiration := p.SeriesExpiration.Duration
+	if expiration <= 0 || now.Sub(p.expired) < expiration {
+		return
+	}
+	for k, w := range p.written {
+		if now.Sub(w.last) >= expiration {
+			delete(p.written, k)
+		}
+	}
+	p.expired = now
+}
+
+func seriesKey(labels []*prompb.Label) string {
+	var buf bytes.Buffer
+	for _, l := range labels {
+		buf.WriteString(l.Name)
+		buf.WriteByte(0)
+		buf.WriteString(l.Value)
+		buf.WriteByte(0)
+	}
+	return buf.String()
+}
+
+type byName []*prompb.Label
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+type byTimestamp []*prompb.Sample
+
+func (s byTimestamp) Len() int           { return len(s) }
+func (s byTimestamp) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTimestamp) Less(i, j int) bool { return s[i].Timestamp < s[j].Timestamp }
+
+func init() {
+	outputs.Add("prometheus_remote_write", func() telegraf.Output {
+		return &PrometheusRemoteWrite{
+			Timeout:           internal.Duration{Duration: 5 * time.Second},
+			MaxSamplesPerSend: 1000,
+			MaxRetries:        3,
+			RetryBackoff:      internal.Duration{Duration: 100 * time.Millisecond},
+		}
+	})
+}
@@ -0,0 +1,376 @@
+package prometheus_remote_write
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/prompb"
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a stub remote write endpoint answering with the given status
+// codes in turn, then with 204.
+type receiver struct {
+	sync.Mutex
+	*httptest.Server
+
+	statuses []int
+	requests []*http.Request
+	received []*prompb.WriteRequest
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			r.Lock()
+			defer r.Unlock()
+			r.requests = append(r.requests, req)
+
+			if len(r.statuses) > 0 {
+				status := r.statuses[0]
+				r.statuses = r.statuses[1:]
+				w.WriteHeader(status)
+				return
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			wr, err := prompb.Decode(body)
+			require.NoError(t, err)
+			r.received = append(r.received, wr)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	return r
+}
+
+func newPrometheusRemoteWrite(url string) *PrometheusRemoteWrite {
+	return &PrometheusRemoteWrite{
+		URL:          url,
+		Timeout:      internal.Duration{Duration: time.Second},
+		MaxRetries:   2,
+		RetryBackoff: internal.Duration{Duration: time.Millisecond},
+	}
+}
+
+func newMetric(
+	name string,
+	tags map[string]string,
+	fields map[string]interface{},
+	t time.Time,
+) telegraf.Metric {
+	m, _ := metric.New(name, tags, fields, t)
+	return m
+}
+
+// labels returns the labels of the series as a map.
+func labels(ts *prompb.TimeSeries) map[string]string {
+	l := make(map[string]string)
+	for _, label := range ts.Labels {
+		l[label.Name] = label.Value
+	}
+	return l
+}
+
+type bySeriesKey []*prompb.TimeSeries
+
+func (s bySeriesKey) Len() int      { return len(s) }
+func (s bySeriesKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySeriesKey) Less(i, j int) bool {
+	return seriesKey(s[i].Labels) < seriesKey(s[j].Labels)
+}
+
+func TestMakeTimeSeries(t *testing.T) {
+	now := time.Unix(1487067700, 0)
+	metrics := []telegraf.Metric{
+		newMetric("cpu.usage", map[string]string{"host": "a", "cpu-id": "0"},
+			map[string]interface{}{
+				"idle":  float64(98),
+				"value": int64(1),
+				"name":  "skipped",
+				"up":    true,
+			}, now),
+		newMetric("cpu.usage", map[string]string{"host": "a", "cpu-id": "0"},
+			map[string]interface{}{"idle": float64(97)}, now.Add(-time.Second)),
+	}
+
+	series := (&PrometheusRemoteWrite{}).makeTimeSeries(metrics)
+	sort.Sort(bySeriesKey(series))
+	require.Len(t, series, 2)
+
+	assert.Equal(t, map[string]string{
+		"__name__": "cpu_usage",
+		"cpu_id":   "0",
+		"host":     "a",
+	}, labels(series[0]))
+	assert.Equal(t, []*prompb.Sample{
+		{Value: 1, Timestamp: 1487067700000},
+	}, series[0].Samples)
+
+	assert.Equal(t, "cpu_usage_idle", labels(series[1])["__name__"])
+	// samples of a series are in time order
+	assert.Equal(t, []*prompb.Sample{
+		{Value: 97, Timestamp: 1487067699000},
+		{Value: 98, Timestamp: 1487067700000},
+	}, series[1].Samples)
+
+	// labels are sorted by name
+	for _, s := range series {
+		assert.True(t, sort.IsSorted(byName(s.Labels)))
+	}
+}
+
+func newStaleMarker(name string, tags map[string]string, t time.Time) telegraf.Metric {
+	m, _ := metric.NewStaleMarker(name, tags, t)
+	return m
+}
+
+func TestMakeTimeSeriesStaleMarker(t *testing.T) {
+	now := time.Unix(1487067700, 0)
+	tags := map[string]string{"host": "a"}
+	p := &PrometheusRemoteWrite{
+		SeriesExpiration: internal.Duration{Duration: time.Hour},
+	}
+
+	p.makeTimeSeries([]telegraf.Metric{
+		newMetric("cpu", tags,
+			map[string]interface{}{"idle": float64(98), "value": int64(1)}, now),
+	})
+	series := p.makeTimeSeries([]telegraf.Metric{
+		newStaleMarker("cpu", tags, now.Add(time.Second)),
+		// no series was written for this one
+		newStaleMarker("mem", tags, now.Add(time.Second)),
+	})
+	sort.Sort(bySeriesKey(series))
+	require.Len(t, series, 3)
+
+	var names []string
+	for _, s := range series {
+		names = append(names, labels(s)["__name__"])
+		assert.Equal(t, "a", labels(s)["host"])
+		require.Len(t, s.Samples, 1)
+		assert.Equal(t, prompb.StaleNaN, math.Float64bits(s.Samples[0].Value))
+		assert.Equal(t, int64(1487067701000), s.Samples[0].Timestamp)
+	}
+	assert.Equal(t, []string{"cpu", "cpu_idle", "mem"}, names)
+
+	// the series are forgotten once marked stale
+	series = p.makeTimeSeries([]telegraf.Metric{
+		newStaleMarker("cpu", tags, now.Add(2*time.Second)),
+	})
+	require.Len(t, series, 1)
+	assert.Equal(t, "cpu", labels(series[0])["__name__"])
+}
+
+func TestMakeTimeSeriesWithoutTracking(t *testing.T) {
+	now := time.Unix(1487067700, 0)
+	tags := map[string]string{"host": "a"}
+	p := &PrometheusRemoteWrite{}
+
+	p.makeTimeSeries([]telegraf.Metric{
+		newMetric("cpu", tags, map[string]interface{}{"idle": float64(98)}, now),
+	})
+	assert.Empty(t, p.written)
+
+	series := p.makeTimeSeries([]telegraf.Metric{
+		newStaleMarker("cpu", tags, now.Add(time.Second)),
+	})
+	require.Len(t, series, 1)
+	assert.Equal(t, "cpu", labels(series[0])["__name__"])
+}
+
+func TestExpireWrittenSeries(t *testing.T) {
+	now := time.Unix(1487067700, 0)
+	p := &PrometheusRemoteWrite{
+		SeriesExpiration: internal.Duration{Duration: time.Hour},
+	}
+
+	p.makeTimeSeries([]telegraf.Metric{
+		newMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"idle": float64(98)}, now),
+		newMetric("cpu", map[string]string{"host": "b"},
+			map[string]interface{}{"idle": float64(98)}, now),
+	})
+	require.Len(t, p.written, 2)
+	for _, w := range p.written {
+		w.last = w.last.Add(-2 * time.Hour)
+	}
+	p.expired = time.Time{}
+
+	p.makeTimeSeries([]telegraf.Metric{
+		newMetric("cpu", map[string]string{"host": "a"},
+			map[string]interface{}{"idle": float64(98)}, now),
+	})
+	require.Len(t, p.written, 1)
+	for _, w := range p.written {
+		assert.Equal(t, map[string]bool{"cpu_idle": true}, w.names)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := newReceiver(t)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	p.Username = "telegraf"
+	p.Password = "secret"
+	p.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+	require.NoError(t, p.Connect())
+
+	now := time.Unix(1487067700, 0)
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("mem", map[string]string{"host": "a"},
+			map[string]interface{}{"free": int64(1024)}, now),
+	}))
+
+	require.Len(t, r.requests, 1)
+	req := r.requests[0]
+	assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+	assert.Equal(t, "snappy", req.Header.Get("Content-Encoding"))
+	assert.Equal(t, "0.1.0", req.Header.Get("X-Prometheus-Remote-Write-Version"))
+	assert.Equal(t, "tenant", req.Header.Get("X-Scope-OrgID"))
+	user, pass, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "telegraf", user)
+	assert.Equal(t, "secret", pass)
+
+	require.Len(t, r.received, 1)
+	require.Len(t, r.received[0].Timeseries, 1)
+	ts := r.received[0].Timeseries[0]
+	assert.Equal(t, map[string]string{"__name__": "mem_free", "host": "a"},
+		labels(ts))
+	assert.Equal(t, []*prompb.Sample{{Value: 1024, Timestamp: 1487067700000}},
+		ts.Samples)
+}
+
+func TestWriteStaleMarker(t *testing.T) {
+	r := newReceiver(t)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	p.SeriesExpiration = internal.Duration{Duration: time.Hour}
+	require.NoError(t, p.Connect())
+
+	now := time.Unix(1487067700, 0)
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("mem", map[string]string{"host": "a"},
+			map[string]interface{}{"free": int64(1024)}, now),
+		newStaleMarker("mem", map[string]string{"host": "a"}, now.Add(time.Second)),
+	}))
+
+	require.Len(t, r.received, 1)
+	require.Len(t, r.received[0].Timeseries, 1)
+	ts := r.received[0].Timeseries[0]
+	assert.Equal(t, map[string]string{"__name__": "mem_free", "host": "a"},
+		labels(ts))
+	require.Len(t, ts.Samples, 2)
+	assert.Equal(t, float64(1024), ts.Samples[0].Value)
+	assert.Equal(t, prompb.StaleNaN, math.Float64bits(ts.Samples[1].Value))
+	assert.Equal(t, int64(1487067701000), ts.Samples[1].Timestamp)
+}
+
+func TestWriteBatches(t *testing.T) {
+	r := newReceiver(t)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	p.MaxSamplesPerSend = 2
+	require.NoError(t, p.Connect())
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 5; i++ {
+		metrics = append(metrics, newMetric("cpu", nil,
+			map[string]interface{}{"value": float64(i)},
+			time.Unix(int64(i), 0)))
+	}
+	metrics = append(metrics, newMetric("mem", nil,
+		map[string]interface{}{"value": float64(1)}, time.Unix(0, 0)))
+	require.NoError(t, p.Write(metrics))
+
+	// a series is never split between requests
+	var sizes []int
+	for _, wr := range r.received {
+		n := 0
+		for _, ts := range wr.Timeseries {
+			n += len(ts.Samples)
+		}
+		sizes = append(sizes, n)
+	}
+	sort.Ints(sizes)
+	assert.Equal(t, []int{1, 5}, sizes)
+}
+
+func TestWriteRetries(t *testing.T) {
+	r := newReceiver(t, 503, 429)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	require.NoError(t, p.Connect())
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("cpu", nil, map[string]interface{}{"value": 1.0}, time.Now()),
+	}))
+	assert.Len(t, r.requests, 3)
+	assert.Len(t, r.received, 1)
+}
+
+func TestWriteRetriesExhausted(t *testing.T) {
+	r := newReceiver(t, 500, 500, 500)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	require.NoError(t, p.Connect())
+	assert.Error(t, p.Write([]telegraf.Metric{
+		newMetric("cpu", nil, map[string]interface{}{"value": 1.0}, time.Now()),
+	}))
+	assert.Len(t, r.requests, 3)
+}
+
+func TestWritePartialFailure(t *testing.T) {
+	// the first request is written, the second one fails
+	r := newReceiver(t, 204, 500, 500, 500)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	p.MaxSamplesPerSend = 1
+	require.NoError(t, p.Connect())
+	now := time.Now()
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("cpu", nil, map[string]interface{}{"value": 1.0}, now),
+		newMetric("mem", nil, map[string]interface{}{"value": 1.0}, now),
+	}))
+	assert.Len(t, r.requests, 4)
+	require.Len(t, p.pending, 1)
+
+	// the remaining request is sent before the next batch
+	require.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("disk", nil, map[string]interface{}{"value": 1.0}, now),
+	}))
+	assert.Len(t, r.requests, 6)
+	require.Len(t, r.received, 2)
+	assert.Equal(t, "mem", labels(r.received[0].Timeseries[0])["__name__"])
+	assert.Equal(t, "disk", labels(r.received[1].Timeseries[0])["__name__"])
+	assert.Empty(t, p.pending)
+}
+
+func TestWriteBadRequestIsDropped(t *testing.T) {
+	r := newReceiver(t, 400)
+	defer r.Close()
+
+	p := newPrometheusRemoteWrite(r.URL)
+	require.NoError(t, p.Connect())
+	assert.NoError(t, p.Write([]telegraf.Metric{
+		newMetric("cpu", nil, map[string]interface{}{"value": 1.0}, time.Now()),
+	}))
+	assert.Len(t, r.requests, 1)
+}
@@ -0,0 +1,231 @@
+package socket_writer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+type SocketWriter struct {
+	Address         string
+	KeepAlivePeriod *internal.Duration `toml:"keep_alive_period"`
+	Timeout         internal.Duration
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	serializer serializers.Serializer
+
+	conn net.Conn
+}
+
+var sampleConfig = `
+  ## URL to connect to
+  # address = "tcp://127.0.0.1:8094"
+  # address = "tcp://example.com:http"
+  # address = "tcp4://127.0.0.1:8094"
+  # address = "tcp6://127.0.0.1:8094"
+  # address = "tcp6://[2001:db8::1]:8094"
+  # address = "udp://127.0.0.1:8094"
+  # address = "udp4://127.0.0.1:8094"
+  # address = "udp6://127.0.0.1:8094"
+  # address = "unix:///tmp/telegraf.sock"
+  # address = "unixgram:///tmp/telegraf.sock"
+
+  ## Timeout for establishing the connection and for each write.
+  ## 0 means no timeout.
+  # timeout = "5s"
+
+  ## Period between keep alive probes.
+  ## Only applies to TCP sockets.
+  ## 0 disables keep alive probes.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## Optional SSL Config, only applies to TCP sockets.
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format to generate.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  # data_format = "influx"
+`
+
+func (sw *SocketWriter) Description() string {
+	return "Generic socket writer capable of handling multiple socket types."
+}
+
+func (sw *SocketWriter) SampleConfig() string {
+	return sampleConfig
+}
+
+func (sw *SocketWriter) SetSerializer(s serializers.Serializer) {
+	sw.serializer = s
+}
+
+func (sw *SocketWriter) Connect() error {
+	spl := strings.SplitN(sw.Address, "://", 2)
+	if len(spl) != 2 {
+		return fmt.Errorf("invalid address: %s", sw.Address)
+	}
+	network, address := spl[0], spl[1]
+
+	tlsCfg, err := sw.tlsConfig(network, address)
+	if err != nil {
+		return err
+	}
+
+	var c net.Conn
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		c, err = net.DialTimeout(network, address, sw.Timeout.Duration)
+		if err != nil {
+			return err
+		}
+		if err := sw.setKeepAlive(c.(*net.TCPConn)); err != nil {
+			log.Printf("W! unable to configure keep alive (%s): %s\n",
+				sw.Address, err)
+		}
+		if tlsCfg != nil {
+			if c, err = sw.startTLS(c, tlsCfg); err != nil {
+				return err
+			}
+		}
+	case "udp", "udp4", "udp6", "unix", "unixgram":
+		if tlsCfg != nil {
+			return fmt.Errorf("ssl is not supported for %s sockets", network)
+		}
+		c, err = net.DialTimeout(network, address, sw.Timeout.Duration)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown network type: %s", network)
+	}
+
+	sw.conn = c
+	return nil
+}
+
+func (sw *SocketWriter) setKeepAlive(c *net.TCPConn) error {
+	if sw.KeepAlivePeriod == nil {
+		return nil
+	}
+	if sw.KeepAlivePeriod.Duration == 0 {
+		return c.SetKeepAlive(false)
+	}
+	if err := c.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return c.SetKeepAlivePeriod(sw.KeepAlivePeriod.Duration)
+}
+
+// tlsConfig returns a new TLS config for a connection to the address, with
+// the server name set to its host, or nil if ssl is not configured.
+func (sw *SocketWriter) tlsConfig(network, address string) (*tls.Config, error) {
+	// GetTLSConfig returns a new config on each call, which is modified below.
+	tlsCfg, err := internal.GetTLSConfig(
+		sw.SSLCert, sw.SSLKey, sw.SSLCA, sw.InsecureSkipVerify)
+	if err != nil || tlsCfg == nil {
+		return nil, err
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ServerName = host
+	}
+	return tlsCfg, nil
+}
+
+// startTLS performs the TLS handshake over an established TCP connection.
+func (sw *SocketWriter) startTLS(c net.Conn, tlsCfg *tls.Config) (net.Conn, error) {
+	tc := tls.Client(c, tlsCfg)
+	if sw.Timeout.Duration > 0 {
+		tc.SetDeadline(time.Now().Add(sw.Timeout.Duration))
+	}
+	if err := tc.Handshake(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	tc.SetDeadline(time.Time{})
+	return tc, nil
+}
+
+// Write writes the given metrics to the destination.
+// If an error is encountered, it is up to the caller to retry the same write
+// again later. Each metric is written separately, so that it is sent in its
+// own datagram for packet-oriented sockets.
+func (sw *SocketWriter) Write(metrics []telegraf.Metric) error {
+	if sw.conn == nil {
+		// previous write failed with permanent error and socket was closed.
+		if err := sw.Connect(); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range metrics {
+		bs, err := sw.serializer.Serialize(m)
+		if err != nil {
+			log.Printf("E! Error serializing metric %s: %s\n", m.Name(), err)
+			continue
+		}
+
+		if sw.Timeout.Duration > 0 {
+			sw.conn.SetWriteDeadline(time.Now().Add(sw.Timeout.Duration))
+		}
+		if _, err := sw.conn.Write(bs); err != nil {
+			// Close the connection so that it is reestablished on the next
+			// write. Timeouts are not considered permanent, but a partial
+			// write may have corrupted the stream so close it anyway.
+			sw.Close()
+			return fmt.Errorf("error writing to %s: %s", sw.Address, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the connection. Noop if already closed.
+func (sw *SocketWriter) Close() error {
+	if sw.conn == nil {
+		return nil
+	}
+	err := sw.conn.Close()
+	sw.conn = nil
+	return err
+}
+
+func newSocketWriter() *SocketWriter {
+	s, _ := serializers.NewInfluxSerializer()
+	return &SocketWriter{
+		Timeout:    internal.Duration{Duration: 5 * time.Second},
+		serializer: s,
+	}
+}
+
+func init() {
+	outputs.Add("socket_writer", func() telegraf.Output { return newSocketWriter() })
+}
@@ -0,0 +1,169 @@
+package socket_writer
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetrics() []telegraf.Metric {
+	m1, _ := metric.New("test", map[string]string{"foo": "bar"},
+		map[string]interface{}{"value": int64(1)}, time.Unix(0, 0))
+	m2, _ := metric.New("test", map[string]string{"foo": "baz"},
+		map[string]interface{}{"value": int64(2)}, time.Unix(0, 0))
+	return []telegraf.Metric{m1, m2}
+}
+
+const expected = "test,foo=bar value=1i 0\ntest,foo=baz value=2i 0\n"
+
+func TestSocketWriter_tcp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + listener.Addr().String()
+	require.NoError(t, sw.Connect())
+
+	lconn, err := listener.Accept()
+	require.NoError(t, err)
+	defer lconn.Close()
+
+	testSocketWriter_stream(t, sw, lconn)
+}
+
+func TestSocketWriter_udp(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "udp://" + listener.LocalAddr().String()
+	require.NoError(t, sw.Connect())
+
+	testSocketWriter_packet(t, sw, listener)
+}
+
+func TestSocketWriter_unix(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "sw.TestSocketWriter_unix.sock")
+
+	listener, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "unix://" + sock
+	require.NoError(t, sw.Connect())
+
+	lconn, err := listener.Accept()
+	require.NoError(t, err)
+	defer lconn.Close()
+
+	testSocketWriter_stream(t, sw, lconn)
+}
+
+func TestSocketWriter_unixgram(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+	sock := filepath.Join(tmpdir, "sw.TestSocketWriter_unixgram.sock")
+
+	listener, err := net.ListenPacket("unixgram", sock)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "unixgram://" + sock
+	require.NoError(t, sw.Connect())
+
+	testSocketWriter_packet(t, sw, listener)
+}
+
+func testSocketWriter_stream(t *testing.T, sw *SocketWriter, lconn net.Conn) {
+	require.NoError(t, sw.Write(testMetrics()))
+
+	scnr := bufio.NewScanner(lconn)
+	require.True(t, scnr.Scan())
+	assert.Equal(t, "test,foo=bar value=1i 0", scnr.Text())
+	require.True(t, scnr.Scan())
+	assert.Equal(t, "test,foo=baz value=2i 0", scnr.Text())
+}
+
+func testSocketWriter_packet(t *testing.T, sw *SocketWriter, lconn net.PacketConn) {
+	require.NoError(t, sw.Write(testMetrics()))
+
+	// each metric is sent in its own datagram
+	buf := make([]byte, 256)
+	var lines string
+	for i := 0; i < 2; i++ {
+		n, _, err := lconn.ReadFrom(buf)
+		require.NoError(t, err)
+		lines += string(buf[:n])
+	}
+	assert.Equal(t, expected, lines)
+}
+
+func TestSocketWriter_Reconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + listener.Addr().String()
+	require.NoError(t, sw.Connect())
+
+	lconn, err := listener.Accept()
+	require.NoError(t, err)
+	lconn.(*net.TCPConn).SetLinger(0)
+	lconn.Close()
+
+	// the first writes fail once the reset is received, and close the socket.
+	for i := 0; i < 10 && sw.conn != nil; i++ {
+		sw.Write(testMetrics())
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Nil(t, sw.conn)
+
+	// the next write reconnects.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := listener.Accept()
+		accepted <- c
+	}()
+	require.NoError(t, sw.Write(testMetrics()))
+	assert.NotNil(t, sw.conn)
+
+	lconn = <-accepted
+	require.NotNil(t, lconn)
+	defer lconn.Close()
+	scnr := bufio.NewScanner(lconn)
+	require.True(t, scnr.Scan())
+	assert.Equal(t, "test,foo=bar value=1i 0", scnr.Text())
+}
+
+func TestSocketWriter_InvalidAddress(t *testing.T) {
+	sw := newSocketWriter()
+
+	sw.Address = "127.0.0.1:8094"
+	assert.Error(t, sw.Connect())
+
+	sw.Address = "sctp://127.0.0.1:8094"
+	assert.Error(t, sw.Connect())
+
+	sw.Address = "udp://127.0.0.1:8094"
+	sw.InsecureSkipVerify = true
+	assert.Error(t, sw.Connect())
+}
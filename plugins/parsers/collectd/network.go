@@ -0,0 +1,266 @@
+package collectd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Part types of the collectd binary network protocol, see
+// https://collectd.org/wiki/index.php/Binary_protocol
+const (
+	partHost           = 0x0000
+	partTime           = 0x0001
+	partPlugin         = 0x0002
+	partPluginInstance = 0x0003
+	partType           = 0x0004
+	partTypeInstance   = 0x0005
+	partValues         = 0x0006
+	partInterval       = 0x0007
+	partTimeHR         = 0x0008
+	partIntervalHR     = 0x0009
+	partMessage        = 0x0100
+	partSeverity       = 0x0101
+	partSignature      = 0x0200
+	partEncryption     = 0x0210
+)
+
+// Data source types of the values.
+const (
+	typeCounter  = 0
+	typeGauge    = 1
+	typeDerive   = 2
+	typeAbsolute = 3
+)
+
+// Security levels of the packets accepted by the parser.
+const (
+	securityNone    = "none"
+	securitySign    = "sign"
+	securityEncrypt = "encrypt"
+)
+
+const (
+	headerLength    = 4
+	signatureLength = 32
+	ivLength        = 16
+)
+
+// valueList holds the values of a single values part, along with the
+// identifier set by the previous parts of the packet.
+type valueList struct {
+	host           string
+	plugin         string
+	pluginInstance string
+	typ            string
+	typeInstance   string
+	time           time.Time
+	values         []interface{}
+}
+
+// decoder decodes the value lists of a packet.
+type decoder struct {
+	securityLevel string
+	// passwords by user name, for signed and encrypted packets.
+	passwords map[string]string
+}
+
+func (d *decoder) decode(buf []byte) ([]valueList, error) {
+	return d.decodeParts(buf, false, false)
+}
+
+// decodeParts decodes the parts of a packet. Values are only accepted if
+// their part is covered by a signature or encrypted, as required by the
+// security level.
+func (d *decoder) decodeParts(
+	buf []byte,
+	signed bool,
+	encrypted bool,
+) ([]valueList, error) {
+	var lists []valueList
+	var state valueList
+
+	for len(buf) > 0 {
+		if len(buf) < headerLength {
+			return nil, fmt.Errorf("truncated part header")
+		}
+		typ := binary.BigEndian.Uint16(buf[0:2])
+		length := int(binary.BigEndian.Uint16(buf[2:4]))
+		if length < headerLength || length > len(buf) {
+			return nil, fmt.Errorf("invalid length %d for part type %#04x", length, typ)
+		}
+		payload := buf[headerLength:length]
+		rest := buf[length:]
+
+		var err error
+		switch typ {
+		case partHost:
+			state.host, err = decodeString(payload)
+		case partPlugin:
+			state.plugin, err = decodeString(payload)
+		case partPluginInstance:
+			state.pluginInstance, err = decodeString(payload)
+		case partType:
+			state.typ, err = decodeString(payload)
+		case partTypeInstance:
+			state.typeInstance, err = decodeString(payload)
+		case partTime:
+			var n uint64
+			n, err = decodeNumber(payload)
+			state.time = time.Unix(int64(n), 0)
+		case partTimeHR:
+			var n uint64
+			n, err = decodeNumber(payload)
+			state.time = hrTime(n)
+		case partInterval, partIntervalHR, partMessage, partSeverity:
+			// not used
+		case partValues:
+			if d.securityLevel == securitySign && !signed && !encrypted ||
+				d.securityLevel == securityEncrypt && !encrypted {
+				return nil, fmt.Errorf("values not secured as required by "+
+					"security level %s", d.securityLevel)
+			}
+			list := state
+			list.values, err = decodeValues(payload)
+			if err == nil {
+				lists = append(lists, list)
+			}
+		case partSignature:
+			if d.securityLevel == securityNone {
+				// the signature covers the rest of the packet, which is
+				// accepted without checking it.
+				break
+			}
+			err = d.verify(payload, rest)
+			signed = err == nil
+		case partEncryption:
+			var plain []byte
+			plain, err = d.decrypt(payload)
+			if err == nil {
+				var decrypted []valueList
+				decrypted, err = d.decodeParts(plain, signed, true)
+				lists = append(lists, decrypted...)
+			}
+		default:
+			// unknown parts are skipped, as collectd does.
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = rest
+	}
+	return lists, nil
+}
+
+// verify checks the HMAC-SHA256 signature of the data following the
+// signature part.
+func (d *decoder) verify(payload []byte, data []byte) error {
+	if len(payload) <= signatureLength {
+		return fmt.Errorf("invalid signature part")
+	}
+	signature := payload[:signatureLength]
+	user := payload[signatureLength:]
+
+	password, ok := d.passwords[string(user)]
+	if !ok {
+		return fmt.Errorf("unknown user %q in signature", user)
+	}
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write(user)
+	mac.Write(data)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("invalid signature for user %q", user)
+	}
+	return nil
+}
+
+// decrypt decrypts the payload of an encryption part, which is made of the
+// user name, the initialization vector, and the SHA-1 checksum of the data
+// followed by the data, encrypted with AES-256 in OFB mode.
+func (d *decoder) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 2 {
+		return nil, fmt.Errorf("invalid encryption part")
+	}
+	userLength := int(binary.BigEndian.Uint16(payload[0:2]))
+	if len(payload) < 2+userLength+ivLength+sha1.Size {
+		return nil, fmt.Errorf("invalid encryption part")
+	}
+	user := string(payload[2 : 2+userLength])
+	iv := payload[2+userLength : 2+userLength+ivLength]
+	encrypted := payload[2+userLength+ivLength:]
+
+	password, ok := d.passwords[user]
+	if !ok {
+		return nil, fmt.Errorf("unknown user %q in encrypted part", user)
+	}
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewOFB(block, iv).XORKeyStream(plain, encrypted)
+
+	checksum := sha1.Sum(plain[sha1.Size:])
+	if !bytes.Equal(checksum[:], plain[:sha1.Size]) {
+		return nil, fmt.Errorf("invalid checksum in encrypted part for user %q", user)
+	}
+	return plain[sha1.Size:], nil
+}
+
+func decodeString(payload []byte) (string, error) {
+	if len(payload) == 0 || payload[len(payload)-1] != 0 {
+		return "", fmt.Errorf("string part is not null terminated")
+	}
+	return string(payload[:len(payload)-1]), nil
+}
+
+func decodeNumber(payload []byte) (uint64, error) {
+	if len(payload) != 8 {
+		return 0, fmt.Errorf("invalid numeric part length %d", len(payload))
+	}
+	return binary.BigEndian.Uint64(payload), nil
+}
+
+func decodeValues(payload []byte) ([]interface{}, error) {
+	if len(payload) < 2 {
+		return nil, fmt.Errorf("invalid values part")
+	}
+	n := int(binary.BigEndian.Uint16(payload[0:2]))
+	if len(payload) != 2+n*9 {
+		return nil, fmt.Errorf("invalid values part length for %d values", n)
+	}
+	types := payload[2 : 2+n]
+	data := payload[2+n:]
+
+	values := make([]interface{}, n)
+	for i, typ := range types {
+		v := data[i*8 : (i+1)*8]
+		switch typ {
+		case typeCounter, typeAbsolute:
+			values[i] = binary.BigEndian.Uint64(v)
+		case typeGauge:
+			// gauges are the only values in little endian.
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		case typeDerive:
+			values[i] = int64(binary.BigEndian.Uint64(v))
+		default:
+			return nil, fmt.Errorf("unknown data source type %d", typ)
+		}
+	}
+	return values, nil
+}
+
+// hrTime converts a high resolution time, in units of 2^-30 seconds.
+func hrTime(n uint64) time.Time {
+	sec := n >> 30
+	nsec := ((n & (1<<30 - 1)) * uint64(time.Second)) >> 30
+	return time.Unix(int64(sec), int64(nsec))
+}
@@ -0,0 +1,244 @@
+package collectd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const (
+	// split creates a metric per value, named after the plugin and the data
+	// source.
+	split = "split"
+	// join creates a metric per value list, with a field per data source.
+	join = "join"
+)
+
+// CollectdParser decodes packets of the collectd binary network protocol.
+type CollectdParser struct {
+	// AuthFile holds the passwords of the users allowed to send signed and
+	// encrypted packets, one "user: password" per line.
+	AuthFile string
+	// SecurityLevel is the minimum security of the accepted packets: none,
+	// sign or encrypt.
+	SecurityLevel string
+	// TypesDB are the types.db files naming the data sources of each type.
+	TypesDB []string
+	// ParseMultiValue is either split or join.
+	ParseMultiValue string
+	DefaultTags     map[string]string
+
+	decoder *decoder
+	// data source names by type.
+	types map[string][]string
+}
+
+func (p *CollectdParser) Compile() error {
+	switch p.SecurityLevel {
+	case "":
+		p.SecurityLevel = securityNone
+	case securityNone, securitySign, securityEncrypt:
+	default:
+		return fmt.Errorf("invalid collectd security level %q", p.SecurityLevel)
+	}
+
+	switch p.ParseMultiValue {
+	case "":
+		p.ParseMultiValue = split
+	case split, join:
+	default:
+		return fmt.Errorf("invalid collectd parse_multivalue %q", p.ParseMultiValue)
+	}
+
+	p.decoder = &decoder{
+		securityLevel: p.SecurityLevel,
+		passwords:     make(map[string]string),
+	}
+	if p.AuthFile != "" {
+		if err := readAuthFile(p.AuthFile, p.decoder.passwords); err != nil {
+			return err
+		}
+	} else if p.SecurityLevel != securityNone {
+		return fmt.Errorf("collectd security level %s requires an auth file",
+			p.SecurityLevel)
+	}
+
+	p.types = make(map[string][]string)
+	for _, path := range p.TypesDB {
+		if err := readTypesDB(path, p.types); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *CollectdParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	lists, err := p.decoder.decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse collectd packet, %s", err)
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(lists))
+	for _, list := range lists {
+		metrics, err = p.appendMetrics(metrics, list)
+		if err != nil {
+			log.Printf("E! collectd: %s\n", err)
+		}
+	}
+	return metrics, nil
+}
+
+func (p *CollectdParser) ParseLine(line string) (telegraf.Metric, error) {
+	return nil, fmt.Errorf("ParseLine not supported by the collectd binary format")
+}
+
+func (p *CollectdParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *CollectdParser) appendMetrics(
+	metrics []telegraf.Metric,
+	list valueList,
+) ([]telegraf.Metric, error) {
+	names, err := p.sourceNames(list)
+	if err != nil {
+		return metrics, err
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	if list.host != "" {
+		tags["host"] = list.host
+	}
+	if list.pluginInstance != "" {
+		tags["instance"] = list.pluginInstance
+	}
+	if list.typ != "" {
+		tags["type"] = list.typ
+	}
+	if list.typeInstance != "" {
+		tags["type_instance"] = list.typeInstance
+	}
+
+	if p.ParseMultiValue == join {
+		fields := make(map[string]interface{}, len(list.values))
+		for i, v := range list.values {
+			fields[names[i]] = v
+		}
+		m, err := metric.New(list.plugin, tags, fields, list.time)
+		if err != nil {
+			return metrics, err
+		}
+		return append(metrics, m), nil
+	}
+
+	for i, v := range list.values {
+		fields := map[string]interface{}{"value": v}
+		m, err := metric.New(list.plugin+"_"+names[i], copyTags(tags), fields, list.time)
+		if err != nil {
+			return metrics, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// sourceNames returns the names of the data sources of the values, from the
+// types.db files if the type is known. Otherwise a single value is named
+// "value", and multiple values are named after their index.
+func (p *CollectdParser) sourceNames(list valueList) ([]string, error) {
+	if names, ok := p.types[list.typ]; ok {
+		if len(names) != len(list.values) {
+			return nil, fmt.Errorf("type %s has %d data sources, got %d values",
+				list.typ, len(names), len(list.values))
+		}
+		return names, nil
+	}
+
+	if len(list.values) == 1 {
+		return []string{"value"}, nil
+	}
+	names := make([]string, len(list.values))
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	return names, nil
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
+
+// readTypesDB reads the data source names of each type from a types.db file,
+// where each line is made of the name of a type followed by its data sources
+// as "name:type:min:max", ie:
+//
+//   if_octets  rx:DERIVE:0:U, tx:DERIVE:0:U
+//
+func readTypesDB(path string, types map[string][]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(strings.Replace(line, ",", " ", -1))
+		if len(fields) < 2 {
+			return fmt.Errorf("invalid line in types.db %s: %s", path, line)
+		}
+		var names []string
+		for _, source := range fields[1:] {
+			spec := strings.Split(source, ":")
+			if len(spec) != 4 {
+				return fmt.Errorf("invalid data source in types.db %s: %s", path, source)
+			}
+			names = append(names, spec[0])
+		}
+		types[fields[0]] = names
+	}
+	return scanner.Err()
+}
+
+// readAuthFile reads the passwords of the users from an auth file, where each
+// line is made of a user name and its password, separated by a colon.
+func readAuthFile(path string, passwords map[string]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid line in auth file %s", path)
+		}
+		passwords[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return scanner.Err()
+}
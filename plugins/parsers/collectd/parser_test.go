@@ -0,0 +1,251 @@
+package collectd
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func part(typ uint16, payload []byte) []byte {
+	buf := make([]byte, headerLength, headerLength+len(payload))
+	binary.BigEndian.PutUint16(buf[0:2], typ)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(headerLength+len(payload)))
+	return append(buf, payload...)
+}
+
+func stringPart(typ uint16, s string) []byte {
+	return part(typ, append([]byte(s), 0))
+}
+
+func numberPart(typ uint16, n uint64) []byte {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, n)
+	return part(typ, payload)
+}
+
+func valuesPart(types []byte, values []uint64) []byte {
+	payload := make([]byte, 2, 2+len(types)*9)
+	binary.BigEndian.PutUint16(payload, uint16(len(types)))
+	payload = append(payload, types...)
+	for i, v := range values {
+		b := make([]byte, 8)
+		if types[i] == typeGauge {
+			binary.LittleEndian.PutUint64(b, v)
+		} else {
+			binary.BigEndian.PutUint64(b, v)
+		}
+		payload = append(payload, b...)
+	}
+	return part(partValues, payload)
+}
+
+// packet returns a packet with a gauge value for the cpu plugin and an
+// if_octets value for the interface plugin.
+func packet() []byte {
+	var buf bytes.Buffer
+	buf.Write(stringPart(partHost, "server01"))
+	buf.Write(numberPart(partTimeHR, 1488369600<<30|1<<29))
+	buf.Write(numberPart(partIntervalHR, 10<<30))
+	buf.Write(stringPart(partPlugin, "cpu"))
+	buf.Write(stringPart(partPluginInstance, "0"))
+	buf.Write(stringPart(partType, "gauge"))
+	buf.Write(stringPart(partTypeInstance, "idle"))
+	buf.Write(valuesPart([]byte{typeGauge}, []uint64{math.Float64bits(91.5)}))
+	buf.Write(stringPart(partPlugin, "interface"))
+	buf.Write(stringPart(partPluginInstance, "eth0"))
+	buf.Write(stringPart(partType, "if_octets"))
+	buf.Write(stringPart(partTypeInstance, ""))
+	buf.Write(valuesPart([]byte{typeDerive, typeDerive}, []uint64{100, 200}))
+	return buf.Bytes()
+}
+
+func sign(data []byte, user, password string) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(user))
+	mac.Write(data)
+	payload := append(mac.Sum(nil), user...)
+	return append(part(partSignature, payload), data...)
+}
+
+func encrypt(data []byte, user, password string) []byte {
+	key := sha256.Sum256([]byte(password))
+	block, _ := aes.NewCipher(key[:])
+	iv := bytes.Repeat([]byte{7}, ivLength)
+
+	checksum := sha1.Sum(data)
+	plain := append(checksum[:], data...)
+	encrypted := make([]byte, len(plain))
+	cipher.NewOFB(block, iv).XORKeyStream(encrypted, plain)
+
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(len(user)))
+	payload = append(payload, user...)
+	payload = append(payload, iv...)
+	payload = append(payload, encrypted...)
+	return part(partEncryption, payload)
+}
+
+func TestParseSplit(t *testing.T) {
+	p := &CollectdParser{
+		TypesDB:     []string{"testdata/types.db"},
+		DefaultTags: map[string]string{"region": "us-east"},
+	}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse(packet())
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+
+	ts := time.Unix(1488369600, 500000000)
+
+	assert.Equal(t, "cpu_value", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"region":        "us-east",
+		"host":          "server01",
+		"instance":      "0",
+		"type":          "gauge",
+		"type_instance": "idle",
+	}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(91.5)}, metrics[0].Fields())
+	assert.Equal(t, ts.UnixNano(), metrics[0].Time().UnixNano())
+
+	assert.Equal(t, "interface_rx", metrics[1].Name())
+	assert.Equal(t, map[string]string{
+		"region":   "us-east",
+		"host":     "server01",
+		"instance": "eth0",
+		"type":     "if_octets",
+	}, metrics[1].Tags())
+	assert.Equal(t, map[string]interface{}{"value": int64(100)}, metrics[1].Fields())
+
+	assert.Equal(t, "interface_tx", metrics[2].Name())
+	assert.Equal(t, map[string]interface{}{"value": int64(200)}, metrics[2].Fields())
+}
+
+func TestParseJoin(t *testing.T) {
+	p := &CollectdParser{
+		TypesDB:         []string{"testdata/types.db"},
+		ParseMultiValue: "join",
+	}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse(packet())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{"value": float64(91.5)}, metrics[0].Fields())
+	assert.Equal(t, "interface", metrics[1].Name())
+	assert.Equal(t, map[string]interface{}{
+		"rx": int64(100),
+		"tx": int64(200),
+	}, metrics[1].Fields())
+}
+
+func TestParseUnknownType(t *testing.T) {
+	p := &CollectdParser{ParseMultiValue: "join"}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse(packet())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, map[string]interface{}{"value": float64(91.5)}, metrics[0].Fields())
+	assert.Equal(t, map[string]interface{}{
+		"0": int64(100),
+		"1": int64(200),
+	}, metrics[1].Fields())
+}
+
+func TestParseSigned(t *testing.T) {
+	p := &CollectdParser{
+		AuthFile:      "testdata/auth_file",
+		SecurityLevel: "sign",
+	}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse(sign(packet(), "alice", "secret"))
+	require.NoError(t, err)
+	assert.Len(t, metrics, 3)
+
+	_, err = p.Parse(sign(packet(), "alice", "wrong"))
+	assert.Error(t, err)
+
+	_, err = p.Parse(sign(packet(), "bob", "secret"))
+	assert.Error(t, err)
+
+	_, err = p.Parse(packet())
+	assert.Error(t, err)
+}
+
+func TestParseEncrypted(t *testing.T) {
+	p := &CollectdParser{
+		AuthFile:      "testdata/auth_file",
+		SecurityLevel: "encrypt",
+	}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse(encrypt(packet(), "alice", "secret"))
+	require.NoError(t, err)
+	assert.Len(t, metrics, 3)
+
+	_, err = p.Parse(encrypt(packet(), "alice", "wrong"))
+	assert.Error(t, err)
+
+	// signed packets are not enough
+	_, err = p.Parse(sign(packet(), "alice", "secret"))
+	assert.Error(t, err)
+}
+
+func TestParseSecurityNone(t *testing.T) {
+	p := &CollectdParser{AuthFile: "testdata/auth_file"}
+	require.NoError(t, p.Compile())
+
+	for _, buf := range [][]byte{
+		packet(),
+		sign(packet(), "alice", "wrong"),
+		encrypt(packet(), "alice", "secret"),
+	} {
+		metrics, err := p.Parse(buf)
+		require.NoError(t, err)
+		assert.Len(t, metrics, 3)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	p := &CollectdParser{}
+	require.NoError(t, p.Compile())
+
+	buf := packet()
+	_, err := p.Parse(buf[:len(buf)-3])
+	assert.Error(t, err)
+
+	_, err = p.Parse(part(partHost, []byte("no null")))
+	assert.Error(t, err)
+
+	_, err = p.ParseLine("cpu value=1")
+	assert.Error(t, err)
+}
+
+func TestCompileErrors(t *testing.T) {
+	p := &CollectdParser{SecurityLevel: "sign"}
+	assert.Error(t, p.Compile())
+
+	p = &CollectdParser{SecurityLevel: "strict"}
+	assert.Error(t, p.Compile())
+
+	p = &CollectdParser{ParseMultiValue: "merge"}
+	assert.Error(t, p.Compile())
+
+	p = &CollectdParser{TypesDB: []string{"testdata/missing.db"}}
+	assert.Error(t, p.Compile())
+}
@@ -0,0 +1,317 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+type CSVParser struct {
+	MetricName string
+	// HeaderRowCount is the number of rows holding the column names, the
+	// names of a column in several header rows are concatenated.
+	HeaderRowCount int
+	// SkipRows is the number of rows to skip before the header.
+	SkipRows  int
+	Delimiter string
+	Comment   string
+	TrimSpace bool
+	// ColumnNames overrides the names read from the header, if any.
+	ColumnNames []string
+	// ColumnTypes are the types of the columns: int, float, bool or string.
+	// Values of columns without a type are parsed as the first of int,
+	// float and bool that succeeds, and kept as strings otherwise.
+	ColumnTypes       []string
+	TagColumns        []string
+	MeasurementColumn string
+	TimestampColumn   string
+	// TimestampFormat is unix, unix_ms, unix_us, unix_ns or a Go time layout.
+	TimestampFormat string
+	DefaultTags     map[string]string
+}
+
+// Compile checks the configuration of the parser.
+func (p *CSVParser) Compile() error {
+	if p.HeaderRowCount == 0 && len(p.ColumnNames) == 0 {
+		return fmt.Errorf("csv_header_row_count or csv_column_names must be set")
+	}
+	if _, err := p.delimiter(); err != nil {
+		return err
+	}
+	if len(p.Comment) > 1 {
+		return fmt.Errorf("csv_comment must be a single character: %q", p.Comment)
+	}
+	for _, t := range p.ColumnTypes {
+		switch t {
+		case "", "int", "float", "bool", "string":
+		default:
+			return fmt.Errorf("invalid csv_column_types %q, must be one of "+
+				"int, float, bool or string", t)
+		}
+	}
+	if p.TimestampColumn != "" && p.TimestampFormat == "" {
+		return fmt.Errorf("csv_timestamp_format must be set with csv_timestamp_column")
+	}
+	return nil
+}
+
+func (p *CSVParser) delimiter() (rune, error) {
+	if p.Delimiter == "" {
+		return ',', nil
+	}
+	r, n := utf8.DecodeRuneInString(p.Delimiter)
+	if n != len(p.Delimiter) || r == '\n' || r == '\r' || r == '"' {
+		return 0, fmt.Errorf("invalid csv_delimiter %q", p.Delimiter)
+	}
+	return r, nil
+}
+
+func (p *CSVParser) newReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma, _ = p.delimiter()
+	if p.Comment != "" {
+		reader.Comment = []rune(p.Comment)[0]
+	}
+	reader.TrimLeadingSpace = p.TrimSpace
+	// the number of fields may vary from a record to another
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// Parse parses a complete CSV document, starting with the rows to skip and
+// the header rows.
+func (p *CSVParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	r := bufio.NewReader(bytes.NewReader(buf))
+	for i := 0; i < p.SkipRows; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	reader := p.newReader(r)
+	var header [][]string
+	for i := 0; i < p.HeaderRowCount; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, record)
+	}
+	columns := p.columns(header)
+
+	var metrics []telegraf.Metric
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		m, err := p.parseRecord(columns, record)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// ParseLine parses a single line of a CSV document with the column names
+// given by csv_column_names. The lines may come from several documents, such
+// as the files read by the tail input, so the rows to skip and the header
+// rows are not tracked: a line holding the column names is taken for a
+// header row and skipped.
+func (p *CSVParser) ParseLine(line string) (telegraf.Metric, error) {
+	if len(p.ColumnNames) == 0 {
+		return nil, fmt.Errorf("csv_column_names must be set to parse single lines")
+	}
+
+	record, err := p.newReader(strings.NewReader(line)).Read()
+	if err == io.EOF {
+		// empty or comment line
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.isHeader(record) {
+		return nil, nil
+	}
+	return p.parseRecord(p.ColumnNames, record)
+}
+
+// isHeader returns true if the record holds the column names.
+func (p *CSVParser) isHeader(record []string) bool {
+	if len(record) != len(p.ColumnNames) {
+		return false
+	}
+	for i, name := range record {
+		if p.TrimSpace {
+			name = strings.TrimSpace(name)
+		}
+		if name != p.ColumnNames[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// columns returns the column names, from the configuration or concatenated
+// from the header rows.
+func (p *CSVParser) columns(header [][]string) []string {
+	if len(p.ColumnNames) > 0 {
+		return p.ColumnNames
+	}
+
+	var columns []string
+	for _, row := range header {
+		for i, name := range row {
+			if p.TrimSpace {
+				name = strings.TrimSpace(name)
+			}
+			if i < len(columns) {
+				columns[i] += name
+			} else {
+				columns = append(columns, name)
+			}
+		}
+	}
+	return columns
+}
+
+func (p *CSVParser) parseRecord(columns []string, record []string) (telegraf.Metric, error) {
+	name := p.MetricName
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	fields := make(map[string]interface{})
+	t := time.Now()
+
+	for i, value := range record {
+		if i >= len(columns) {
+			break
+		}
+		column := columns[i]
+		if p.TrimSpace {
+			value = strings.TrimSpace(value)
+		}
+
+		switch {
+		case column == p.MeasurementColumn:
+			if value != "" {
+				name = value
+			}
+			continue
+		case column == p.TimestampColumn:
+			var err error
+			t, err = parseTimestamp(value, p.TimestampFormat)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %s", column, err)
+			}
+			continue
+		case p.isTag(column):
+			if value != "" {
+				tags[column] = value
+			}
+			continue
+		}
+
+		if value == "" {
+			continue
+		}
+		var typ string
+		if i < len(p.ColumnTypes) {
+			typ = p.ColumnTypes[i]
+		}
+		v, err := parseValue(value, typ)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %s", column, err)
+		}
+		fields[column] = v
+	}
+
+	return metric.New(name, tags, fields, t)
+}
+
+func (p *CSVParser) isTag(column string) bool {
+	for _, tag := range p.TagColumns {
+		if tag == column {
+			return true
+		}
+	}
+	return false
+}
+
+func parseValue(value, typ string) (interface{}, error) {
+	switch typ {
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "string":
+		return value, nil
+	}
+
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return v, nil
+	}
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v, nil
+	}
+	if v, err := strconv.ParseBool(value); err == nil {
+		return v, nil
+	}
+	return value, nil
+}
+
+func parseTimestamp(value, format string) (time.Time, error) {
+	var unit time.Duration
+	switch format {
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	default:
+		return time.Parse(format, value)
+	}
+
+	if unit == time.Second && strings.Contains(value, ".") {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, n*int64(unit)), nil
+}
+
+func (p *CSVParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
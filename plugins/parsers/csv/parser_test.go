@@ -0,0 +1,193 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHeader(t *testing.T) {
+	parser := CSVParser{
+		MetricName:     "csv",
+		HeaderRowCount: 1,
+		TagColumns:     []string{"host"},
+	}
+	require.NoError(t, parser.Compile())
+
+	metrics, err := parser.Parse([]byte("host,usage,ok,name\n" +
+		"server01,42,true,foo\n" +
+		"server02,4.5,false,\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "csv", metrics[0].Name())
+	assert.Equal(t, map[string]string{"host": "server01"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"usage": int64(42),
+		"ok":    true,
+		"name":  "foo",
+	}, metrics[0].Fields())
+
+	assert.Equal(t, map[string]string{"host": "server02"}, metrics[1].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"usage": float64(4.5),
+		"ok":    false,
+	}, metrics[1].Fields())
+}
+
+func TestParseColumnNamesAndTypes(t *testing.T) {
+	parser := CSVParser{
+		MetricName:  "csv",
+		ColumnNames: []string{"a", "b", "c"},
+		ColumnTypes: []string{"float", "string", "int"},
+		DefaultTags: map[string]string{"region": "us-east"},
+	}
+	require.NoError(t, parser.Compile())
+
+	metrics, err := parser.Parse([]byte("1,2,3\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, map[string]string{"region": "us-east"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"a": float64(1),
+		"b": "2",
+		"c": int64(3),
+	}, metrics[0].Fields())
+
+	_, err = parser.Parse([]byte("1,2,x\n"))
+	assert.Error(t, err)
+}
+
+func TestParseMultipleHeaderRows(t *testing.T) {
+	parser := CSVParser{
+		MetricName:     "csv",
+		SkipRows:       1,
+		HeaderRowCount: 2,
+	}
+	require.NoError(t, parser.Compile())
+
+	metrics, err := parser.Parse([]byte("garbage line\n" +
+		"cpu,mem\n" +
+		"_used,_used\n" +
+		"1,2\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"cpu_used": int64(1),
+		"mem_used": int64(2),
+	}, metrics[0].Fields())
+}
+
+func TestParseTimestampAndMeasurement(t *testing.T) {
+	parser := CSVParser{
+		MetricName:        "csv",
+		HeaderRowCount:    1,
+		MeasurementColumn: "name",
+		TimestampColumn:   "time",
+		TimestampFormat:   "2006-01-02T15:04:05Z07:00",
+	}
+	require.NoError(t, parser.Compile())
+
+	metrics, err := parser.Parse([]byte("name,time,value\n" +
+		"cpu,2017-03-01T12:00:00Z,1\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC).UnixNano(),
+		metrics[0].Time().UnixNano())
+	assert.Equal(t, map[string]interface{}{"value": int64(1)}, metrics[0].Fields())
+
+	parser.TimestampFormat = "unix_ms"
+	metrics, err = parser.Parse([]byte("name,time,value\n" +
+		"cpu,1488369600123,1\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600123000000), metrics[0].Time().UnixNano())
+
+	parser.TimestampFormat = "unix"
+	_, err = parser.Parse([]byte("name,time,value\n" +
+		"cpu,notatime,1\n"))
+	assert.Error(t, err)
+}
+
+func TestParseDelimiterCommentTrimSpace(t *testing.T) {
+	parser := CSVParser{
+		MetricName:     "csv",
+		HeaderRowCount: 1,
+		Delimiter:      ";",
+		Comment:        "#",
+		TrimSpace:      true,
+	}
+	require.NoError(t, parser.Compile())
+
+	metrics, err := parser.Parse([]byte(" a ; b\n" +
+		"# a comment\n" +
+		" 1 ;  2\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"a": int64(1),
+		"b": int64(2),
+	}, metrics[0].Fields())
+}
+
+func TestParseLine(t *testing.T) {
+	parser := CSVParser{
+		MetricName:  "csv",
+		ColumnNames: []string{"a", "b"},
+	}
+	require.NoError(t, parser.Compile())
+
+	// the header row is skipped
+	m, err := parser.ParseLine("a,b")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = parser.ParseLine("1,2")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, map[string]interface{}{
+		"a": int64(1),
+		"b": int64(2),
+	}, m.Fields())
+
+	// no state is kept between the lines, which may come from other files
+	m, err = parser.ParseLine("a,b")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = parser.ParseLine("3,4")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, map[string]interface{}{
+		"a": int64(3),
+		"b": int64(4),
+	}, m.Fields())
+}
+
+func TestParseLineWithoutColumnNames(t *testing.T) {
+	parser := CSVParser{
+		MetricName:     "csv",
+		HeaderRowCount: 1,
+	}
+	require.NoError(t, parser.Compile())
+
+	_, err := parser.ParseLine("a,b")
+	assert.Error(t, err)
+}
+
+func TestCompileErrors(t *testing.T) {
+	parser := CSVParser{MetricName: "csv"}
+	assert.Error(t, parser.Compile())
+
+	parser = CSVParser{HeaderRowCount: 1, Delimiter: "::"}
+	assert.Error(t, parser.Compile())
+
+	parser = CSVParser{HeaderRowCount: 1, ColumnTypes: []string{"integer"}}
+	assert.Error(t, parser.Compile())
+
+	parser = CSVParser{HeaderRowCount: 1, TimestampColumn: "time"}
+	assert.Error(t, parser.Compile())
+}
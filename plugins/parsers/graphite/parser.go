@@ -161,16 +161,25 @@ func (p *GraphiteParser) ParseLine(line string) (telegraf.Metric, error) {
 		return nil, fmt.Errorf("received %q which doesn't have required fields", line)
 	}
 
+	// split the tags of Graphite 1.1 tagged series from the name
+	name, taggedTags, err := parseTaggedName(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
 	// decode the name and tags
-	template := p.matcher.Match(fields[0])
-	measurement, tags, field, err := template.Apply(fields[0])
+	template := p.matcher.Match(name)
+	measurement, tags, field, err := template.Apply(name)
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range taggedTags {
+		tags[k] = v
+	}
 
 	// Could not extract measurement, use the raw value
 	if measurement == "" {
-		measurement = fields[0]
+		measurement = name
 	}
 
 	// Parse value.
@@ -220,6 +229,26 @@ func (p *GraphiteParser) ParseLine(line string) (telegraf.Metric, error) {
 	return metric.New(measurement, tags, fieldValues, timestamp)
 }
 
+// parseTaggedName splits a Graphite 1.1 tagged series, ie:
+//   cpu.usage_idle;host=server01;region=us-east
+// into its name and its tags.
+func parseTaggedName(series string) (string, map[string]string, error) {
+	parts := strings.Split(series, ";")
+	if len(parts) == 1 {
+		return series, nil, nil
+	}
+
+	tags := make(map[string]string, len(parts)-1)
+	for _, tag := range parts[1:] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return "", nil, fmt.Errorf("invalid tag %q in series %q", tag, series)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return parts[0], tags, nil
+}
+
 // ApplyTemplate extracts the template fields from the given line and
 // returns the measurement name and tags.
 func (p *GraphiteParser) ApplyTemplate(line string) (string, map[string]string, string, error) {
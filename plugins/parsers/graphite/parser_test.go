@@ -184,6 +184,25 @@ func TestParseLine(t *testing.T) {
 			value:       50.554,
 			time:        testTime,
 		},
+		{
+			test:        "tagged series",
+			input:       `cpu.foo;host=server01;region=us-east 50 ` + strTime,
+			template:    "measurement.foo",
+			measurement: "cpu",
+			tags: map[string]string{
+				"foo":    "foo",
+				"host":   "server01",
+				"region": "us-east",
+			},
+			value: 50,
+			time:  testTime,
+		},
+		{
+			test:     "tagged series with invalid tag",
+			input:    `cpu;host 50 ` + strTime,
+			template: "measurement",
+			err:      `invalid tag "host" in series "cpu;host"`,
+		},
 		{
 			test:     "missing metric",
 			input:    `1419972457825`,
@@ -775,3 +794,20 @@ func errstr(err error) string {
 	}
 	return ""
 }
+
+func TestParseTaggedSeries(t *testing.T) {
+	p, err := NewGraphiteParser("_", []string{"measurement.field*"},
+		map[string]string{"host": "default", "dc": "us-west"})
+	assert.NoError(t, err)
+
+	m, err := p.ParseLine("cpu.usage.idle;host=server01;cpu=cpu0 91.5 1455320660")
+	assert.NoError(t, err)
+	assert.Equal(t, "cpu", m.Name())
+	assert.Equal(t, map[string]string{
+		"host": "server01",
+		"cpu":  "cpu0",
+		"dc":   "us-west",
+	}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"usage_idle": float64(91.5)}, m.Fields())
+	assert.Equal(t, int64(1455320660), m.Time().Unix())
+}
@@ -0,0 +1,76 @@
+package grok
+
+import (
+	"bufio"
+	"bytes"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs/logparser/grok"
+)
+
+// GrokParser parses each line of the data with the grok patterns of the
+// logparser input.
+type GrokParser struct {
+	Patterns           []string
+	CustomPatterns     string
+	CustomPatternFiles []string
+	MetricName         string
+	DefaultTags        map[string]string
+
+	parser *grok.Parser
+	// the grok parser keeps track of the timestamps it has seen.
+	sync.Mutex
+}
+
+func (p *GrokParser) Compile() error {
+	p.parser = &grok.Parser{
+		Patterns:           p.Patterns,
+		CustomPatterns:     p.CustomPatterns,
+		CustomPatternFiles: p.CustomPatternFiles,
+		Measurement:        p.MetricName,
+	}
+	return p.parser.Compile()
+}
+
+// Parse parses each line of the buffer, lines that do not match any pattern
+// are ignored.
+func (p *GrokParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		m, err := p.ParseLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			metrics = append(metrics, m)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// ParseLine returns nil if the line does not match any pattern.
+func (p *GrokParser) ParseLine(line string) (telegraf.Metric, error) {
+	p.Lock()
+	m, err := p.parser.ParseLine(line)
+	p.Unlock()
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	for k, v := range p.DefaultTags {
+		if !m.HasTag(k) {
+			m.AddTag(k, v)
+		}
+	}
+	return m, nil
+}
+
+func (p *GrokParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
@@ -0,0 +1,77 @@
+package grok
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	p := &GrokParser{
+		MetricName:  "access_log",
+		Patterns:    []string{"%{COMMON_LOG_FORMAT}"},
+		DefaultTags: map[string]string{"host": "server01", "verb": "default"},
+	}
+	require.NoError(t, p.Compile())
+
+	metrics, err := p.Parse([]byte(
+		`127.0.0.1 user-identifier frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326` + "\n" +
+			"not a log line\n" +
+			`127.0.0.1 user-identifier frank [10/Oct/2000:13:55:37 -0700] "POST /apache_pb.gif HTTP/1.0" 404 0` + "\n"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "access_log", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"host":      "server01",
+		"verb":      "GET",
+		"resp_code": "200",
+	}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"resp_bytes":   int64(2326),
+		"auth":         "frank",
+		"client_ip":    "127.0.0.1",
+		"http_version": float64(1.0),
+		"ident":        "user-identifier",
+		"request":      "/apache_pb.gif",
+	}, metrics[0].Fields())
+	assert.Equal(t,
+		time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC).UnixNano(),
+		metrics[0].Time().UnixNano())
+
+	assert.Equal(t, "POST", metrics[1].Tags()["verb"])
+	assert.Equal(t, "404", metrics[1].Tags()["resp_code"])
+}
+
+func TestParseLineCustomPatterns(t *testing.T) {
+	p := &GrokParser{
+		MetricName: "custom",
+		Patterns:   []string{"%{TEST_LOG}"},
+		CustomPatterns: `
+			TEST_LOG %{NUMBER:value:float} %{WORD:name:tag} %{NUMBER:ts:ts-epoch}
+		`,
+	}
+	require.NoError(t, p.Compile())
+
+	m, err := p.ParseLine("4.5 foo 1488369600")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "custom", m.Name())
+	assert.Equal(t, map[string]string{"name": "foo"}, m.Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(4.5)}, m.Fields())
+	assert.Equal(t, int64(1488369600), m.Time().Unix())
+
+	m, err = p.ParseLine("no match")
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestCompileError(t *testing.T) {
+	p := &GrokParser{
+		Patterns:           []string{"%{COMMON_LOG_FORMAT}"},
+		CustomPatternFiles: []string{"/non/existent/file"},
+	}
+	assert.Error(t, p.Compile())
+}
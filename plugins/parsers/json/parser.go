@@ -4,31 +4,62 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/metric"
 )
 
 type JSONParser struct {
-	MetricName  string
-	TagKeys     []string
+	MetricName string
+	TagKeys    []string
+	// StringFields are the keys of the string values to keep as fields, they
+	// support glob matching on the flattened key.
+	StringFields []string
+	// Query selects the object or array of objects to parse, see Query for
+	// the path syntax.
+	Query string
+	// NameKey is the key holding the measurement name.
+	NameKey string
+	// TimeKey is the key holding the metric timestamp, in TimeFormat: unix,
+	// unix_ms, unix_us, unix_ns or a Go time layout.
+	TimeKey     string
+	TimeFormat  string
 	DefaultTags map[string]string
-}
 
-func (p *JSONParser) parseArray(buf []byte) ([]telegraf.Metric, error) {
-	metrics := make([]telegraf.Metric, 0)
+	stringFields filter.Filter
+}
 
-	var jsonOut []map[string]interface{}
-	err := json.Unmarshal(buf, &jsonOut)
+// Compile compiles the string field filters.
+func (p *JSONParser) Compile() error {
+	if len(p.StringFields) == 0 {
+		return nil
+	}
+	var err error
+	p.stringFields, err = filter.Compile(p.StringFields)
 	if err != nil {
-		err = fmt.Errorf("unable to parse out as JSON Array, %s", err)
-		return nil, err
+		return fmt.Errorf("unable to compile JSON string fields, %s", err)
 	}
-	for _, item := range jsonOut {
-		metrics, err = p.parseObject(metrics, item)
+	return nil
+}
+
+func (p *JSONParser) parseArray(items []interface{}) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	for _, item := range items {
+		jsonOut, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unable to parse out as JSON Array, "+
+				"expected objects but got %T", item)
+		}
+		var err error
+		metrics, err = p.parseObject(metrics, jsonOut)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return metrics, nil
 }
@@ -46,19 +77,52 @@ func (p *JSONParser) parseObject(metrics []telegraf.Metric, jsonOut map[string]i
 			tags[tag] = v
 		case bool:
 			tags[tag] = strconv.FormatBool(v)
-		case float64:
-			tags[tag] = strconv.FormatFloat(v, 'f', -1, 64)
+		case json.Number:
+			tags[tag] = v.String()
 		}
 		delete(jsonOut, tag)
 	}
 
+	name := p.MetricName
+	if p.NameKey != "" {
+		if v, ok := jsonOut[p.NameKey].(string); ok && v != "" {
+			name = v
+		}
+		delete(jsonOut, p.NameKey)
+	}
+
+	t := time.Now().UTC()
+	if p.TimeKey != "" {
+		v, ok := jsonOut[p.TimeKey]
+		if !ok {
+			return nil, fmt.Errorf("JSON time key %s not found", p.TimeKey)
+		}
+		var err error
+		t, err = parseTime(v, p.TimeFormat)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse JSON time key %s, %s",
+				p.TimeKey, err)
+		}
+		delete(jsonOut, p.TimeKey)
+	}
+
 	f := JSONFlattener{}
-	err := f.FlattenJSON("", jsonOut)
-	if err != nil {
-		return nil, err
+	if p.stringFields == nil {
+		if err := f.FlattenJSON("", jsonOut); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := f.FullFlattenJSON("", jsonOut, true, false); err != nil {
+			return nil, err
+		}
+		for k, v := range f.Fields {
+			if _, ok := v.(string); ok && !p.stringFields.Match(k) {
+				delete(f.Fields, k)
+			}
+		}
 	}
 
-	metric, err := metric.New(p.MetricName, tags, f.Fields, time.Now().UTC())
+	metric, err := metric.New(name, tags, f.Fields, t)
 
 	if err != nil {
 		return nil, err
@@ -67,18 +131,35 @@ func (p *JSONParser) parseObject(metrics []telegraf.Metric, jsonOut map[string]i
 }
 
 func (p *JSONParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	// the numbers are decoded as json.Number, so that integer timestamps are
+	// parsed exactly.
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var jsonOut interface{}
+	if err := dec.Decode(&jsonOut); err != nil {
+		return nil, fmt.Errorf("unable to parse out as JSON, %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unable to parse out as JSON, " +
+			"unexpected data after the top-level value")
+	}
 
-	if !isarray(buf) {
-		metrics := make([]telegraf.Metric, 0)
-		var jsonOut map[string]interface{}
-		err := json.Unmarshal(buf, &jsonOut)
-		if err != nil {
-			err = fmt.Errorf("unable to parse out as JSON, %s", err)
-			return nil, err
+	if p.Query != "" {
+		var ok bool
+		jsonOut, ok = Query(jsonOut, p.Query)
+		if !ok {
+			return nil, fmt.Errorf("JSON query %s returned no result", p.Query)
 		}
-		return p.parseObject(metrics, jsonOut)
 	}
-	return p.parseArray(buf)
+
+	switch v := jsonOut.(type) {
+	case map[string]interface{}:
+		return p.parseObject(make([]telegraf.Metric, 0), v)
+	case []interface{}:
+		return p.parseArray(v)
+	}
+	return nil, fmt.Errorf("unable to parse out as JSON, "+
+		"expected an object or an array of objects but got %T", jsonOut)
 }
 
 func (p *JSONParser) ParseLine(line string) (telegraf.Metric, error) {
@@ -142,6 +223,12 @@ func (f *JSONFlattener) FullFlattenJSON(
 		}
 	case float64:
 		f.Fields[fieldname] = t
+	case json.Number:
+		n, err := t.Float64()
+		if err != nil {
+			return err
+		}
+		f.Fields[fieldname] = n
 	case string:
 		if convertString {
 			f.Fields[fieldname] = v.(string)
@@ -165,12 +252,45 @@ func (f *JSONFlattener) FullFlattenJSON(
 	return nil
 }
 
-func isarray(buf []byte) bool {
-	ia := bytes.IndexByte(buf, '[')
-	ib := bytes.IndexByte(buf, '{')
-	if ia > -1 && ia < ib {
-		return true
-	} else {
-		return false
+func parseTime(v interface{}, format string) (time.Time, error) {
+	var unit time.Duration
+	switch format {
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	case "":
+		return time.Time{}, fmt.Errorf("no time format given")
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("expected a string but got %T", v)
+		}
+		return time.Parse(format, s)
+	}
+
+	var s string
+	switch t := v.(type) {
+	case json.Number:
+		s = t.String()
+	case string:
+		s = t
+	default:
+		return time.Time{}, fmt.Errorf("expected a number but got %T", v)
+	}
+
+	// integers are parsed exactly, as a float64 can not hold nanosecond
+	// timestamps.
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, i*int64(unit)).UTC(), nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, err
 	}
+	return time.Unix(0, int64(n*float64(unit))).UTC(), nil
 }
@@ -1,7 +1,9 @@
 package json
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -418,3 +420,172 @@ func TestParseArrayWithTagKeys(t *testing.T) {
 		"othertag": "baz",
 	}, metrics[1].Tags())
 }
+
+const validJSONNested = `
+{
+  "status": "ok",
+  "data": {
+    "metrics": [
+      {"name": "cpu", "time": 1488369600, "host": "server01", "state": "running", "value": 42},
+      {"name": "mem", "time": 1488369660, "host": "server02", "state": "idle", "value": 7}
+    ]
+  }
+}
+`
+
+func TestParseWithQuery(t *testing.T) {
+	parser := JSONParser{
+		MetricName: "json_test",
+		TagKeys:    []string{"host"},
+		Query:      "data.metrics",
+		NameKey:    "name",
+		TimeKey:    "time",
+		TimeFormat: "unix",
+	}
+	metrics, err := parser.Parse([]byte(validJSONNested))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, map[string]string{"host": "server01"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(42)}, metrics[0].Fields())
+	assert.Equal(t, int64(1488369600), metrics[0].Time().Unix())
+
+	assert.Equal(t, "mem", metrics[1].Name())
+	assert.Equal(t, map[string]string{"host": "server02"}, metrics[1].Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(7)}, metrics[1].Fields())
+	assert.Equal(t, int64(1488369660), metrics[1].Time().Unix())
+
+	// a single element of the array
+	parser.Query = "data.metrics.1"
+	metrics, err = parser.Parse([]byte(validJSONNested))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "mem", metrics[0].Name())
+
+	parser.Query = "data.missing"
+	_, err = parser.Parse([]byte(validJSONNested))
+	assert.Error(t, err)
+
+	// the result is not an object
+	parser.Query = "status"
+	_, err = parser.Parse([]byte(validJSONNested))
+	assert.Error(t, err)
+}
+
+func TestParseWithStringFields(t *testing.T) {
+	parser := JSONParser{
+		MetricName:   "json_test",
+		Query:        "data.metrics.0",
+		StringFields: []string{"sta*"},
+	}
+	assert.NoError(t, parser.Compile())
+	metrics, err := parser.Parse([]byte(validJSONNested))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"state": "running",
+		"time":  float64(1488369600),
+		"value": float64(42),
+	}, metrics[0].Fields())
+}
+
+func TestParseWithTimeLayout(t *testing.T) {
+	parser := JSONParser{
+		MetricName: "json_test",
+		TimeKey:    "time",
+		TimeFormat: "2006-01-02T15:04:05Z07:00",
+	}
+	metrics, err := parser.Parse([]byte(`{"time": "2017-03-01T12:00:00Z", "a": 1}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600), metrics[0].Time().Unix())
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, metrics[0].Fields())
+
+	parser.TimeFormat = "unix_ms"
+	metrics, err = parser.Parse([]byte(`{"time": "1488369600500", "a": 1}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600500000000), metrics[0].Time().UnixNano())
+
+	_, err = parser.Parse([]byte(`{"a": 1}`))
+	assert.Error(t, err)
+
+	parser.TimeFormat = time.RFC3339
+	_, err = parser.Parse([]byte(`{"time": 1488369600, "a": 1}`))
+	assert.Error(t, err)
+}
+
+func TestParseWithIntegerTimestamps(t *testing.T) {
+	parser := JSONParser{
+		MetricName: "json_test",
+		TimeKey:    "time",
+		TimeFormat: "unix_ns",
+	}
+	// more digits than a float64 holds
+	metrics, err := parser.Parse([]byte(`{"time": 1488369600123456789, "a": 1}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600123456789), metrics[0].Time().UnixNano())
+
+	parser.TimeFormat = "unix_us"
+	metrics, err = parser.Parse([]byte(`{"time": 1488369600123457, "a": 1}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600123457000), metrics[0].Time().UnixNano())
+
+	// fractional seconds are still supported
+	parser.TimeFormat = "unix"
+	metrics, err = parser.Parse([]byte(`{"time": 1488369600.5, "a": 1}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, int64(1488369600500000000), metrics[0].Time().UnixNano())
+}
+
+func TestParseTrailingData(t *testing.T) {
+	parser := JSONParser{MetricName: "json_test"}
+	_, err := parser.Parse([]byte(`{"a": 1} {"a": 2}`))
+	assert.Error(t, err)
+	_, err = parser.Parse([]byte(`{"a": 1}]`))
+	assert.Error(t, err)
+
+	metrics, err := parser.Parse([]byte("{\"a\": 1}\n"))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+}
+
+func TestCompileStringFields(t *testing.T) {
+	parser := JSONParser{StringFields: []string{"[a-"}}
+	assert.Error(t, parser.Compile())
+}
+
+func TestQuery(t *testing.T) {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`
+{
+  "a": {"b.c": 1},
+  "list": [{"v": 1}, {"v": 2}, {"w": 3}]
+}`), &doc))
+
+	v, ok := Query(doc, `a.b\.c`)
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), v)
+
+	v, ok = Query(doc, "list.#")
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), v)
+
+	v, ok = Query(doc, "list.#.v")
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, v)
+
+	v, ok = Query(doc, "list.2.w")
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), v)
+
+	_, ok = Query(doc, "list.3")
+	assert.False(t, ok)
+
+	_, ok = Query(doc, "a.x")
+	assert.False(t, ok)
+}
@@ -0,0 +1,78 @@
+package json
+
+import (
+	"strconv"
+)
+
+// Query returns the value found at the given path in a decoded JSON document,
+// and false if there is none.
+//
+// The path syntax is a subset of GJSON's: keys are separated by dots and array
+// elements are selected by their index. "#" gives the length of an array, or,
+// when followed by more of the path, the array of the values found in each of
+// its elements. Dots in keys are escaped with a backslash, ie:
+//
+//   Query(doc, "data.0.value")      // "value" of the first element of "data"
+//   Query(doc, "data.#")            // number of elements in "data"
+//   Query(doc, "data.#.value")      // "value" of each element of "data"
+//   Query(doc, `version\.major`)    // key "version.major"
+//
+func Query(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+	return query(v, splitPath(path))
+}
+
+func query(v interface{}, path []string) (interface{}, bool) {
+	if len(path) == 0 {
+		return v, true
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		child, ok := t[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return query(child, path[1:])
+	case []interface{}:
+		if path[0] == "#" {
+			if len(path) == 1 {
+				return float64(len(t)), true
+			}
+			values := make([]interface{}, 0, len(t))
+			for _, elem := range t {
+				if value, ok := query(elem, path[1:]); ok {
+					values = append(values, value)
+				}
+			}
+			return values, true
+		}
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(t) {
+			return nil, false
+		}
+		return query(t[i], path[1:])
+	}
+	return nil, false
+}
+
+// splitPath splits a path on the dots that are not escaped.
+func splitPath(path string) []string {
+	var parts []string
+	var part []byte
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			part = append(part, path[i])
+		case path[i] == '.':
+			parts = append(parts, string(part))
+			part = part[:0]
+		default:
+			part = append(part, path[i])
+		}
+	}
+	return append(parts, string(part))
+}
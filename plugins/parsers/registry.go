@@ -5,7 +5,10 @@ import (
 
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/parsers/collectd"
+	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/graphite"
+	"github.com/influxdata/telegraf/plugins/parsers/grok"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
@@ -40,7 +43,8 @@ type Parser interface {
 // Config is a struct that covers the data types needed for all parser types,
 // and can be used to instantiate _any_ of the parsers.
 type Config struct {
-	// Dataformat can be one of: json, influx, graphite, value, nagios
+	// Dataformat can be one of: json, influx, graphite, value, nagios, csv, grok,
+	// collectd
 	DataFormat string
 
 	// Separator only applied to Graphite data.
@@ -50,12 +54,42 @@ type Config struct {
 
 	// TagKeys only apply to JSON data
 	TagKeys []string
-	// MetricName applies to JSON & value. This will be the name of the measurement.
+	// JSON configuration, see the json parser for details.
+	JSONStringFields []string
+	JSONQuery        string
+	JSONNameKey      string
+	JSONTimeKey      string
+	JSONTimeFormat   string
+	// MetricName applies to JSON, value & CSV. This will be the name of the measurement.
 	MetricName string
 
 	// DataType only applies to value, this will be the type to parse value to
 	DataType string
 
+	// CSV configuration, see the csv parser for details.
+	CSVHeaderRowCount    int
+	CSVSkipRows          int
+	CSVDelimiter         string
+	CSVComment           string
+	CSVTrimSpace         bool
+	CSVColumnNames       []string
+	CSVColumnTypes       []string
+	CSVTagColumns        []string
+	CSVMeasurementColumn string
+	CSVTimestampColumn   string
+	CSVTimestampFormat   string
+
+	// Grok configuration, see the logparser input for details.
+	GrokPatterns           []string
+	GrokCustomPatterns     string
+	GrokCustomPatternFiles []string
+
+	// Collectd configuration, see the collectd parser for details.
+	CollectdAuthFile        string
+	CollectdSecurityLevel   string
+	CollectdTypesDB         []string
+	CollectdParseMultiValue string
+
 	// DefaultTags are the default tags that will be added to all parsed metrics.
 	DefaultTags map[string]string
 }
@@ -66,8 +100,7 @@ func NewParser(config *Config) (Parser, error) {
 	var parser Parser
 	switch config.DataFormat {
 	case "json":
-		parser, err = NewJSONParser(config.MetricName,
-			config.TagKeys, config.DefaultTags)
+		parser, err = newJSONParser(config)
 	case "value":
 		parser, err = NewValueParser(config.MetricName,
 			config.DataType, config.DefaultTags)
@@ -78,6 +111,16 @@ func NewParser(config *Config) (Parser, error) {
 	case "graphite":
 		parser, err = NewGraphiteParser(config.Separator,
 			config.Templates, config.DefaultTags)
+	case "csv":
+		parser, err = NewCSVParser(config)
+	case "collectd":
+		parser, err = NewCollectdParser(config.CollectdAuthFile,
+			config.CollectdSecurityLevel, config.CollectdTypesDB,
+			config.CollectdParseMultiValue, config.DefaultTags)
+	case "grok":
+		parser, err = NewGrokParser(config.MetricName, config.GrokPatterns,
+			config.GrokCustomPatterns, config.GrokCustomPatternFiles,
+			config.DefaultTags)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -97,6 +140,23 @@ func NewJSONParser(
 	return parser, nil
 }
 
+func newJSONParser(config *Config) (Parser, error) {
+	parser := &json.JSONParser{
+		MetricName:   config.MetricName,
+		TagKeys:      config.TagKeys,
+		StringFields: config.JSONStringFields,
+		Query:        config.JSONQuery,
+		NameKey:      config.JSONNameKey,
+		TimeKey:      config.JSONTimeKey,
+		TimeFormat:   config.JSONTimeFormat,
+		DefaultTags:  config.DefaultTags,
+	}
+	if err := parser.Compile(); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
+
 func NewNagiosParser() (Parser, error) {
 	return &nagios.NagiosParser{}, nil
 }
@@ -124,3 +184,65 @@ func NewValueParser(
 		DefaultTags: defaultTags,
 	}, nil
 }
+
+func NewCSVParser(config *Config) (Parser, error) {
+	parser := &csv.CSVParser{
+		MetricName:        config.MetricName,
+		HeaderRowCount:    config.CSVHeaderRowCount,
+		SkipRows:          config.CSVSkipRows,
+		Delimiter:         config.CSVDelimiter,
+		Comment:           config.CSVComment,
+		TrimSpace:         config.CSVTrimSpace,
+		ColumnNames:       config.CSVColumnNames,
+		ColumnTypes:       config.CSVColumnTypes,
+		TagColumns:        config.CSVTagColumns,
+		MeasurementColumn: config.CSVMeasurementColumn,
+		TimestampColumn:   config.CSVTimestampColumn,
+		TimestampFormat:   config.CSVTimestampFormat,
+		DefaultTags:       config.DefaultTags,
+	}
+	if err := parser.Compile(); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
+
+func NewGrokParser(
+	metricName string,
+	patterns []string,
+	customPatterns string,
+	customPatternFiles []string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	parser := &grok.GrokParser{
+		Patterns:           patterns,
+		CustomPatterns:     customPatterns,
+		CustomPatternFiles: customPatternFiles,
+		MetricName:         metricName,
+		DefaultTags:        defaultTags,
+	}
+	if err := parser.Compile(); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
+
+func NewCollectdParser(
+	authFile string,
+	securityLevel string,
+	typesDB []string,
+	parseMultiValue string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	parser := &collectd.CollectdParser{
+		AuthFile:        authFile,
+		SecurityLevel:   securityLevel,
+		TypesDB:         typesDB,
+		ParseMultiValue: parseMultiValue,
+		DefaultTags:     defaultTags,
+	}
+	if err := parser.Compile(); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
@@ -0,0 +1,269 @@
+package cardinality
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	actionDrop     action = "drop"
+	actionStripTag action = "strip_tag"
+)
+
+// action is what to do with the metrics over the limit, an invalid action is
+// rejected when the configuration is loaded.
+type action string
+
+func (a *action) UnmarshalTOML(b []byte) error {
+	s := string(b)
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = s[1 : len(s)-1]
+	} else if uq, err := strconv.Unquote(s); err == nil {
+		s = uq
+	} else {
+		return fmt.Errorf("invalid action %s, must be a string", b)
+	}
+	if action(s) != actionDrop && action(s) != actionStripTag {
+		return fmt.Errorf("invalid action %q, must be %q or %q",
+			s, actionDrop, actionStripTag)
+	}
+	*a = action(s)
+	return nil
+}
+
+var sampleConfig = `
+  ## Maximum number of distinct series (measurement + tag set) allowed per
+  ## measurement within the window.
+  limit = 1000
+  ## Series that have not been seen for this long no longer count against
+  ## the limit.
+  window = "1h"
+  ## What to do with a metric that would create a new series once the limit
+  ## is reached:
+  ##   "drop"      - drop the metric.
+  ##   "strip_tag" - remove the tag with the most distinct values from the
+  ##                 metric, and drop it if this still creates a new series
+  ##                 over the limit.
+  action = "drop"
+`
+
+type Cardinality struct {
+	Limit  int
+	Window internal.Duration
+	Action action
+
+	initialized  bool
+	measurements map[string]*measurement
+	// last time the series out of the window were expired.
+	expired time.Time
+
+	// now returns the current time, overridden in tests.
+	now func() time.Time
+
+	Measurements  selfstat.Stat
+	SeriesDropped selfstat.Stat
+	TagsStripped  selfstat.Stat
+}
+
+// measurement holds the series seen for a single measurement name.
+type measurement struct {
+	name string
+	// last time each series was seen, keyed by HashID.
+	series map[uint64]time.Time
+	// last time each tag value was seen, by tag key.
+	values map[string]map[string]time.Time
+	// last time the limit was reported in the log.
+	warned time.Time
+
+	// the stats of a measurement are only registered once it reaches the
+	// limit, as the measurement names are not bounded.
+	capped        bool
+	Series        selfstat.Stat
+	SeriesDropped selfstat.Stat
+	TagsStripped  selfstat.Stat
+}
+
+func (c *Cardinality) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *Cardinality) Description() string {
+	return "Limit the number of distinct series per measurement."
+}
+
+func (c *Cardinality) init() {
+	c.initialized = true
+	c.measurements = make(map[string]*measurement)
+
+	tags := map[string]string{}
+	c.Measurements = selfstat.Register("cardinality", "measurements", tags)
+	c.SeriesDropped = selfstat.Register("cardinality", "series_dropped", tags)
+	c.TagsStripped = selfstat.Register("cardinality", "tags_stripped", tags)
+}
+
+func (c *Cardinality) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !c.initialized {
+		c.init()
+	}
+	now := c.now()
+
+	// forget the series out of the window, and the measurements left without
+	// series, so that the memory used is bounded by the active series.
+	if now.Sub(c.expired) >= c.Window.Duration {
+		for name, meas := range c.measurements {
+			meas.expire(now.Add(-c.Window.Duration))
+			if len(meas.series) == 0 {
+				delete(c.measurements, name)
+			}
+		}
+		c.expired = now
+	}
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		name := m.Name()
+		meas, ok := c.measurements[name]
+		if !ok {
+			meas = newMeasurement(name)
+			c.measurements[name] = meas
+		}
+
+		if meas.accept(m, c.Limit, c.Window.Duration, now) {
+			out = append(out, m)
+			continue
+		}
+
+		meas.capLimit()
+		tag := meas.offendingTag(m)
+		if now.Sub(meas.warned) >= c.Window.Duration {
+			log.Printf("W! Series limit of %d reached for measurement %s, "+
+				"most distinct values are in tag %q\n", c.Limit, name, tag)
+			meas.warned = now
+		}
+
+		if c.Action == actionStripTag && tag != "" {
+			m.RemoveTag(tag)
+			c.TagsStripped.Incr(1)
+			meas.TagsStripped.Incr(1)
+			if meas.accept(m, c.Limit, c.Window.Duration, now) {
+				out = append(out, m)
+				continue
+			}
+		}
+		c.SeriesDropped.Incr(1)
+		meas.SeriesDropped.Incr(1)
+	}
+	c.Measurements.Set(int64(len(c.measurements)))
+	return out
+}
+
+func newMeasurement(name string) *measurement {
+	return &measurement{
+		name:   name,
+		series: make(map[uint64]time.Time),
+		values: make(map[string]map[string]time.Time),
+	}
+}
+
+// capLimit registers the stats of a measurement which reached the limit.
+func (meas *measurement) capLimit() {
+	if meas.capped {
+		return
+	}
+	meas.capped = true
+	tags := map[string]string{"measurement": meas.name}
+	meas.Series = selfstat.Register("cardinality", "series", tags)
+	meas.SeriesDropped = selfstat.Register("cardinality", "series_dropped", tags)
+	meas.TagsStripped = selfstat.Register("cardinality", "tags_stripped", tags)
+	meas.Series.Set(int64(len(meas.series)))
+}
+
+// accept records the series of the metric and returns true if it is already
+// known or if there is room for a new series.
+func (meas *measurement) accept(
+	m telegraf.Metric,
+	limit int,
+	window time.Duration,
+	now time.Time,
+) bool {
+	id := m.HashID()
+	if _, ok := meas.series[id]; !ok && len(meas.series) >= limit {
+		// make room by forgetting the series that fell out of the window.
+		meas.expire(now.Add(-window))
+		if len(meas.series) >= limit {
+			return false
+		}
+	}
+
+	meas.series[id] = now
+	for k, v := range m.Tags() {
+		values, ok := meas.values[k]
+		if !ok {
+			values = make(map[string]time.Time)
+			meas.values[k] = values
+		}
+		values[v] = now
+	}
+	if meas.capped {
+		meas.Series.Set(int64(len(meas.series)))
+	}
+	return true
+}
+
+// expire removes all series and tag values last seen before the given time.
+func (meas *measurement) expire(before time.Time) {
+	for id, seen := range meas.series {
+		if seen.Before(before) {
+			delete(meas.series, id)
+		}
+	}
+	for k, values := range meas.values {
+		for v, seen := range values {
+			if seen.Before(before) {
+				delete(values, v)
+			}
+		}
+		if len(values) == 0 {
+			delete(meas.values, k)
+		}
+	}
+	if meas.capped {
+		meas.Series.Set(int64(len(meas.series)))
+	}
+}
+
+// offendingTag returns the tag key of the metric that would have the most
+// distinct values in the measurement if the metric was accepted.
+func (meas *measurement) offendingTag(m telegraf.Metric) string {
+	var tag string
+	var max int
+	for k, v := range m.Tags() {
+		n := len(meas.values[k])
+		if _, ok := meas.values[k][v]; !ok {
+			n++
+		}
+		if n > max || (n == max && k < tag) {
+			tag = k
+			max = n
+		}
+	}
+	return tag
+}
+
+func init() {
+	processors.Add("cardinality", func() telegraf.Processor {
+		return &Cardinality{
+			Limit:  1000,
+			Window: internal.Duration{Duration: time.Hour},
+			Action: actionDrop,
+			now:    time.Now,
+		}
+	})
+}
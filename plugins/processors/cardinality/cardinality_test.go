@@ -0,0 +1,147 @@
+package cardinality
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCardinality(action action, limit int, now *time.Time) *Cardinality {
+	return &Cardinality{
+		Limit:  limit,
+		Window: internal.Duration{Duration: time.Minute},
+		Action: action,
+		now:    func() time.Time { return *now },
+	}
+}
+
+// stat returns the current value of a stat, the stats being registered
+// globally and shared by the tests.
+func stat(field string, tags map[string]string) int64 {
+	return selfstat.Register("cardinality", field, tags).Get()
+}
+
+func newMetric(name string, tags map[string]string) telegraf.Metric {
+	m, _ := metric.New(name, tags, map[string]interface{}{"value": 1.0},
+		time.Now())
+	return m
+}
+
+func TestDropNewSeries(t *testing.T) {
+	now := time.Now()
+	c := newCardinality("drop", 2, &now)
+	dropped := stat("series_dropped", map[string]string{"measurement": "cpu"})
+
+	out := c.Apply(
+		newMetric("cpu", map[string]string{"id": "1"}),
+		newMetric("cpu", map[string]string{"id": "2"}),
+		newMetric("cpu", map[string]string{"id": "3"}),
+		newMetric("mem", map[string]string{"id": "3"}),
+	)
+	require.Len(t, out, 3)
+	assert.Equal(t, "2", out[1].Tags()["id"])
+	assert.Equal(t, "mem", out[2].Name())
+
+	// known series still pass
+	out = c.Apply(newMetric("cpu", map[string]string{"id": "1"}))
+	assert.Len(t, out, 1)
+
+	m := c.measurements["cpu"]
+	assert.Equal(t, int64(2), m.Series.Get())
+	assert.Equal(t, dropped+1, m.SeriesDropped.Get())
+}
+
+func TestWindowExpiresSeries(t *testing.T) {
+	now := time.Now()
+	c := newCardinality("drop", 2, &now)
+
+	c.Apply(newMetric("cpu", map[string]string{"id": "1"}))
+	now = now.Add(30 * time.Second)
+	c.Apply(newMetric("cpu", map[string]string{"id": "2"}))
+
+	// id=1 falls out of the window, making room for id=3
+	now = now.Add(45 * time.Second)
+	out := c.Apply(newMetric("cpu", map[string]string{"id": "3"}))
+	assert.Len(t, out, 1)
+
+	// id=2 is still within the window, so id=4 is dropped
+	out = c.Apply(newMetric("cpu", map[string]string{"id": "4"}))
+	assert.Len(t, out, 0)
+}
+
+func TestStripOffendingTag(t *testing.T) {
+	now := time.Now()
+	c := newCardinality("strip_tag", 3, &now)
+	tags := map[string]string{"measurement": "exec"}
+	stripped := stat("tags_stripped", tags)
+	dropped := stat("series_dropped", tags)
+
+	in := []telegraf.Metric{
+		newMetric("exec", map[string]string{"host": "localhost"}),
+	}
+	for i := 0; i < 3; i++ {
+		in = append(in, newMetric("exec", map[string]string{
+			"host":       "localhost",
+			"request_id": fmt.Sprint(i),
+		}))
+	}
+
+	out := c.Apply(in...)
+	require.Len(t, out, 4)
+	assert.Equal(t,
+		map[string]string{"host": "localhost"},
+		out[3].Tags())
+	assert.Equal(t, stripped+1, c.measurements["exec"].TagsStripped.Get())
+	assert.Equal(t, dropped, c.measurements["exec"].SeriesDropped.Get())
+
+	// stripping the tag still creates a new series, so the metric is dropped
+	out = c.Apply(newMetric("exec", map[string]string{
+		"host":       "remote",
+		"request_id": "3",
+	}))
+	assert.Len(t, out, 0)
+	assert.Equal(t, dropped+1, c.measurements["exec"].SeriesDropped.Get())
+}
+
+func TestExpireMeasurements(t *testing.T) {
+	now := time.Now()
+	c := newCardinality("drop", 2, &now)
+
+	c.Apply(
+		newMetric("cpu", map[string]string{"id": "1"}),
+		newMetric("mem", map[string]string{"id": "1"}),
+	)
+	require.Len(t, c.measurements, 2)
+	// the measurements under the limit have no stats of their own
+	assert.Nil(t, c.measurements["cpu"].Series)
+
+	now = now.Add(30 * time.Second)
+	c.Apply(newMetric("cpu", map[string]string{"id": "1"}))
+
+	// mem has no series left within the window, so it is forgotten
+	now = now.Add(45 * time.Second)
+	c.Apply(newMetric("disk", map[string]string{"id": "1"}))
+	assert.Len(t, c.measurements, 2)
+	assert.Contains(t, c.measurements, "cpu")
+	assert.Contains(t, c.measurements, "disk")
+	assert.Equal(t, int64(2), c.Measurements.Get())
+}
+
+func TestInvalidAction(t *testing.T) {
+	var a action
+	assert.NoError(t, a.UnmarshalTOML([]byte(`"strip_tag"`)))
+	assert.Equal(t, actionStripTag, a)
+	assert.NoError(t, a.UnmarshalTOML([]byte(`'drop'`)))
+	assert.Equal(t, actionDrop, a)
+
+	assert.Error(t, a.UnmarshalTOML([]byte(`"strip"`)))
+	assert.Error(t, a.UnmarshalTOML([]byte(`1`)))
+}
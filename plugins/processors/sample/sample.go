@@ -0,0 +1,147 @@
+package sample
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+var sampleConfig = `
+  ## Keep one in every "rate" metrics.
+  rate = 10
+  ## Keep metrics with this probability between 0 and 1, overrides rate if set.
+  # probability = 0.1
+
+  ## If true, whole series (measurement + tag set) are kept or dropped based on
+  ## a hash of the series, so the same series are consistently kept. If false,
+  ## every metric is sampled independently of its series.
+  by_series = true
+  ## Seed of the series hash, change it to keep a different set of series.
+  # seed = 0
+
+  ## Name of the tag and of the field recording the number of metrics that
+  ## each kept metric stands for (1 / probability). Leave empty to not record
+  ## the sampling rate.
+  # rate_tag = ""
+  rate_field = "sample_rate"
+`
+
+type Sample struct {
+	Rate        int
+	Probability float64
+	BySeries    bool `toml:"by_series"`
+	Seed        uint64
+	RateTag     string `toml:"rate_tag"`
+	RateField   string `toml:"rate_field"`
+
+	initialized bool
+	threshold   float64
+	factor      float64
+	// number of metrics seen since the last kept one, if not sampling by series.
+	count int
+
+	// random returns a number in [0.0,1.0), overridden in tests.
+	random func() float64
+}
+
+func (s *Sample) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Sample) Description() string {
+	return "Keep a sample of the metrics, consistently per series."
+}
+
+func (s *Sample) init() {
+	s.initialized = true
+
+	s.threshold = s.Probability
+	if s.threshold <= 0 {
+		if s.Rate < 1 {
+			log.Printf("E! sample: rate must be 1 or more, got %d, "+
+				"keeping all metrics\n", s.Rate)
+			s.Rate = 1
+		}
+		s.threshold = 1 / float64(s.Rate)
+	}
+	if s.threshold > 1 {
+		log.Printf("E! sample: probability must be between 0 and 1, "+
+			"got %v, keeping all metrics\n", s.Probability)
+		s.threshold = 1
+	}
+	s.factor = 1 / s.threshold
+}
+
+func (s *Sample) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	if !s.initialized {
+		s.init()
+	}
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		if !s.keep(m) {
+			continue
+		}
+		if s.RateTag != "" {
+			m.AddTag(s.RateTag, strconv.FormatFloat(s.factor, 'f', -1, 64))
+		}
+		if s.RateField != "" {
+			m.AddField(s.RateField, s.factor)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// keep returns true if the metric is part of the sample.
+func (s *Sample) keep(m telegraf.Metric) bool {
+	if s.threshold >= 1 {
+		return true
+	}
+
+	if s.BySeries {
+		return unit(mix(m.HashID()^s.Seed)) < s.threshold
+	}
+
+	if s.Probability > 0 {
+		return s.random() < s.threshold
+	}
+
+	// every Rate-th metric
+	s.count++
+	if s.count >= s.Rate {
+		s.count = 0
+		return true
+	}
+	return false
+}
+
+// mix spreads the bits of the series hash so that its value is uniformly
+// distributed, using the splitmix64 finalizer.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// unit maps a hash to [0.0,1.0).
+func unit(x uint64) float64 {
+	return float64(x>>11) / float64(uint64(1)<<53)
+}
+
+func init() {
+	processors.Add("sample", func() telegraf.Processor {
+		return &Sample{
+			Rate:      10,
+			BySeries:  true,
+			RateField: "sample_rate",
+			random:    rand.Float64,
+		}
+	})
+}
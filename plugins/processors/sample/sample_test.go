@@ -0,0 +1,114 @@
+package sample
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMetric(name string, tags map[string]string) telegraf.Metric {
+	m, _ := metric.New(name, tags, map[string]interface{}{"value": 1.0},
+		time.Now())
+	return m
+}
+
+func seriesMetrics(n int) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, n)
+	for i := 0; i < n; i++ {
+		metrics = append(metrics,
+			newMetric("cpu", map[string]string{"id": fmt.Sprint(i)}))
+	}
+	return metrics
+}
+
+func TestSampleBySeriesIsConsistent(t *testing.T) {
+	s := &Sample{Rate: 10, BySeries: true}
+
+	first := s.Apply(seriesMetrics(10000)...)
+	// roughly 1 in 10 series are kept
+	assert.InDelta(t, 1000, len(first), 150)
+
+	kept := make(map[string]bool)
+	for _, m := range first {
+		kept[m.Tags()["id"]] = true
+	}
+
+	second := s.Apply(seriesMetrics(10000)...)
+	require.Len(t, second, len(first))
+	for _, m := range second {
+		assert.True(t, kept[m.Tags()["id"]])
+	}
+}
+
+func TestSampleSeedChangesSeries(t *testing.T) {
+	a := &Sample{Probability: 0.5, BySeries: true}
+	b := &Sample{Probability: 0.5, BySeries: true, Seed: 42}
+
+	outA := a.Apply(seriesMetrics(100)...)
+	outB := b.Apply(seriesMetrics(100)...)
+	assert.NotEqual(t, outA, outB)
+}
+
+func TestSampleEveryNth(t *testing.T) {
+	s := &Sample{Rate: 3}
+
+	var metrics []telegraf.Metric
+	for i := 0; i < 9; i++ {
+		metrics = append(metrics, newMetric("cpu", nil))
+	}
+	out := s.Apply(metrics...)
+	assert.Len(t, out, 3)
+}
+
+func TestSampleProbability(t *testing.T) {
+	values := []float64{0.1, 0.6, 0.2, 0.9}
+	s := &Sample{
+		Probability: 0.25,
+		random: func() float64 {
+			v := values[0]
+			values = values[1:]
+			return v
+		},
+	}
+
+	out := s.Apply(
+		newMetric("a", nil),
+		newMetric("b", nil),
+		newMetric("c", nil),
+		newMetric("d", nil),
+	)
+	require.Len(t, out, 2)
+	assert.Equal(t, "a", out[0].Name())
+	assert.Equal(t, "c", out[1].Name())
+}
+
+func TestSampleRecordsRate(t *testing.T) {
+	s := &Sample{
+		Probability: 1,
+		RateTag:     "sample_rate",
+		RateField:   "sample_rate",
+	}
+
+	out := s.Apply(newMetric("cpu", nil))
+	require.Len(t, out, 1)
+	assert.Equal(t, "1", out[0].Tags()["sample_rate"])
+	assert.Equal(t, 1.0, out[0].Fields()["sample_rate"])
+
+	s = &Sample{Rate: 4, RateField: "sample_rate"}
+	out = s.Apply(seriesMetrics(4)...)
+	require.Len(t, out, 1)
+	assert.Equal(t, 4.0, out[0].Fields()["sample_rate"])
+}
+
+func TestSampleInvalidRateKeepsAll(t *testing.T) {
+	s := &Sample{Rate: 0}
+
+	out := s.Apply(seriesMetrics(5)...)
+	assert.Len(t, out, 5)
+}
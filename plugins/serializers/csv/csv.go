@@ -0,0 +1,279 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/influxdata/telegraf"
+)
+
+// CSVSerializer writes each metric as a CSV row made of the timestamp, the
+// measurement name, the tags and the fields, with tags and fields ordered by
+// key.
+type CSVSerializer struct {
+	// Header writes a header row before the first metric.
+	Header bool
+	// TimestampFormat is unix, unix_ms, unix_us, unix_ns or a Go time layout.
+	TimestampFormat string
+	Delimiter       string
+	// ColumnPrefix prefixes the tag columns with "tag_" and the field columns
+	// with "field_" in the header.
+	ColumnPrefix bool
+
+	// columns of the header written by Serialize
+	columns *columns
+	sync.Mutex
+}
+
+func NewSerializer(
+	header bool,
+	timestampFormat string,
+	delimiter string,
+	columnPrefix bool,
+) (*CSVSerializer, error) {
+	if delimiter != "" {
+		r, n := utf8.DecodeRuneInString(delimiter)
+		if n != len(delimiter) || r == '\n' || r == '\r' || r == '"' {
+			return nil, fmt.Errorf("invalid csv_delimiter %q", delimiter)
+		}
+	}
+	if timestampFormat == "" {
+		timestampFormat = "unix"
+	}
+	return &CSVSerializer{
+		Header:          header,
+		TimestampFormat: timestampFormat,
+		Delimiter:       delimiter,
+		ColumnPrefix:    columnPrefix,
+	}, nil
+}
+
+// Serialize serializes the metric as a CSV row. With a header, the columns
+// are the ones of the first metric and the tags and fields missing from the
+// next metrics are left empty. A metric with other tags or fields starts a new
+// section, with a header row made of the previous columns and its own.
+func (s *CSVSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	cols := s.columns
+	if cols == nil {
+		cols = newColumns([]telegraf.Metric{metric})
+	} else if !cols.contains(metric) {
+		cols = cols.add(metric)
+	}
+
+	var buf bytes.Buffer
+	writer := s.newWriter(&buf)
+	if s.Header && cols != s.columns {
+		if err := writer.Write(cols.header(s.ColumnPrefix)); err != nil {
+			return nil, err
+		}
+		s.columns = cols
+	}
+	if err := writer.Write(cols.row(metric, s.TimestampFormat)); err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SerializeBatch serializes the metrics as a CSV document, starting with a
+// header row if enabled. The columns are the tags and fields of all the
+// metrics, the ones missing from a metric are left empty.
+func (s *CSVSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	cols := newColumns(metrics)
+
+	var buf bytes.Buffer
+	writer := s.newWriter(&buf)
+	if s.Header {
+		if err := writer.Write(cols.header(s.ColumnPrefix)); err != nil {
+			return nil, err
+		}
+	}
+	for _, metric := range metrics {
+		if err := writer.Write(cols.row(metric, s.TimestampFormat)); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HeaderRow returns the header row of the current section, or nil before the
+// first metric or without a header. It is written again at the beginning of
+// the files opened by a rotation.
+func (s *CSVSerializer) HeaderRow() []byte {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.Header || s.columns == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	writer := s.newWriter(&buf)
+	writer.Write(s.columns.header(s.ColumnPrefix))
+	writer.Flush()
+	if writer.Error() != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func (s *CSVSerializer) newWriter(buf *bytes.Buffer) *csv.Writer {
+	writer := csv.NewWriter(buf)
+	if s.Delimiter != "" {
+		writer.Comma, _ = utf8.DecodeRuneInString(s.Delimiter)
+	}
+	return writer
+}
+
+// columns are the tag and field columns of a CSV document, ordered by key.
+type columns struct {
+	tags   []string
+	fields []string
+}
+
+// newColumns returns the columns of the tags and fields of all the metrics.
+func newColumns(metrics []telegraf.Metric) *columns {
+	tags := make(map[string]bool)
+	fields := make(map[string]bool)
+	for _, metric := range metrics {
+		for k := range metric.Tags() {
+			tags[k] = true
+		}
+		for k := range metric.Fields() {
+			fields[k] = true
+		}
+	}
+	return &columns{tags: sortedKeys(tags), fields: sortedKeys(fields)}
+}
+
+// contains returns true if the columns include all the tags and fields of the
+// metric.
+func (c *columns) contains(metric telegraf.Metric) bool {
+	for k := range metric.Tags() {
+		if !containsKey(c.tags, k) {
+			return false
+		}
+	}
+	for k := range metric.Fields() {
+		if !containsKey(c.fields, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// add returns the columns with the tags and fields of the metric added.
+func (c *columns) add(metric telegraf.Metric) *columns {
+	tags := make(map[string]bool)
+	fields := make(map[string]bool)
+	for _, k := range c.tags {
+		tags[k] = true
+	}
+	for _, k := range c.fields {
+		fields[k] = true
+	}
+	for k := range metric.Tags() {
+		tags[k] = true
+	}
+	for k := range metric.Fields() {
+		fields[k] = true
+	}
+	return &columns{tags: sortedKeys(tags), fields: sortedKeys(fields)}
+}
+
+func (c *columns) header(prefix bool) []string {
+	header := []string{"timestamp", "measurement"}
+	for _, k := range c.tags {
+		if prefix {
+			k = "tag_" + k
+		}
+		header = append(header, k)
+	}
+	for _, k := range c.fields {
+		if prefix {
+			k = "field_" + k
+		}
+		header = append(header, k)
+	}
+	return header
+}
+
+func (c *columns) row(metric telegraf.Metric, timestampFormat string) []string {
+	row := []string{formatTimestamp(metric.Time(), timestampFormat), metric.Name()}
+	tags := metric.Tags()
+	for _, k := range c.tags {
+		row = append(row, tags[k])
+	}
+	fields := metric.Fields()
+	for _, k := range c.fields {
+		if v, ok := fields[k]; ok {
+			row = append(row, formatValue(v))
+		} else {
+			row = append(row, "")
+		}
+	}
+	return row
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsKey(keys []string, key string) bool {
+	i := sort.SearchStrings(keys, key)
+	return i < len(keys) && keys[i] == key
+}
+
+func formatTimestamp(t time.Time, format string) string {
+	switch format {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix_ms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "unix_us":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10)
+	case "unix_ns":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.UTC().Format(format)
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,201 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestSerialize(t *testing.T) {
+	now := time.Unix(1488369600, 0)
+	m, err := metric.New("cpu",
+		map[string]string{"host": "server01", "cpu": "cpu0"},
+		map[string]interface{}{
+			"usage_idle": float64(91.5),
+			"count":      int64(3),
+			"ok":         true,
+			"note":       "a, b",
+		},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "", "", false)
+	require.NoError(t, err)
+
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp,measurement,cpu,host,count,note,ok,usage_idle\n"+
+			"1488369600,cpu,cpu0,server01,3,\"a, b\",true,91.5\n",
+		string(buf))
+
+	// the header is only written once
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"1488369600,cpu,cpu0,server01,3,\"a, b\",true,91.5\n",
+		string(buf))
+}
+
+func TestSerializeOptions(t *testing.T) {
+	now := time.Unix(1488369600, 5000000)
+	m, err := metric.New("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"value": int64(1)},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "unix_ms", ";", true)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp;measurement;tag_host;field_value\n"+
+			"1488369600005;cpu;server01;1\n",
+		string(buf))
+
+	s, err = NewSerializer(false, time.RFC3339, "", false)
+	require.NoError(t, err)
+	buf, err = s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t, "2017-03-01T12:00:00Z,cpu,server01,1\n", string(buf))
+}
+
+func TestSerializeHeaderRow(t *testing.T) {
+	now := time.Unix(1488369600, 0)
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"value": int64(1)},
+		now)
+	require.NoError(t, err)
+	m2, err := metric.New("mem",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"used": int64(2)},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "", "", false)
+	require.NoError(t, err)
+	assert.Nil(t, s.HeaderRow())
+
+	buf, err := s.Serialize(m1)
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp,measurement,host,value\n1488369600,cpu,server01,1\n",
+		string(buf))
+	assert.Equal(t, "timestamp,measurement,host,value\n", string(s.HeaderRow()))
+
+	// the header row of the new section
+	buf, err = s.Serialize(m2)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp,measurement,host,used,value\n1488369600,mem,server01,2,\n",
+		string(buf))
+	assert.Equal(t, "timestamp,measurement,host,used,value\n", string(s.HeaderRow()))
+
+	buf, err = s.Serialize(m1)
+	require.NoError(t, err)
+	assert.Equal(t, "1488369600,cpu,server01,,1\n", string(buf))
+}
+
+func TestInvalidDelimiter(t *testing.T) {
+	_, err := NewSerializer(false, "", "\n", false)
+	assert.Error(t, err)
+}
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Unix(1488369600, 0)
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"value": int64(1)},
+		now)
+	require.NoError(t, err)
+	m2, err := metric.New("cpu",
+		map[string]string{"host": "server02"},
+		map[string]interface{}{"value": int64(2)},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "", "", false)
+	require.NoError(t, err)
+
+	// each batch starts with a header
+	for i := 0; i < 2; i++ {
+		buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+		require.NoError(t, err)
+		assert.Equal(t,
+			"timestamp,measurement,host,value\n"+
+				"1488369600,cpu,server01,1\n"+
+				"1488369600,cpu,server02,2\n",
+			string(buf))
+	}
+}
+
+func TestSerializeHeaderColumns(t *testing.T) {
+	now := time.Unix(1488369600, 0)
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"idle": int64(90), "user": int64(5)},
+		now)
+	require.NoError(t, err)
+	m2, err := metric.New("cpu",
+		map[string]string{"host": "server02"},
+		map[string]interface{}{"idle": int64(80)},
+		now)
+	require.NoError(t, err)
+	m3, err := metric.New("cpu",
+		map[string]string{"host": "server03"},
+		map[string]interface{}{"system": int64(1)},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "", "", false)
+	require.NoError(t, err)
+	buf, err := s.Serialize(m1)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp,measurement,host,idle,user\n"+
+			"1488369600,cpu,server01,90,5\n",
+		string(buf))
+
+	// missing fields are left empty
+	buf, err = s.Serialize(m2)
+	require.NoError(t, err)
+	assert.Equal(t, "1488369600,cpu,server02,80,\n", string(buf))
+
+	// fields not in the header start a new section
+	buf, err = s.Serialize(m3)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp,measurement,host,idle,system,user\n"+
+			"1488369600,cpu,server03,,1,\n",
+		string(buf))
+}
+
+func TestSerializeBatchColumns(t *testing.T) {
+	now := time.Unix(1488369600, 0)
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"idle": int64(90)},
+		now)
+	require.NoError(t, err)
+	m2, err := metric.New("mem",
+		map[string]string{"region": "eu"},
+		map[string]interface{}{"free": int64(1024)},
+		now)
+	require.NoError(t, err)
+
+	s, err := NewSerializer(true, "", "", false)
+	require.NoError(t, err)
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	require.NoError(t, err)
+	assert.Equal(t,
+		"timestamp,measurement,host,region,free,idle\n"+
+			"1488369600,cpu,server01,,,90\n"+
+			"1488369600,mem,,eu,1024,\n",
+		string(buf))
+}
@@ -13,14 +13,27 @@ const DEFAULT_TEMPLATE = "host.tags.measurement.field"
 var (
 	fieldDeleter   = strings.NewReplacer(".FIELDNAME", "", "FIELDNAME.", "")
 	sanitizedChars = strings.NewReplacer("/", "-", "@", "-", "*", "-", " ", "_", "..", ".", `\`, "", ")", "_", "(", "_")
+	// characters not allowed in the path, tag keys and tag values of the
+	// Graphite 1.1 tagged series.
+	taggedPathChars  = strings.NewReplacer(";", "_")
+	taggedKeyChars   = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_", " ", "_")
+	taggedValueChars = strings.NewReplacer(";", "_", "~", "_", " ", "_")
 )
 
 type GraphiteSerializer struct {
 	Prefix   string
 	Template string
+	// TagSupport writes the tags as Graphite 1.1 tagged series, ie:
+	//   prefix.measurement.field;tag1=value1;tag2=value2 value timestamp
+	// instead of inserting them in the path with the template.
+	TagSupport bool
 }
 
 func (s *GraphiteSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	if s.TagSupport {
+		return s.serializeTagged(metric), nil
+	}
+
 	out := []byte{}
 
 	// Convert UnixNano to Unix timestamps
@@ -44,6 +57,68 @@ func (s *GraphiteSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
 	return out, nil
 }
 
+// serializeTagged serializes the metric as Graphite 1.1 tagged series, with a
+// series per field.
+func (s *GraphiteSerializer) serializeTagged(metric telegraf.Metric) []byte {
+	out := []byte{}
+
+	// Convert UnixNano to Unix timestamps
+	timestamp := metric.UnixNano() / 1000000000
+
+	tags := SerializeTags(metric.Tags())
+	for fieldName, value := range metric.Fields() {
+		path := metric.Name()
+		if fieldName != "value" {
+			path += "." + fieldName
+		}
+		if s.Prefix != "" {
+			path = s.Prefix + "." + path
+		}
+
+		// Convert value to string
+		valueS := fmt.Sprintf("%#v", value)
+		point := []byte(fmt.Sprintf("%s%s %s %d\n",
+			taggedPathChars.Replace(sanitizedChars.Replace(path)),
+			tags,
+			sanitizedChars.Replace(valueS),
+			timestamp))
+		out = append(out, point...)
+	}
+	return out
+}
+
+// SerializeTags returns the tags in the format of Graphite 1.1 tagged series,
+// ordered by key, ie: ";tag1=value1;tag2=value2". Tags with an empty value
+// are skipped, as they are not allowed by Graphite.
+func SerializeTags(tags map[string]string) string {
+	var keys []string
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var tagStr string
+	for _, k := range keys {
+		tagStr += ";" + taggedKeyChars.Replace(k) + "=" + taggedValueChars.Replace(tags[k])
+	}
+	return tagStr
+}
+
+// SerializeBatch serializes the metrics as consecutive graphite lines.
+func (s *GraphiteSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var out []byte
+	for _, metric := range metrics {
+		buf, err := s.Serialize(metric)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, buf...)
+	}
+	return out, nil
+}
+
 // SerializeBucketName will take the given measurement name and tags and
 // produce a graphite bucket. It will use the GraphiteSerializer.Template
 // to generate this, or DEFAULT_TEMPLATE.
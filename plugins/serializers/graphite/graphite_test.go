@@ -9,7 +9,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/graphite"
 )
 
 var defaultTags = map[string]string{
@@ -416,3 +418,84 @@ func TestTemplate6(t *testing.T) {
 	expS := "localhost.cpu0.us-west-2.cpu.FIELDNAME"
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		now)
+	assert.NoError(t, err)
+	m2, err := metric.New("mem",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"used": int64(42)},
+		now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	assert.NoError(t, err)
+	expS := fmt.Sprintf("localhost.cpu.usage_idle 91.5 %d\nlocalhost.mem.used 42 %d\n",
+		now.Unix(), now.Unix())
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeTagSupport(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"host":       "localhost",
+		"cpu":        "cpu0",
+		"datacenter": "us;west=2",
+		"empty":      "",
+		"my key":     "my value",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+		"value":      float64(8.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{
+		Prefix:     "prefix",
+		TagSupport: true,
+	}
+	buf, _ := s.Serialize(m)
+	mS := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	assert.NoError(t, err)
+
+	expS := []string{
+		fmt.Sprintf("prefix.cpu.usage_idle;cpu=cpu0;datacenter=us_west=2;host=localhost;my_key=my_value 91.5 %d", now.Unix()),
+		fmt.Sprintf("prefix.cpu;cpu=cpu0;datacenter=us_west=2;host=localhost;my_key=my_value 8.5 %d", now.Unix()),
+	}
+	sort.Strings(mS)
+	sort.Strings(expS)
+	assert.Equal(t, expS, mS)
+}
+
+func TestSerializeTagSupportRoundTrip(t *testing.T) {
+	now := time.Unix(1455320660, 0)
+	tags := map[string]string{
+		"host": "localhost",
+		"cpu":  "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{TagSupport: true}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+
+	p, err := graphite.NewGraphiteParser("", []string{"measurement.field"}, nil)
+	assert.NoError(t, err)
+	metrics, err := p.Parse(buf)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, m.Name(), metrics[0].Name())
+	assert.Equal(t, m.Tags(), metrics[0].Tags())
+	assert.Equal(t, m.Fields(), metrics[0].Fields())
+	assert.Equal(t, m.Time().Unix(), metrics[0].Time().Unix())
+}
@@ -10,3 +10,13 @@ type InfluxSerializer struct {
 func (s *InfluxSerializer) Serialize(m telegraf.Metric) ([]byte, error) {
 	return m.Serialize(), nil
 }
+
+// SerializeBatch serializes the metrics as consecutive lines of the line
+// protocol.
+func (s *InfluxSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf []byte
+	for _, m := range metrics {
+		buf = append(buf, m.Serialize()...)
+	}
+	return buf, nil
+}
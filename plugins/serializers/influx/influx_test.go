@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
 
@@ -70,3 +71,24 @@ func TestSerializeMetricString(t *testing.T) {
 	expS := []string{fmt.Sprintf("cpu,cpu=cpu0 usage_idle=\"foobar\" %d", now.UnixNano())}
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	m1, err := metric.New("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		now)
+	assert.NoError(t, err)
+	m2, err := metric.New("mem",
+		map[string]string{},
+		map[string]interface{}{"used": int64(42)},
+		now)
+	assert.NoError(t, err)
+
+	s := InfluxSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	assert.NoError(t, err)
+	expS := fmt.Sprintf("cpu,cpu=cpu0 usage_idle=91.5 %d\nmem used=42i %d\n",
+		now.UnixNano(), now.UnixNano())
+	assert.Equal(t, expS, string(buf))
+}
@@ -10,12 +10,7 @@ type JsonSerializer struct {
 }
 
 func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
-	m := make(map[string]interface{})
-	m["tags"] = metric.Tags()
-	m["fields"] = metric.Fields()
-	m["name"] = metric.Name()
-	m["timestamp"] = metric.UnixNano() / 1000000000
-	serialized, err := ejson.Marshal(m)
+	serialized, err := ejson.Marshal(s.createObject(metric))
 	if err != nil {
 		return []byte{}, err
 	}
@@ -23,3 +18,27 @@ func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
 
 	return serialized, nil
 }
+
+// SerializeBatch serializes the metrics as a JSON array.
+func (s *JsonSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	objects := make([]map[string]interface{}, 0, len(metrics))
+	for _, metric := range metrics {
+		objects = append(objects, s.createObject(metric))
+	}
+	serialized, err := ejson.Marshal(objects)
+	if err != nil {
+		return []byte{}, err
+	}
+	serialized = append(serialized, '\n')
+
+	return serialized, nil
+}
+
+func (s *JsonSerializer) createObject(metric telegraf.Metric) map[string]interface{} {
+	m := make(map[string]interface{})
+	m["tags"] = metric.Tags()
+	m["fields"] = metric.Fields()
+	m["name"] = metric.Name()
+	m["timestamp"] = metric.UnixNano() / 1000000000
+	return m
+}
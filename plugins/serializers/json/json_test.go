@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
 
@@ -108,3 +109,25 @@ func TestSerializeMetricWithEscapes(t *testing.T) {
 	expS := []byte(fmt.Sprintf(`{"fields":{"U,age=Idle":90},"name":"My CPU","tags":{"cpu tag":"cpu0"},"timestamp":%d}`, now.Unix()) + "\n")
 	assert.Equal(t, string(expS), string(buf))
 }
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	m1, err := metric.New("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		now)
+	assert.NoError(t, err)
+	m2, err := metric.New("mem",
+		map[string]string{},
+		map[string]interface{}{"used": int64(42)},
+		now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	assert.NoError(t, err)
+	expS := fmt.Sprintf(`[{"fields":{"usage_idle":91.5},"name":"cpu","tags":{"cpu":"cpu0"},"timestamp":%d},`+
+		`{"fields":{"used":42},"name":"mem","tags":{},"timestamp":%d}]`,
+		now.Unix(), now.Unix()) + "\n"
+	assert.Equal(t, expS, string(buf))
+}
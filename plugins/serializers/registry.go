@@ -1,8 +1,11 @@
 package serializers
 
 import (
+	"fmt"
+
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/serializers/csv"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
@@ -24,10 +27,69 @@ type Serializer interface {
 	Serialize(metric telegraf.Metric) ([]byte, error)
 }
 
+// BatchSerializer is an interface for serializers that are able to serialize
+// several metrics into a single buffer, when the result differs from the
+// metrics serialized one at a time, ie: a JSON array.
+type BatchSerializer interface {
+	// SerializeBatch takes a batch of telegraf metrics and turns them into a
+	// single byte buffer.
+	SerializeBatch(metrics []telegraf.Metric) ([]byte, error)
+}
+
+const (
+	// BatchFormatMetric sends one message per metric.
+	BatchFormatMetric = "metric"
+	// BatchFormatBatch sends one message per batch of metrics.
+	BatchFormatBatch = "batch"
+)
+
+// CheckBatchFormat returns an error if the batch format of an output is
+// neither BatchFormatMetric nor BatchFormatBatch. An empty format stands for
+// the default format of the output.
+func CheckBatchFormat(format string) error {
+	switch format {
+	case "", BatchFormatMetric, BatchFormatBatch:
+		return nil
+	}
+	return fmt.Errorf("invalid batch_format %q, must be %q or %q",
+		format, BatchFormatMetric, BatchFormatBatch)
+}
+
+// SerializeBatch turns a batch of metrics into a single byte buffer, using the
+// SerializeBatch function of the serializer if it is a BatchSerializer, and
+// concatenating the metrics serialized one at a time otherwise.
+func SerializeBatch(s Serializer, metrics []telegraf.Metric) ([]byte, error) {
+	if bs, ok := s.(BatchSerializer); ok {
+		return bs.SerializeBatch(metrics)
+	}
+
+	var buf []byte
+	for _, m := range metrics {
+		b, err := s.Serialize(m)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// ContentType returns the MIME type of the data serialized by a serializer,
+// for the outputs sending it over protocols with a content type.
+func ContentType(s Serializer) string {
+	switch s.(type) {
+	case *json.JsonSerializer:
+		return "application/json"
+	case *csv.CSVSerializer:
+		return "text/csv; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
 // Config is a struct that covers the data types needed for all serializer types,
 // and can be used to instantiate _any_ of the serializers.
 type Config struct {
-	// Dataformat can be one of: influx, graphite
+	// Dataformat can be one of: influx, graphite, json, csv
 	DataFormat string
 
 	// Prefix to add to all measurements, only supports Graphite
@@ -36,6 +98,16 @@ type Config struct {
 	// Template for converting telegraf metrics into Graphite
 	// only supports Graphite
 	Template string
+
+	// GraphiteTagSupport writes Graphite 1.1 tagged series, only supports
+	// Graphite
+	GraphiteTagSupport bool
+
+	// CSV configuration, only supports CSV
+	CSVHeader          bool
+	CSVTimestampFormat string
+	CSVDelimiter       string
+	CSVColumnPrefix    bool
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -46,9 +118,12 @@ func NewSerializer(config *Config) (Serializer, error) {
 	case "influx":
 		serializer, err = NewInfluxSerializer()
 	case "graphite":
-		serializer, err = NewGraphiteSerializer(config.Prefix, config.Template)
+		serializer, err = NewGraphiteSerializer(config.Prefix, config.Template,
+			config.GraphiteTagSupport)
 	case "json":
 		serializer, err = NewJsonSerializer()
+	case "csv":
+		serializer, err = NewCSVSerializer(config)
 	}
 	return serializer, err
 }
@@ -61,9 +136,19 @@ func NewInfluxSerializer() (Serializer, error) {
 	return &influx.InfluxSerializer{}, nil
 }
 
-func NewGraphiteSerializer(prefix, template string) (Serializer, error) {
+func NewGraphiteSerializer(prefix, template string, tagSupport bool) (Serializer, error) {
 	return &graphite.GraphiteSerializer{
-		Prefix:   prefix,
-		Template: template,
+		Prefix:     prefix,
+		Template:   template,
+		TagSupport: tagSupport,
 	}, nil
 }
+
+func NewCSVSerializer(config *Config) (Serializer, error) {
+	s, err := csv.NewSerializer(config.CSVHeader, config.CSVTimestampFormat,
+		config.CSVDelimiter, config.CSVColumnPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
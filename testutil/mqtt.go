@@ -0,0 +1,389 @@
+package testutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// MQTT control packet types
+const (
+	mqttConnect     = 1
+	mqttConnack     = 2
+	mqttPublish     = 3
+	mqttPuback      = 4
+	mqttPubrec      = 5
+	mqttPubrel      = 6
+	mqttPubcomp     = 7
+	mqttSubscribe   = 8
+	mqttSuback      = 9
+	mqttUnsubscribe = 10
+	mqttUnsuback    = 11
+	mqttPingreq     = 12
+	mqttPingresp    = 13
+	mqttDisconnect  = 14
+)
+
+// MQTTMessage is a message published to the MQTTBroker.
+type MQTTMessage struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+}
+
+// MQTTBroker is a minimal in-process MQTT 3.1.1 broker for tests. It
+// acknowledges the messages with QoS 0, 1 and 2, keeps the retained messages,
+// and forwards the published messages to the matching subscriptions. Sessions
+// are not persisted between connections.
+type MQTTBroker struct {
+	listener net.Listener
+
+	mu        sync.Mutex
+	published []MQTTMessage
+	retained  map[string]MQTTMessage
+	conns     map[*mqttConn]bool
+	wg        sync.WaitGroup
+}
+
+type mqttConn struct {
+	net.Conn
+	writeMu       sync.Mutex
+	subscriptions map[string]byte
+	packetID      uint16
+}
+
+// NewMQTTBroker starts a broker listening on a random local port.
+func NewMQTTBroker() (*MQTTBroker, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &MQTTBroker{
+		listener: l,
+		retained: make(map[string]MQTTMessage),
+		conns:    make(map[*mqttConn]bool),
+	}
+	b.wg.Add(1)
+	go b.accept()
+	return b, nil
+}
+
+// Addr returns the "host:port" address of the broker.
+func (b *MQTTBroker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Close stops the broker and closes all the connections.
+func (b *MQTTBroker) Close() {
+	b.listener.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		c.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// Published returns the messages published to the broker by its clients.
+func (b *MQTTBroker) Published() []MQTTMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]MQTTMessage{}, b.published...)
+}
+
+// Publish sends a message to the subscribed clients, as if it was published
+// by another client.
+func (b *MQTTBroker) Publish(msg MQTTMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.route(msg)
+}
+
+func (b *MQTTBroker) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &mqttConn{Conn: conn, subscriptions: make(map[string]byte)}
+		b.mu.Lock()
+		b.conns[c] = true
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go b.serve(c)
+	}
+}
+
+func (b *MQTTBroker) serve(c *mqttConn) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	for {
+		header, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case mqttConnect:
+			c.write(mqttConnack<<4, []byte{0, 0})
+		case mqttPublish:
+			if err := b.onPublish(c, header, body); err != nil {
+				return
+			}
+		case mqttPubrel:
+			c.write(mqttPubcomp<<4, body[:2])
+		case mqttPubrec:
+			c.write(mqttPubrel<<4|0x02, body[:2])
+		case mqttPuback, mqttPubcomp:
+		case mqttSubscribe:
+			if err := b.onSubscribe(c, body); err != nil {
+				return
+			}
+		case mqttUnsubscribe:
+			b.onUnsubscribe(c, body)
+		case mqttPingreq:
+			c.write(mqttPingresp<<4, nil)
+		case mqttDisconnect:
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (b *MQTTBroker) onPublish(c *mqttConn, header byte, body []byte) error {
+	msg := MQTTMessage{
+		QoS:      (header >> 1) & 0x03,
+		Retained: header&0x01 != 0,
+	}
+	topic, rest, err := readMQTTString(body)
+	if err != nil {
+		return err
+	}
+	msg.Topic = topic
+
+	var id []byte
+	if msg.QoS > 0 {
+		if len(rest) < 2 {
+			return errors.New("missing packet id")
+		}
+		id, rest = rest[:2], rest[2:]
+	}
+	msg.Payload = append([]byte{}, rest...)
+
+	b.mu.Lock()
+	b.published = append(b.published, msg)
+	b.route(msg)
+	b.mu.Unlock()
+
+	switch msg.QoS {
+	case 1:
+		c.write(mqttPuback<<4, id)
+	case 2:
+		c.write(mqttPubrec<<4, id)
+	}
+	return nil
+}
+
+// route keeps the retained message and forwards it to the subscriptions,
+// b.mu must be held.
+func (b *MQTTBroker) route(msg MQTTMessage) {
+	if msg.Retained {
+		if len(msg.Payload) == 0 {
+			delete(b.retained, msg.Topic)
+		} else {
+			b.retained[msg.Topic] = msg
+		}
+	}
+
+	for c := range b.conns {
+		for filter, qos := range c.subscriptions {
+			if matchMQTTTopic(filter, msg.Topic) {
+				// retained is only set for the messages sent on subscription.
+				c.send(msg, qos, false)
+				break
+			}
+		}
+	}
+}
+
+func (b *MQTTBroker) onSubscribe(c *mqttConn, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("missing packet id")
+	}
+	id, rest := body[:2], body[2:]
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	granted := append([]byte{}, id...)
+	var filters []string
+	for len(rest) > 0 {
+		filter, r, err := readMQTTString(rest)
+		if err != nil || len(r) < 1 {
+			return errors.New("invalid subscription")
+		}
+		qos := r[0] & 0x03
+		rest = r[1:]
+
+		c.subscriptions[filter] = qos
+		filters = append(filters, filter)
+		granted = append(granted, qos)
+	}
+	c.write(mqttSuback<<4, granted)
+
+	for _, msg := range b.retained {
+		for _, filter := range filters {
+			if matchMQTTTopic(filter, msg.Topic) {
+				c.send(msg, c.subscriptions[filter], true)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (b *MQTTBroker) onUnsubscribe(c *mqttConn, body []byte) {
+	if len(body) < 2 {
+		return
+	}
+	id, rest := body[:2], body[2:]
+
+	b.mu.Lock()
+	for len(rest) > 0 {
+		filter, r, err := readMQTTString(rest)
+		if err != nil {
+			break
+		}
+		delete(c.subscriptions, filter)
+		rest = r
+	}
+	b.mu.Unlock()
+
+	c.write(mqttUnsuback<<4, id)
+}
+
+// send forwards a message to the client with the lowest of the QoS of the
+// message and of the subscription.
+func (c *mqttConn) send(msg MQTTMessage, qos byte, retained bool) {
+	if msg.QoS < qos {
+		qos = msg.QoS
+	}
+
+	header := byte(mqttPublish<<4) | qos<<1
+	if retained {
+		header |= 0x01
+	}
+
+	body := mqttString(msg.Topic)
+	if qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		id := make([]byte, 2)
+		binary.BigEndian.PutUint16(id, c.packetID)
+		body = append(body, id...)
+	}
+	body = append(body, msg.Payload...)
+	c.write(header, body)
+}
+
+func (c *mqttConn) write(header byte, body []byte) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	packet := []byte{header}
+	// remaining length, 7 bits per byte
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+	c.Write(packet)
+}
+
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var length int
+	multiplier := 1
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func readMQTTString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("invalid string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("invalid string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func mqttString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// matchMQTTTopic returns true if the topic matches the filter, with the "+"
+// single level and "#" multi level wildcards.
+func matchMQTTTopic(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}